@@ -1,68 +1,243 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"golift.io/starr"
-	"golift.io/starr/sonarr"
 
 	"github.com/woiza/telegram-bot-sonarr/pkg/bot"
 	"github.com/woiza/telegram-bot-sonarr/pkg/config"
+	"github.com/woiza/telegram-bot-sonarr/pkg/discovery"
+	"github.com/woiza/telegram-bot-sonarr/pkg/mediaserver"
+	"github.com/woiza/telegram-bot-sonarr/pkg/metrics"
+	"github.com/woiza/telegram-bot-sonarr/pkg/sonarrapi"
 )
 
-func main() {
-	fmt.Println("Starting bot...")
+const shutdownTimeout = 30 * time.Second
 
+func main() {
 	// get config from environment variables
 	config, err := config.LoadConfig()
 	if err != nil {
 		// Handle error: configuration is incomplete or invalid
-		log.Fatal(err)
+		fatal("Invalid configuration", err)
 	}
 
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(config.LogLevel)
+	slog.SetDefault(slog.New(newLogHandler(os.Stdout, config.LogFormat, logLevel)))
+	slog.Info("Starting bot...")
+
 	b, err := tgbotapi.NewBotAPI(config.TelegramBotToken)
 	if err != nil {
-		log.Fatal("Error while starting bot: ", err)
+		fatal("Error while starting bot", err)
 	}
 
-	fmt.Printf("Authorized on account %v\n", b.Self.UserName)
+	slog.Info("Authorized on account", "account", b.Self.UserName)
 
-	sonarrConfig := starr.New(config.SonarrAPIKey, fmt.Sprintf("%v://%v:%v%v", config.SonarrProtocol, config.SonarrHostname, config.SonarrPort, config.SonarrBaseUrl), 0)
-	sonarrServer := sonarr.New(sonarrConfig)
+	sonarrServer := newSonarrServer(config)
 
 	botInstance := bot.New(&config, b, sonarrServer)
 
-	// Channel for receiving updates from the bot API
-	updates := make(chan tgbotapi.Update)
-	defer close(updates)
+	if config.AuditLogFile != "" {
+		auditFile, err := os.OpenFile(config.AuditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			fatal("Error opening audit log file", err)
+		}
+		defer auditFile.Close()
+		botInstance.AuditLog = slog.New(slog.NewJSONHandler(auditFile, nil))
+	}
+
+	if config.SettingsFile != "" {
+		if err := botInstance.LoadSettings(config.SettingsFile); err != nil {
+			fatal("Error loading settings file", err)
+		}
+	}
+
+	botInstance.Discovery, err = newDiscoveryProvider(config)
+	if err != nil {
+		fatal("Error setting up discovery", err)
+	}
+
+	botInstance.MediaServer = newMediaServer(config)
+
+	// Reload the configuration on SIGHUP or when the config file changes
+	go watchConfig(botInstance, config, sonarrServer, logLevel)
+
+	// Stop receiving updates on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Start a goroutine to fetch updates from the bot API and send to the updates channel
+	// Evaluate the cleanup rules on their schedule
+	go botInstance.ScheduleCleanup(ctx)
+
+	if config.MetricsListen != "" {
+		botInstance.ListenForHealth(ctx, config.MetricsListen)
+	}
+
+	// Receive updates via webhook if configured, otherwise via long polling
+	var updates <-chan tgbotapi.Update
+	if config.WebhookURL != "" {
+		updates, err = botInstance.ListenForWebhook(ctx)
+		if err != nil {
+			fatal("Error while setting up webhook", err)
+		}
+	} else {
+		updates = botInstance.PollUpdates(ctx)
+	}
+
+	// Handle updates until the updates channel is closed and drained
+	handled := make(chan struct{})
 	go func() {
-		lastUpdateID := 0
-		for {
-			updateConfig := tgbotapi.NewUpdate(lastUpdateID + 1)
-			updateConfig.Timeout = 60
-
-			updatesChannel := b.GetUpdatesChan(updateConfig)
-			if err != nil {
-				log.Println("Error getting updates channel:", err)
-				time.Sleep(5 * time.Second)
+		botInstance.HandleUpdates(updates)
+		close(handled)
+	}()
+
+	<-ctx.Done()
+	slog.Info("Shutting down, waiting for running commands...")
+	select {
+	case <-handled:
+		slog.Info("Bot stopped")
+	case <-time.After(shutdownTimeout):
+		slog.Warn("Timed out waiting for running commands")
+	}
+	botInstance.CancelPendingDeletes()
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func newLogHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	options := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
+}
+
+func newSonarrServer(config config.Config) *sonarrapi.Client {
+	sonarrConfig := starr.New(config.SonarrAPIKey, fmt.Sprintf("%v://%v:%v%v", config.SonarrProtocol, config.SonarrHostname, config.SonarrPort, config.SonarrBaseUrl), 0)
+	sonarrConfig.Client.Transport = metrics.InstrumentTransport(sonarrConfig.Client.Transport)
+	return sonarrapi.New(sonarrConfig)
+}
+
+// newDiscoveryProvider returns the provider of /discover, or nil if none is
+// configured.
+func newDiscoveryProvider(config config.Config) (bot.DiscoveryProvider, error) {
+	switch config.DiscoverProvider {
+	case "trakt":
+		return discovery.NewTrakt(config.DiscoverAPIKey), nil
+	case "tmdb":
+		return discovery.NewTMDB(config.DiscoverAPIKey), nil
+	case "fixture":
+		return discovery.LoadFixture(config.DiscoverFixture)
+	}
+	return nil, nil
+}
+
+// newMediaServer returns the media server of the watch status, or nil if none
+// is configured.
+func newMediaServer(config config.Config) bot.MediaServer {
+	switch config.MediaServerType {
+	case "plex":
+		return mediaserver.NewPlex(config.MediaServerURL, config.MediaServerToken)
+	case "jellyfin":
+		return mediaserver.NewJellyfin(config.MediaServerURL, config.MediaServerToken, config.MediaServerUserID)
+	case "emby":
+		return mediaserver.NewEmby(config.MediaServerURL, config.MediaServerToken, config.MediaServerUserID)
+	}
+	return nil
+}
+
+func watchConfig(botInstance *bot.Bot, current config.Config, currentServer *sonarrapi.Client, logLevel *slog.LevelVar) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	// Poll the config file, if any, for modifications
+	var fileTicks <-chan time.Time
+	var lastModified time.Time
+	if current.ConfigFile != "" {
+		if info, err := os.Stat(current.ConfigFile); err == nil {
+			lastModified = info.ModTime()
+		}
+		fileTicks = time.NewTicker(10 * time.Second).C
+	}
+
+	for {
+		select {
+		case <-hangup:
+			slog.Info("SIGHUP received, reloading config")
+		case <-fileTicks:
+			info, err := os.Stat(current.ConfigFile)
+			if err != nil || !info.ModTime().After(lastModified) {
 				continue
 			}
+			lastModified = info.ModTime()
+			slog.Info("Config file changed, reloading config")
+		}
 
-			for update := range updatesChannel {
-				updates <- update // Send updates to the updates channel
-				lastUpdateID = update.UpdateID
-			}
+		newConfig, newServer, err := reloadConfig(current, currentServer)
+		if err != nil {
+			slog.Error("Config reload rejected", "error", err)
+			botInstance.NotifyAdmins(fmt.Sprintf("Config reload rejected, the previous config stays active:\n%v", err))
+			continue
 		}
-	}()
+		if newConfig.TelegramBotToken != current.TelegramBotToken {
+			slog.Warn("SBOT_TELEGRAM_BOT_TOKEN changed, a restart is required to apply it")
+		}
+		if newConfig.WebhookURL != current.WebhookURL || newConfig.WebhookListen != current.WebhookListen || newConfig.WebhookSecret != current.WebhookSecret ||
+			newConfig.WebhookCert != current.WebhookCert || newConfig.WebhookKey != current.WebhookKey {
+			slog.Warn("SBOT_TELEGRAM_WEBHOOK_* changed, a restart is required to apply it")
+		}
+		if newConfig.MetricsListen != current.MetricsListen {
+			slog.Warn("SBOT_METRICS_LISTEN changed, a restart is required to apply it")
+		}
+		if newConfig.LogFormat != current.LogFormat || newConfig.AuditLogFile != current.AuditLogFile || newConfig.SettingsFile != current.SettingsFile {
+			slog.Warn("SBOT_LOG_FORMAT, SBOT_AUDIT_LOG_FILE or SBOT_SETTINGS_FILE changed, a restart is required to apply it")
+		}
+		if newConfig.DiscoverProvider != current.DiscoverProvider || newConfig.DiscoverAPIKey != current.DiscoverAPIKey || newConfig.DiscoverFixture != current.DiscoverFixture {
+			slog.Warn("SBOT_DISCOVER_* changed, a restart is required to apply it")
+		}
+		if newConfig.MediaServerType != current.MediaServerType || newConfig.MediaServerURL != current.MediaServerURL ||
+			newConfig.MediaServerToken != current.MediaServerToken || newConfig.MediaServerUserID != current.MediaServerUserID {
+			slog.Warn("SBOT_MEDIASERVER_* changed, a restart is required to apply it")
+		}
+		logLevel.Set(newConfig.LogLevel)
+		botInstance.Reload(&newConfig, newServer)
+		current, currentServer = newConfig, newServer
+		slog.Info("Config reloaded")
+	}
+}
 
-	// Start a goroutine to handle updates concurrently
-	go botInstance.HandleUpdates(updates)
+// reloadConfig loads and validates a new configuration. A new Sonarr server is
+// only created, and checked for reachability, if its connection settings changed.
+func reloadConfig(current config.Config, currentServer *sonarrapi.Client) (config.Config, *sonarrapi.Client, error) {
+	newConfig, err := config.LoadConfig()
+	if err != nil {
+		return current, currentServer, err
+	}
 
-	// This can be a long-running process to handle incoming updates
-	select {}
+	if newConfig.SonarrProtocol == current.SonarrProtocol &&
+		newConfig.SonarrHostname == current.SonarrHostname &&
+		newConfig.SonarrPort == current.SonarrPort &&
+		newConfig.SonarrBaseUrl == current.SonarrBaseUrl &&
+		newConfig.SonarrAPIKey == current.SonarrAPIKey {
+		return newConfig, currentServer, nil
+	}
+
+	newServer := newSonarrServer(newConfig)
+	if err := newServer.Ping(); err != nil {
+		return current, currentServer, fmt.Errorf("new Sonarr server is not reachable: %w", err)
+	}
+	return newConfig, newServer, nil
 }
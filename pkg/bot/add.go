@@ -2,6 +2,7 @@ package bot
 
 import (
 	"fmt"
+	"log/slog"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,23 +14,102 @@ import (
 )
 
 const (
-	AddSeriesYes              = "ADDSERIES_YES"
-	AddSeriesGoBack           = "ADDSERIES_GOBACK"
-	AddSeriesProfileGoBack    = "ADDSERIES_QUALITY_GOBACK"
-	AddSeriesRootFolderGoBack = "ADDSERIES_ROOTFOLDER_GOBACK"
-	AddSeriesTagsGoBack       = "ADDSERIES_TAGSGOBACK"
-	AddSeriesTypeGoBack       = "ADDSERIES_TYPEGOBACK"
-	AddSeriesMonitorGoBack    = "ADDSERIES_MONITORGOBACK"
-	AddSeriesAddOptionsGoBack = "ADDSERIES_ADDOPTIONS_GOBACK"
-	AddSeriesCancel           = "ADDSERIES_CANCEL"
-	AddSeriesTagsDone         = "ADDSERIES_TAGS_DONE"
-	AddSeries                 = "ADDSERIES_VANILLA"
-	AddSeriesMissing          = "ADDSERIES_MISSING"
-	AddSeriesMissingCutOff    = "ADDSERIES_MISSING_CUTOFF"
-	AddSeriesCutOff           = "ADDSERIES_CUTOFF"
+	AddSeriesYes                  = "ADDSERIES_YES"
+	AddSeriesGoBack               = "ADDSERIES_GOBACK"
+	AddSeriesProfileGoBack        = "ADDSERIES_QUALITY_GOBACK"
+	AddSeriesRootFolderGoBack     = "ADDSERIES_ROOTFOLDER_GOBACK"
+	AddSeriesTagsGoBack           = "ADDSERIES_TAGSGOBACK"
+	AddSeriesTypeGoBack           = "ADDSERIES_TYPEGOBACK"
+	AddSeriesMonitorGoBack        = "ADDSERIES_MONITORGOBACK"
+	AddSeriesAddOptionsGoBack     = "ADDSERIES_ADDOPTIONS_GOBACK"
+	AddSeriesCancel               = "ADDSERIES_CANCEL"
+	AddSeriesTagsDone             = "ADDSERIES_TAGS_DONE"
+	AddSeriesNewTag               = "ADDSERIES_NEW_TAG"
+	AddSeriesCustomRootFolder     = "ADDSERIES_ROOTFOLDER_CUSTOM"
+	AddSeriesProfileDetails       = "ADDSERIES_PROFILE_DETAILS_"
+	AddSeriesProfileDetailsGoBack = "ADDSERIES_PROFILEDETAILS_GOBACK"
+	AddSeries                     = "ADDSERIES_VANILLA"
+	AddSeriesMissing              = "ADDSERIES_MISSING"
+	AddSeriesMissingCutOff        = "ADDSERIES_MISSING_CUTOFF"
+	AddSeriesCutOff               = "ADDSERIES_CUTOFF"
+	AddSeriesFirstPage            = "ADDSERIES_FIRST_PAGE"
+	AddSeriesPreviousPage         = "ADDSERIES_PREV_PAGE"
+	AddSeriesNextPage             = "ADDSERIES_NEXT_PAGE"
+	AddSeriesLastPage             = "ADDSERIES_LAST_PAGE"
+	AddSeriesRefine               = "ADDSERIES_REFINE"
+	AddSeriesChooseSeasons        = "ADDSERIES_CHOOSE_SEASONS"
+	AddSeriesSeasonsDone          = "ADDSERIES_SEASONS_DONE"
+	AddSeriesSeasonsGoBack        = "ADDSERIES_SEASONS_GOBACK"
+	// MonitorSeasons monitors the seasons chosen in the add wizard
+	MonitorSeasons = "seasons"
+	InLibraryIcon  = "\U0001F4DA" // Books
 )
 
-func (b *Bot) processAddCommand(update tgbotapi.Update, chatID int64, s *sonarr.Sonarr) {
+// Steps of the add series wizard in order.
+const (
+	addSeriesStepProfile = iota
+	addSeriesStepRootFolder
+	addSeriesStepTags
+	addSeriesStepType
+	addSeriesStepMonitor
+	addSeriesStepAddOptions
+)
+
+// addSeriesStepSkipped reports whether the wizard skips a step because there
+// is nothing to choose or the answer comes from the config or /settings.
+func (b *Bot) addSeriesStepSkipped(command *userAddSeries, step int) bool {
+	switch step {
+	case addSeriesStepProfile:
+		return len(command.allProfiles) == 1 || command.defaults.QualityProfileID != 0
+	case addSeriesStepRootFolder:
+		return len(command.allRootFolders) == 1 || command.defaults.RootFolder != ""
+	case addSeriesStepTags:
+		return len(command.allTags) == 0 || b.getConfig().IgnoreTags
+	case addSeriesStepType:
+		return b.getConfig().SeriesType != ""
+	case addSeriesStepMonitor:
+		return command.defaults.Monitor != ""
+	case addSeriesStepAddOptions:
+		return command.defaults.AddOption != ""
+	}
+	return false
+}
+
+func (b *Bot) showAddSeriesStep(command *userAddSeries, step int) bool {
+	switch step {
+	case addSeriesStepProfile:
+		return b.showAddSeriesProfiles(command)
+	case addSeriesStepRootFolder:
+		return b.showAddSeriesRootFolders(command)
+	case addSeriesStepTags:
+		return b.showAddSeriesTags(command)
+	case addSeriesStepType:
+		return b.showAddSeriesType(command)
+	case addSeriesStepMonitor:
+		return b.showAddSeriesMonitor(command)
+	default:
+		return b.showAddSeriesAddOptions(command)
+	}
+}
+
+// showAddSeriesStepBefore goes back to the last step before step that is not
+// skipped, or to the search results, /bulkadd review or /discover list.
+func (b *Bot) showAddSeriesStepBefore(command *userAddSeries, step int) bool {
+	for previous := step - 1; previous >= addSeriesStepProfile; previous-- {
+		if !b.addSeriesStepSkipped(command, previous) {
+			return b.showAddSeriesStep(command, previous)
+		}
+	}
+	if command.bulk != nil {
+		return b.showBulkAddReviewAgain(command.chatID)
+	}
+	if command.discover {
+		return b.showDiscoverShowsAgain(command.chatID)
+	}
+	return b.showAddSeriesSearchResults(command)
+}
+
+func (b *Bot) processAddCommand(update tgbotapi.Update, chatID int64, s SonarrClient) {
 	msg := tgbotapi.NewMessage(chatID, "Handling add series ommand... please wait")
 	message, _ := b.sendMessage(msg)
 	command := userAddSeries{
@@ -38,41 +118,90 @@ func (b *Bot) processAddCommand(update tgbotapi.Update, chatID int64, s *sonarr.
 	}
 
 	criteria := update.Message.CommandArguments()
+	if presetName, rest, found := parsePresetArgument(criteria); found {
+		if b.findPreset(presetName) == nil {
+			b.sendMessageWithEdit(&command, fmt.Sprintf("Unknown preset %q, presets: %v", presetName, b.presetNames()))
+			return
+		}
+		command.preset = presetName
+		criteria = rest
+	}
 	if len(criteria) < 1 {
 		b.sendMessageWithEdit(&command, "Please provide a search criteria /q [query]")
 		return
 	}
-	searchResults, err := s.Lookup("\"" + criteria + "\"")
+	exact, err := b.lookupAddSeries(&command, criteria)
 	if err != nil {
 		msg := tgbotapi.NewMessage(chatID, err.Error())
 		b.sendMessage(msg)
 		return
 	}
 
-	if len(searchResults) == 0 {
+	if len(command.searchResults) == 0 {
 		b.sendMessageWithEdit(&command, "No series found matching your search criteria")
 		return
 	}
-	if len(searchResults) > 25 {
-		b.sendMessageWithEdit(&command, "Result size too large, please narrow down your search criteria")
-		return
-	}
 
+	b.setAddSeriesState(command.chatID, &command)
+	b.setActiveCommand(command.chatID, AddSeriesCommand)
+	b.showAddSeriesLookup(&command, exact)
+}
+
+// lookupAddSeries searches Sonarr for criteria and replaces the search
+// results. exact is true if criteria was an ID or link.
+func (b *Bot) lookupAddSeries(command *userAddSeries, criteria string) (bool, error) {
+	term, exact := lookupTerm(criteria)
+	searchResults, err := b.getSonarrServer().Lookup(term)
+	if err != nil {
+		return false, err
+	}
 	command.searchResults = make(map[string]*sonarr.Series, len(searchResults))
 	for _, series := range searchResults {
 		tvdbID := strconv.Itoa(int(series.TvdbID))
 		command.searchResults[tvdbID] = series
 	}
+	command.page = 0
+	return exact, nil
+}
 
-	b.setAddSeriesState(command.chatID, &command)
-	b.setActiveCommand(command.chatID, AddSeriesCommand)
-	b.showAddSeriesSearchResults(&command)
+// showAddSeriesLookup shows the search results, or the confirmation if an
+// ID or link found a single series.
+func (b *Bot) showAddSeriesLookup(command *userAddSeries, exact bool) bool {
+	if exact && len(command.searchResults) == 1 {
+		for _, series := range command.searchResults {
+			command.series = series
+		}
+		return b.showAddSeriesDetails(command)
+	}
+	return b.showAddSeriesSearchResults(command)
+}
+
+// handleAddSeriesRefine replaces the search results with a new search. It
+// returns false if nothing was found.
+func (b *Bot) handleAddSeriesRefine(command *userAddSeries, text string) bool {
+	previous := command.searchResults
+	exact, err := b.lookupAddSeries(command, strings.TrimSpace(text))
+	if err != nil {
+		command.searchResults = previous
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return true
+	}
+	if len(command.searchResults) == 0 {
+		command.searchResults = previous
+		msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("No series found matching %q", strings.TrimSpace(text)))
+		b.sendMessage(msg)
+		return false
+	}
+	b.showAddSeriesLookup(command, exact)
+	return true
 }
 
 func (b *Bot) addSeries(update tgbotapi.Update) bool {
 	chatID, err := b.getChatID(update)
 	if err != nil {
-		fmt.Printf("Cannot add series: %v", err)
+		slog.Warn("Cannot add series", "update_id", update.UpdateID, "error", err)
 		return false
 	}
 	command, exists := b.getAddSeriesState(chatID)
@@ -84,56 +213,74 @@ func (b *Bot) addSeries(update tgbotapi.Update) bool {
 		b.setActiveCommand(chatID, AddSeriesCommand)
 		return b.handleAddSeriesYes(update, command)
 	case AddSeriesGoBack:
+		if command.discover {
+			return b.showDiscoverShowsAgain(chatID)
+		}
 		b.setAddSeriesState(command.chatID, command)
 		return b.showAddSeriesSearchResults(command)
 	case AddSeriesProfileGoBack:
-		return b.showAddSeriesSearchResults(command)
+		return b.showAddSeriesStepBefore(command, addSeriesStepProfile)
 	case AddSeriesRootFolderGoBack:
-		if len(command.allProfiles) == 1 {
-			return b.showAddSeriesSearchResults(command)
-		}
+		return b.showAddSeriesStepBefore(command, addSeriesStepRootFolder)
+	case AddSeriesProfileDetailsGoBack:
 		return b.showAddSeriesProfiles(command)
 	case AddSeriesTagsGoBack:
-		if len(command.allRootFolders) == 1 && len(command.allProfiles) == 1 {
-			return b.showAddSeriesSearchResults(command)
-		}
-		if len(command.allRootFolders) == 1 {
-			return b.showAddSeriesProfiles(command)
-		}
-		return b.showAddSeriesRootFolders(command)
+		return b.showAddSeriesStepBefore(command, addSeriesStepTags)
 	case AddSeriesTypeGoBack:
-		// Check if there are no tags or tags should be ignored
-		if len(command.allTags) == 0 || b.Config.IgnoreTags {
-			// Check if there is only one root folder and one profile
-			if len(command.allRootFolders) == 1 && len(command.allProfiles) == 1 {
-				return b.showAddSeriesSearchResults(command)
-			}
-			// Check if there is only one root folder
-			if len(command.allRootFolders) == 1 && len(command.allProfiles) > 1 {
-				return b.showAddSeriesProfiles(command)
-			}
-			// Check if there is only one profile
-			if len(command.allProfiles) == 1 && len(command.allRootFolders) > 1 {
-				return b.showAddSeriesRootFolders(command)
-			}
-			// If there are multiple root folders and profiles, go to root folders
-			return b.showAddSeriesRootFolders(command)
-		}
-		// If there are tags, go to the tags step
-		return b.showAddSeriesTags(command)
+		return b.showAddSeriesStepBefore(command, addSeriesStepType)
 	case AddSeriesMonitorGoBack:
-		if b.Config.SeriesType != "" {
-			return b.showAddSeriesSearchResults(command)
-		}
-		return b.showAddSeriesType(command)
+		return b.showAddSeriesStepBefore(command, addSeriesStepMonitor)
 	case AddSeriesAddOptionsGoBack:
-		return b.showAddSeriesMonitor(command)
+		return b.showAddSeriesStepBefore(command, addSeriesStepAddOptions)
 	case AddSeriesCancel:
 		b.clearState(update)
 		b.sendMessageWithEdit(command, CommandsCleared)
 		return false
+	case "current_page":
+		b.askForPage(chatID, b.addSeriesTotalPages(command), func(page int) {
+			command.page = page
+			b.showAddSeriesSearchResults(command)
+		})
+		return false
+	case AddSeriesFirstPage:
+		command.page = 0
+		return b.showAddSeriesSearchResults(command)
+	case AddSeriesPreviousPage:
+		if command.page > 0 {
+			command.page--
+		}
+		return b.showAddSeriesSearchResults(command)
+	case AddSeriesNextPage:
+		command.page++
+		return b.showAddSeriesSearchResults(command)
+	case AddSeriesLastPage:
+		command.page = b.addSeriesTotalPages(command) - 1
+		return b.showAddSeriesSearchResults(command)
+	case AddSeriesRefine:
+		b.askForText(chatID, "Send a new search (title, ID or link):", "e.g. breaking bad", func(text string) bool {
+			return b.handleAddSeriesRefine(command, text)
+		})
+		return false
 	case AddSeriesTagsDone:
 		return b.showAddSeriesType(command)
+	case AddSeriesCustomRootFolder:
+		b.askForText(chatID, "Send the path of the root folder:", "e.g. /tv/kids", func(text string) bool {
+			return b.handleAddSeriesCustomRootFolder(command, text)
+		})
+		return false
+	case AddSeriesNewTag:
+		b.askForText(chatID, TagNamePrompt, TagNamePlaceholder, func(text string) bool {
+			return b.handleAddSeriesTagName(command, text)
+		})
+		return false
+	case AddSeriesChooseSeasons:
+		return b.showAddSeriesSeasons(command)
+	case AddSeriesSeasonsDone:
+		command.monitor = MonitorSeasons
+		b.setAddSeriesState(command.chatID, command)
+		return b.showAddSeriesAddOptions(command)
+	case AddSeriesSeasonsGoBack:
+		return b.showAddSeriesMonitor(command)
 	case AddSeries:
 		return b.handleAddSeries(update, command)
 	case AddSeriesMissing:
@@ -143,6 +290,12 @@ func (b *Bot) addSeries(update tgbotapi.Update) bool {
 	case AddSeriesCutOff:
 		return b.handleAddSeriesCutOff(update, command)
 	default:
+		if strings.HasPrefix(update.CallbackQuery.Data, AddSeriesPreset) {
+			return b.handleAddSeriesPreset(update, command)
+		}
+		if strings.HasPrefix(update.CallbackQuery.Data, AddSeriesProfileDetails) {
+			return b.handleAddSeriesProfileDetails(update, command)
+		}
 		// Check if it starts with "PROFILE_"
 		if strings.HasPrefix(update.CallbackQuery.Data, "PROFILE_") {
 			return b.handleAddSeriesProfile(update, command)
@@ -163,6 +316,10 @@ func (b *Bot) addSeries(update tgbotapi.Update) bool {
 		if strings.HasPrefix(update.CallbackQuery.Data, "MONITOR_") {
 			return b.handleAddSeriesMonitor(update, command)
 		}
+		// Check if it starts with "SEASON_"
+		if strings.HasPrefix(update.CallbackQuery.Data, "SEASON_") {
+			return b.handleAddSeriesSelectSeason(update, command)
+		}
 		// Check if it starts with "TVDBID_"
 		if strings.HasPrefix(update.CallbackQuery.Data, "TVDBID_") {
 			return b.addSeriesDetails(update, command)
@@ -179,36 +336,61 @@ func (b *Bot) showAddSeriesSearchResults(command *userAddSeries) bool {
 		series = append(series, s)
 	}
 
-	// Sort series by year in ascending order
+	// Sort series by year in ascending order, then by title
 	sort.SliceStable(series, func(i, j int) bool {
-		return series[i].Year < series[j].Year
+		if series[i].Year != series[j].Year {
+			return series[i].Year < series[j].Year
+		}
+		return series[i].Title < series[j].Title
 	})
 
+	// Pagination parameters
+	pageSize := b.getConfig().MaxItems
+	totalPages := b.addSeriesTotalPages(command)
+	command.page = max(0, min(command.page, totalPages-1))
+	startIndex := command.page * pageSize
+	endIndex := min(startIndex+pageSize, len(series))
+
 	var buttonLabels []string
 	var buttonData []string
 	var text strings.Builder
 	var responseText string
+	var inLibrary bool
 
-	for _, series := range series {
-		fmt.Fprintf(&text, "[%v](https://www.imdb.com/title/%v) \\- _%v_\n", utils.Escape(series.Title), series.ImdbID, series.Year)
-		buttonLabels = append(buttonLabels, fmt.Sprintf("%v - %v", series.Title, series.Year))
+	for _, series := range series[startIndex:endIndex] {
+		fmt.Fprintf(&text, "[%v](https://www.imdb.com/title/%v) \\- _%v_", utils.Escape(series.Title), series.ImdbID, series.Year)
+		for _, marker := range searchResultMarkers(series) {
+			fmt.Fprintf(&text, " \\- %v", utils.Escape(marker))
+		}
+		fmt.Fprintf(&text, "\n")
+		buttonLabels = append(buttonLabels, searchResultLabel(series))
 		buttonData = append(buttonData, "TVDBID_"+strconv.Itoa(int(series.TvdbID)))
+		inLibrary = inLibrary || series.ID != 0
 	}
 
 	keyboard := b.createKeyboard(buttonLabels, buttonData)
+	if totalPages > 1 {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+			paginationButtons(command.page, totalPages, AddSeriesFirstPage, AddSeriesPreviousPage, AddSeriesNextPage, AddSeriesLastPage))
+	}
 	keyboardCancel := b.createKeyboard(
-		[]string{"Cancel - clear command"},
-		[]string{AddSeriesCancel},
+		[]string{"\U0001F50D Refine search", "Cancel - clear command"},
+		[]string{AddSeriesRefine, AddSeriesCancel},
 	)
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, keyboardCancel.InlineKeyboard...)
 
-	switch len(command.searchResults) {
-	case 1:
+	switch {
+	case len(command.searchResults) == 1:
 		responseText = "*Series found*\n\n"
+	case totalPages > 1:
+		responseText = fmt.Sprintf("*Found %d series* \\- page %d/%d\n\n", len(command.searchResults), command.page+1, totalPages)
 	default:
 		responseText = fmt.Sprintf("*Found %d series*\n\n", len(command.searchResults))
 	}
 	responseText += text.String()
+	if inLibrary {
+		responseText += fmt.Sprintf("\n%v already in your library\n", InLibraryIcon)
+	}
 
 	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
 		command.chatID,
@@ -223,17 +405,59 @@ func (b *Bot) showAddSeriesSearchResults(command *userAddSeries) bool {
 	return false
 }
 
+func (b *Bot) addSeriesTotalPages(command *userAddSeries) int {
+	pageSize := b.getConfig().MaxItems
+	return max(1, (len(command.searchResults)+pageSize-1)/pageSize)
+}
+
+// searchResultMarkers returns the network, status and, for series in the
+// library, the library icon.
+func searchResultMarkers(series *sonarr.Series) []string {
+	var markers []string
+	if series.Network != "" {
+		markers = append(markers, series.Network)
+	}
+	if series.Status != "" {
+		markers = append(markers, series.Status)
+	}
+	if series.ID != 0 {
+		markers = append(markers, InLibraryIcon)
+	}
+	return markers
+}
+
+// searchResultLabel is the button label of a search result, e.g.
+// "Breaking Bad - 2008 - AMC - ended".
+func searchResultLabel(series *sonarr.Series) string {
+	parts := []string{series.Title, strconv.Itoa(series.Year)}
+	return strings.Join(append(parts, searchResultMarkers(series)...), " - ")
+}
+
 func (b *Bot) addSeriesDetails(update tgbotapi.Update, command *userAddSeries) bool {
 	seriesIDStr := strings.TrimPrefix(update.CallbackQuery.Data, "TVDBID_")
 	command.series = command.searchResults[seriesIDStr]
+	return b.showAddSeriesDetails(command)
+}
 
+func (b *Bot) showAddSeriesDetails(command *userAddSeries) bool {
 	var text strings.Builder
 	fmt.Fprintf(&text, "Is this the correct series?\n\n")
 	fmt.Fprintf(&text, "[%v](https://www.imdb.com/title/%v) \\- _%v_\n\n", utils.Escape(command.series.Title), command.series.ImdbID, command.series.Year)
 
+	presetLabels, presetData := b.addSeriesPresetButtons(command)
+	labels := []string{"Yes, add this series"}
+	data := []string{AddSeriesYes}
+	if command.preset != "" {
+		// The preset chosen with /q --preset comes first
+		labels = append(presetLabels, labels...)
+		data = append(presetData, data...)
+	} else {
+		labels = append(labels, presetLabels...)
+		data = append(data, presetData...)
+	}
 	keyboard := b.createKeyboard(
-		[]string{"Yes, add this series", "\U0001F519"},
-		[]string{AddSeriesYes, AddSeriesGoBack})
+		append(labels, "\U0001F519"),
+		append(data, AddSeriesGoBack))
 
 	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
 		command.chatID,
@@ -255,10 +479,21 @@ func (b *Bot) handleAddSeriesYes(update tgbotapi.Update, command *userAddSeries)
 		return false
 	}
 
-	profiles, err := b.SonarrServer.GetQualityProfiles()
+	if !b.loadAddSeriesChoices(update, command) {
+		return false
+	}
+	b.setAddSeriesState(command.chatID, command)
+	return b.showAddSeriesProfiles(command)
+}
+
+// loadAddSeriesChoices loads the quality profiles, root folders and tags to
+// choose from. A single profile or root folder and the user's /settings are
+// selected right away.
+func (b *Bot) loadAddSeriesChoices(update tgbotapi.Update, command *userAddSeries) bool {
+	profiles, err := b.getSonarrServer().GetQualityProfiles()
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
-		fmt.Println(err)
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
 		b.sendMessage(msg)
 		return false
 	}
@@ -271,10 +506,10 @@ func (b *Bot) handleAddSeriesYes(update tgbotapi.Update, command *userAddSeries)
 	}
 	command.allProfiles = profiles
 
-	rootFolders, err := b.SonarrServer.GetRootFolders()
+	rootFolders, err := b.getSonarrServer().GetRootFolders()
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
-		fmt.Println(err)
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
 		b.sendMessage(msg)
 		return false
 	}
@@ -287,28 +522,29 @@ func (b *Bot) handleAddSeriesYes(update tgbotapi.Update, command *userAddSeries)
 	}
 	command.allRootFolders = rootFolders
 
-	tags, err := b.SonarrServer.GetTags()
+	tags, err := b.getSonarrServer().GetTags()
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
-		fmt.Println(err)
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
 		b.sendMessage(msg)
 		return false
 	}
 	command.allTags = tags
+	command.selectedSeasons = nil
 
-	b.setAddSeriesState(command.chatID, command)
-	return b.showAddSeriesProfiles(command)
+	b.applyUserSettings(command)
+	return true
 }
 
 func (b *Bot) showAddSeriesProfiles(command *userAddSeries) bool {
-	// If there is only one profile, skip this step
-	if len(command.allProfiles) == 1 {
+	if b.addSeriesStepSkipped(command, addSeriesStepProfile) {
 		return b.showAddSeriesRootFolders(command)
 	}
 	var profileKeyboard [][]tgbotapi.InlineKeyboardButton
 	for _, profile := range command.allProfiles {
 		row := []tgbotapi.InlineKeyboardButton{
 			tgbotapi.NewInlineKeyboardButtonData(profile.Name, "PROFILE_"+strconv.Itoa(int(profile.ID))),
+			tgbotapi.NewInlineKeyboardButtonData("ℹ️ Details", AddSeriesProfileDetails+strconv.Itoa(int(profile.ID))),
 		}
 		profileKeyboard = append(profileKeyboard, row)
 	}
@@ -336,7 +572,7 @@ func (b *Bot) handleAddSeriesProfile(update tgbotapi.Update, command *userAddSer
 	profileID, err := strconv.Atoi(profileIDStr)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
-		fmt.Println(err)
+		b.logger(command.chatID).Error("Cannot convert profile ID to int", "error", err)
 		b.sendMessage(msg)
 		return false
 	}
@@ -345,9 +581,24 @@ func (b *Bot) handleAddSeriesProfile(update tgbotapi.Update, command *userAddSer
 	return b.showAddSeriesRootFolders(command)
 }
 
+// handleAddSeriesProfileDetails shows the qualities and custom formats of a
+// profile, the back button returns to the profile selection.
+func (b *Bot) handleAddSeriesProfileDetails(update tgbotapi.Update, command *userAddSeries) bool {
+	profileID, err := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, AddSeriesProfileDetails), 10, 64)
+	if err != nil {
+		b.logger(command.chatID).Error("Cannot convert profile ID to int", "error", err)
+		return false
+	}
+	// The profile may have been deleted since the list was shown
+	profile := getQualityProfileByID(command.allProfiles, profileID)
+	if profile == nil {
+		return b.showAddSeriesProfiles(command)
+	}
+	return b.showQualityProfileDetails(command, profile, AddSeriesProfileDetailsGoBack)
+}
+
 func (b *Bot) showAddSeriesRootFolders(command *userAddSeries) bool {
-	// If there is only one root folder, skip this step
-	if len(command.allRootFolders) == 1 {
+	if b.addSeriesStepSkipped(command, addSeriesStepRootFolder) {
 		return b.showAddSeriesTags(command)
 	}
 	var rootFolderKeyboard [][]tgbotapi.InlineKeyboardButton
@@ -361,8 +612,8 @@ func (b *Bot) showAddSeriesRootFolders(command *userAddSeries) bool {
 	var messageText strings.Builder
 	var keyboard tgbotapi.InlineKeyboardMarkup
 	keyboardGoBack := b.createKeyboard(
-		[]string{"\U0001F519"},
-		[]string{AddSeriesRootFolderGoBack},
+		[]string{"✏️ Other path", "\U0001F519"},
+		[]string{AddSeriesCustomRootFolder, AddSeriesRootFolderGoBack},
 	)
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, rootFolderKeyboard...)
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, keyboardGoBack.InlineKeyboard...)
@@ -381,7 +632,7 @@ func (b *Bot) handleAddSeriesRootFolder(update tgbotapi.Update, command *userAdd
 	id, err := strconv.Atoi(data)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, "Invalid root folder selection.")
-		fmt.Println(err)
+		b.logger(command.chatID).Error("Cannot convert root folder ID to int", "error", err)
 		b.sendMessage(msg)
 		return false
 	}
@@ -394,7 +645,7 @@ func (b *Bot) handleAddSeriesRootFolder(update tgbotapi.Update, command *userAdd
 	}
 	if command.rootFolder == nil {
 		msg := tgbotapi.NewMessage(command.chatID, "Root folder not found.")
-		fmt.Println(err)
+		b.logger(command.chatID).Error("Root folder not found", "root_folder_id", id)
 		b.sendMessage(msg)
 		return false
 	}
@@ -404,8 +655,7 @@ func (b *Bot) handleAddSeriesRootFolder(update tgbotapi.Update, command *userAdd
 }
 
 func (b *Bot) showAddSeriesTags(command *userAddSeries) bool {
-	// If there are no tags or tags should be ignored, skip this step
-	if len(command.allTags) == 0 || b.Config.IgnoreTags {
+	if b.addSeriesStepSkipped(command, addSeriesStepTags) {
 		return b.showAddSeriesType(command)
 	}
 	var tagsKeyboard [][]tgbotapi.InlineKeyboardButton
@@ -427,8 +677,8 @@ func (b *Bot) showAddSeriesTags(command *userAddSeries) bool {
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tagsKeyboard...)
 
 	keyboardSubmitCancelGoBack := b.createKeyboard(
-		[]string{"Done - Continue", "\U0001F519"},
-		[]string{AddSeriesTagsDone, AddSeriesTagsGoBack},
+		[]string{"➕ New tag", "Done - Continue", "\U0001F519"},
+		[]string{AddSeriesNewTag, AddSeriesTagsDone, AddSeriesTagsGoBack},
 	)
 
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, keyboardSubmitCancelGoBack.InlineKeyboard...)
@@ -453,7 +703,7 @@ func (b *Bot) handleAddSeriesEditSelectTag(update tgbotapi.Update, command *user
 	// Parse the tag ID
 	tagID, err := strconv.Atoi(tagIDStr)
 	if err != nil {
-		fmt.Printf("Cannot convert tag string to int: %v", err)
+		b.logger(command.chatID).Error("Cannot convert tag string to int", "error", err)
 		return false
 	}
 	// Check if the tag is already selected
@@ -470,10 +720,40 @@ func (b *Bot) handleAddSeriesEditSelectTag(update tgbotapi.Update, command *user
 	return b.showAddSeriesTags(command)
 }
 
+// handleAddSeriesCustomRootFolder uses a typed absolute path as root folder.
+func (b *Bot) handleAddSeriesCustomRootFolder(command *userAddSeries, text string) bool {
+	folder := strings.TrimSpace(text)
+	if !isAbsolutePath(folder) {
+		return false
+	}
+	command.rootFolder = &sonarr.RootFolder{Path: folder}
+	b.setAddSeriesState(command.chatID, command)
+	b.showAddSeriesTags(command)
+	return true
+}
+
+// isAbsolutePath accepts Unix, Windows drive and UNC paths, as Sonarr may run
+// on another OS than the bot.
+func isAbsolutePath(folder string) bool {
+	return strings.HasPrefix(folder, "/") || strings.HasPrefix(folder, `\\`) ||
+		len(folder) >= 3 && folder[1] == ':' && (folder[2] == '\\' || folder[2] == '/')
+}
+
+// handleAddSeriesTagName creates and selects the typed tag.
+func (b *Bot) handleAddSeriesTagName(command *userAddSeries, text string) bool {
+	allTags, selectedTags, err := b.addTypedTag(command.chatID, text, command.allTags, command.selectedTags)
+	if err != nil {
+		return b.handleTypedTagError(command.chatID, err)
+	}
+	command.allTags = allTags
+	command.selectedTags = selectedTags
+	b.showAddSeriesTags(command)
+	return true
+}
+
 func (b *Bot) showAddSeriesType(command *userAddSeries) bool {
-	// If series type is set in config, skip this step
-	if b.Config.SeriesType != "" {
-		command.seriesType = b.Config.SeriesType
+	if b.addSeriesStepSkipped(command, addSeriesStepType) {
+		command.seriesType = b.getConfig().SeriesType
 		return b.showAddSeriesMonitor(command)
 	}
 
@@ -518,39 +798,48 @@ func (b *Bot) handleAddSeriesType(update tgbotapi.Update, command *userAddSeries
 	return b.showAddSeriesMonitor(command)
 }
 
-func (b *Bot) showAddSeriesMonitor(command *userAddSeries) bool {
+// monitorTypes are the monitoring options of the add series wizard and of
+// /settings.
+var monitorTypes = []struct {
+	Text  string
+	Value string
+}{
+	{Text: "All Episodes", Value: "all"},
+	{Text: "Future Episodes", Value: "future"},
+	{Text: "Missing Episodes", Value: "missing"},
+	{Text: "Existing Episodes", Value: "existing"},
+	{Text: "Recent Episodes", Value: "recent"},
+	{Text: "Pilot Episodes", Value: "pilot"},
+	{Text: "First Season", Value: "firstSeason"},
+	{Text: "Last Season", Value: "lastSeason"},
+	{Text: "Monitor Specials", Value: "monitorSpecials"},
+	{Text: "Unmonitor Specials", Value: "unmonitorSpecials"},
+	{Text: "None", Value: "none"},
+}
 
-	monitorTypes := []struct {
-		Text string
-		Data string
-	}{
-		{Text: "All Episodes", Data: "MONITOR_all"},
-		{Text: "Future Episodes", Data: "MONITOR_future"},
-		{Text: "Missing Episodes", Data: "MONITOR_missing"},
-		{Text: "Existing Episodes", Data: "MONITOR_existing"},
-		{Text: "Recent Episodes", Data: "MONITOR_recent"},
-		{Text: "Pilot Episodes", Data: "MONITOR_pilot"},
-		{Text: "First Season", Data: "MONITOR_firstSeason"},
-		{Text: "Last Season", Data: "MONITOR_lastSeason"},
-		{Text: "Monitor Specials", Data: "MONITOR_monitorSpecials"},
-		{Text: "Unmonitor Specials", Data: "MONITOR_unmonitorSpecials"},
-		{Text: "None", Data: "MONITOR_none"},
+func (b *Bot) showAddSeriesMonitor(command *userAddSeries) bool {
+	if b.addSeriesStepSkipped(command, addSeriesStepMonitor) {
+		command.monitor = command.defaults.Monitor
+		return b.showAddSeriesAddOptions(command)
 	}
 
 	var typeKeyboard [][]tgbotapi.InlineKeyboardButton
 	for _, me := range monitorTypes {
 		row := []tgbotapi.InlineKeyboardButton{
-			tgbotapi.NewInlineKeyboardButtonData(me.Text, me.Data),
+			tgbotapi.NewInlineKeyboardButtonData(me.Text, "MONITOR_"+me.Value),
 		}
 		typeKeyboard = append(typeKeyboard, row)
 	}
 
 	var messageText strings.Builder
 	var keyboard tgbotapi.InlineKeyboardMarkup
-	keyboardGoBack := b.createKeyboard(
-		[]string{"\U0001F519"},
-		[]string{AddSeriesMonitorGoBack},
-	)
+	labels := []string{"\U0001F519"}
+	data := []string{AddSeriesMonitorGoBack}
+	if command.bulk == nil && len(command.series.Seasons) > 0 {
+		labels = append([]string{"📋 Choose seasons"}, labels...)
+		data = append([]string{AddSeriesChooseSeasons}, data...)
+	}
+	keyboardGoBack := b.createKeyboard(labels, data)
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, typeKeyboard...)
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, keyboardGoBack.InlineKeyboard...)
 	messageText.WriteString("Select one monitoring option:")
@@ -563,6 +852,55 @@ func (b *Bot) showAddSeriesMonitor(command *userAddSeries) bool {
 
 }
 
+// showAddSeriesSeasons lists the seasons of the series to check the ones to
+// monitor.
+func (b *Bot) showAddSeriesSeasons(command *userAddSeries) bool {
+	seasons := append([]*sonarr.Season(nil), command.series.Seasons...)
+	sort.Slice(seasons, func(i, j int) bool {
+		return seasons[i].SeasonNumber < seasons[j].SeasonNumber
+	})
+	var seasonKeyboardButtons [][]tgbotapi.InlineKeyboardButton
+	for _, season := range seasons {
+		var buttonText string
+		if season.SeasonNumber == 0 {
+			buttonText = "Specials"
+		} else {
+			buttonText = fmt.Sprintf("Season %d", season.SeasonNumber)
+		}
+		if command.selectedSeasons[season.SeasonNumber] {
+			buttonText += " \u2705"
+		}
+		row := []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(buttonText, fmt.Sprintf("SEASON_%d", season.SeasonNumber)),
+		}
+		seasonKeyboardButtons = append(seasonKeyboardButtons, row)
+	}
+	keyboard := tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: seasonKeyboardButtons,
+	}
+	keyboardDoneGoBack := b.createKeyboard(
+		[]string{"Done - Continue", "\U0001F519"},
+		[]string{AddSeriesSeasonsDone, AddSeriesSeasonsGoBack},
+	)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, keyboardDoneGoBack.InlineKeyboard...)
+	b.setAddSeriesState(command.chatID, command)
+	b.sendMessageWithEditAndKeyboard(command, keyboard, "Select the seasons to monitor:")
+	return false
+}
+
+func (b *Bot) handleAddSeriesSelectSeason(update tgbotapi.Update, command *userAddSeries) bool {
+	seasonNumber, err := strconv.Atoi(strings.TrimPrefix(update.CallbackQuery.Data, "SEASON_"))
+	if err != nil {
+		b.logger(command.chatID).Error("Failed to convert season number to integer", "error", err)
+		return false
+	}
+	if command.selectedSeasons == nil {
+		command.selectedSeasons = make(map[int]bool)
+	}
+	command.selectedSeasons[seasonNumber] = !command.selectedSeasons[seasonNumber]
+	return b.showAddSeriesSeasons(command)
+}
+
 func (b *Bot) handleAddSeriesMonitor(update tgbotapi.Update, command *userAddSeries) bool {
 	command.monitor = strings.TrimPrefix(update.CallbackQuery.Data, "MONITOR_")
 	b.setAddSeriesState(command.chatID, command)
@@ -570,6 +908,11 @@ func (b *Bot) handleAddSeriesMonitor(update tgbotapi.Update, command *userAddSer
 }
 
 func (b *Bot) showAddSeriesAddOptions(command *userAddSeries) bool {
+	if b.addSeriesStepSkipped(command, addSeriesStepAddOptions) {
+		command.addSeriesOptions = addSeriesOptions(command.defaults.AddOption, command.monitor)
+		b.setAddSeriesState(command.chatID, command)
+		return b.addSeriesToLibrary(command)
+	}
 	keyboard := b.createKeyboard(
 		[]string{"Add", "Add + search missing", "Add + search missing & cutoff unmet", "Add + search cutoff unmnet", "Cancel, clear command", "\U0001F519"},
 		[]string{AddSeries, AddSeriesMissing, AddSeriesMissingCutOff, AddSeriesCutOff, AddSeriesCancel, AddSeriesAddOptionsGoBack},
@@ -587,46 +930,41 @@ func (b *Bot) showAddSeriesAddOptions(command *userAddSeries) bool {
 }
 
 func (b *Bot) handleAddSeries(update tgbotapi.Update, command *userAddSeries) bool {
-	command.addSeriesOptions = &sonarr.AddSeriesOptions{
-		SearchForMissingEpisodes:     *starr.False(),
-		SearchForCutoffUnmetEpisodes: *starr.False(),
-		Monitor:                      command.monitor,
-	}
+	command.addSeriesOptions = addSeriesOptions("none", command.monitor)
 	b.setAddSeriesState(command.chatID, command)
-	return b.addSeriesToLibrary(update, command)
+	return b.addSeriesToLibrary(command)
 }
 
 func (b *Bot) handleAddSeriesMissing(update tgbotapi.Update, command *userAddSeries) bool {
-	command.addSeriesOptions = &sonarr.AddSeriesOptions{
-		SearchForMissingEpisodes:     *starr.True(),
-		SearchForCutoffUnmetEpisodes: *starr.False(),
-		Monitor:                      command.monitor,
-	}
+	command.addSeriesOptions = addSeriesOptions("missing", command.monitor)
 	b.setAddSeriesState(command.chatID, command)
-	return b.addSeriesToLibrary(update, command)
+	return b.addSeriesToLibrary(command)
 }
 
 func (b *Bot) handleAddSeriesMissingCutoff(update tgbotapi.Update, command *userAddSeries) bool {
-	command.addSeriesOptions = &sonarr.AddSeriesOptions{
-		SearchForMissingEpisodes:     *starr.True(),
-		SearchForCutoffUnmetEpisodes: *starr.True(),
-		Monitor:                      command.monitor,
-	}
+	command.addSeriesOptions = addSeriesOptions("missing-cutoff", command.monitor)
 	b.setAddSeriesState(command.chatID, command)
-	return b.addSeriesToLibrary(update, command)
+	return b.addSeriesToLibrary(command)
 }
 
 func (b *Bot) handleAddSeriesCutOff(update tgbotapi.Update, command *userAddSeries) bool {
-	command.addSeriesOptions = &sonarr.AddSeriesOptions{
-		SearchForMissingEpisodes:     *starr.False(),
-		SearchForCutoffUnmetEpisodes: *starr.True(),
-		Monitor:                      command.monitor,
-	}
+	command.addSeriesOptions = addSeriesOptions("cutoff", command.monitor)
 	b.setAddSeriesState(command.chatID, command)
-	return b.addSeriesToLibrary(update, command)
+	return b.addSeriesToLibrary(command)
+}
+
+// addSeriesOptions returns the options for an add option of /settings and
+// presets: "none", "missing", "cutoff" or "missing-cutoff".
+func addSeriesOptions(search string, monitor string) *sonarr.AddSeriesOptions {
+	return &sonarr.AddSeriesOptions{
+		SearchForMissingEpisodes:     search == "missing" || search == "missing-cutoff",
+		SearchForCutoffUnmetEpisodes: search == "cutoff" || search == "missing-cutoff",
+		Monitor:                      monitor,
+	}
 }
 
-func (b *Bot) addSeriesToLibrary(update tgbotapi.Update, command *userAddSeries) bool {
+// addSeriesInput returns the input adding series with the wizard's answers.
+func addSeriesInput(command *userAddSeries, series *sonarr.Series) *sonarr.AddSeriesInput {
 	var tagIDs []int
 	tagIDs = append(tagIDs, command.selectedTags...)
 
@@ -637,30 +975,54 @@ func (b *Bot) addSeriesToLibrary(update tgbotapi.Update, command *userAddSeries)
 		monitor = *starr.True()
 	}
 
-	addSeriesInput := sonarr.AddSeriesInput{
-		TvdbID:           command.series.TvdbID,
-		Title:            command.series.Title,
+	options := *command.addSeriesOptions
+	var seasons []*sonarr.Season
+	if command.monitor == MonitorSeasons {
+		// Without a monitor option Sonarr monitors the episodes of the
+		// monitored seasons
+		options.Monitor = ""
+		monitor = false
+		for _, season := range series.Seasons {
+			seasons = append(seasons, &sonarr.Season{
+				SeasonNumber: season.SeasonNumber,
+				Monitored:    command.selectedSeasons[season.SeasonNumber],
+			})
+			monitor = monitor || command.selectedSeasons[season.SeasonNumber]
+		}
+	}
+
+	return &sonarr.AddSeriesInput{
+		TvdbID:           series.TvdbID,
+		Title:            series.Title,
 		QualityProfileID: command.profileID,
 		RootFolderPath:   command.rootFolder.Path,
 		SeriesType:       command.seriesType,
-		AddOptions:       command.addSeriesOptions,
+		AddOptions:       &options,
 		Tags:             tagIDs,
 		Monitored:        monitor,
-		SeasonFolder:     *starr.True(),
+		Seasons:          seasons,
+		SeasonFolder:     command.seasonFolder,
 	}
+}
 
+func (b *Bot) addSeriesToLibrary(command *userAddSeries) bool {
+	if command.bulk != nil {
+		return b.addBulkSeriesToLibrary(command)
+	}
 	var messageText string
-	var _, err = b.SonarrServer.AddSeries(&addSeriesInput)
+	var _, err = b.getSonarrServer().AddSeries(addSeriesInput(command, command.series))
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
-		fmt.Println(err)
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
 		b.sendMessage(msg)
 		return false
 	}
-	series, err := b.SonarrServer.GetSeries((command.series.TvdbID))
+	b.audit(command.chatID, "added series", command.series.Title, "tvdb_id", command.series.TvdbID,
+		"quality_profile_id", command.profileID, "root_folder", command.rootFolder.Path, "monitor", command.monitor)
+	series, err := b.getSonarrServer().GetSeries((command.series.TvdbID))
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
-		fmt.Println(err)
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
 		b.sendMessage(msg)
 		return false
 	}
@@ -668,6 +1030,6 @@ func (b *Bot) addSeriesToLibrary(update tgbotapi.Update, command *userAddSeries)
 	messageText = fmt.Sprintf("Series'%v' added\n", series[0].Title)
 
 	b.sendMessageWithEdit(command, messageText)
-	b.clearState(update)
+	b.clearChatState(command.chatID)
 	return true
 }
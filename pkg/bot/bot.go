@@ -1,17 +1,21 @@
 package bot
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"golift.io/starr"
 	"golift.io/starr/sonarr"
 
 	"github.com/woiza/telegram-bot-sonarr/pkg/config"
+	"github.com/woiza/telegram-bot-sonarr/pkg/mediaserver"
+	"github.com/woiza/telegram-bot-sonarr/pkg/metrics"
 )
 
 const (
@@ -21,6 +25,15 @@ const (
 	LibraryFilteredCommand    = "LIBRARYFILTERED"
 	LibrarySeriesEditCommand  = "LIBRARYSERIESEDIT"
 	LibrarySeasonsEditCommand = "LIBRARYSEASONSEDIT"
+	ImportListsCommand        = "IMPORTLISTS"
+	ProvidersCommand          = "PROVIDERS"
+	SystemCommand             = "SYSTEM"
+	TagsCommand               = "TAGS"
+	ProfilesCommand           = "PROFILES"
+	SettingsCommand           = "SETTINGS"
+	BulkAddCommand            = "BULKADD"
+	ImportLibraryCommand      = "IMPORTLIBRARY"
+	DiscoverCommand           = "DISCOVER"
 	CommandsClearedMessage    = "I am not sure what you mean.\nAll commands have been cleared"
 	CommandsCleared           = "All commands have been cleared"
 )
@@ -39,12 +52,23 @@ type userAddSeries struct {
 	addSeriesOptions *sonarr.AddSeriesOptions
 	chatID           int64
 	messageID        int
+	page             int
+	preset           string // chosen with /q --preset
+	seasonFolder     bool
+	selectedSeasons  map[int]bool     // monitored with MonitorSeasons
+	bulk             []*sonarr.Series // added at once by /bulkadd
+	discover         bool             // started from a /discover list
+	// defaults are the user's /settings that exist on the Sonarr server
+	defaults UserSettings
 }
 
 type userDeleteSeries struct {
 	library            map[string]*sonarr.Series
 	seriesForSelection []*sonarr.Series // Series to select from, either whole library or search results
+	lookup             bool             // seriesForSelection are the results of a Sonarr lookup
 	selectedSeries     []*sonarr.Series
+	deleteFiles        bool
+	importExclude      bool
 	chatID             int64
 	messageID          int
 	page               int
@@ -54,6 +78,7 @@ type userLibrary struct {
 	library                []*sonarr.Series
 	libraryFiltered        map[string]*sonarr.Series
 	searchResultsInLibrary []*sonarr.Series
+	lookup                 bool // searchResultsInLibrary are the results of a Sonarr lookup
 	filter                 string
 	qualityProfiles        []*sonarr.QualityProfile
 	selectedQualityProfile int64
@@ -65,26 +90,83 @@ type userLibrary struct {
 	allEpisodeFiles        []*sonarr.EpisodeFile
 	seriesSeasons          map[int]*sonarr.Season
 	selectedSeason         *sonarr.Season
+	deleteFiles            bool
+	importExclude          bool
 	lastSeriesSearch       time.Time
 	lastSeasonSearch       map[int]time.Time
+	watched                mediaserver.Watched // nil without media server
 	chatID                 int64
 	messageID              int
 	page                   int
 }
 
 type Bot struct {
-	Config             *config.Config
-	Bot                *tgbotapi.BotAPI
-	SonarrServer       *sonarr.Sonarr
-	ActiveCommand      map[int64]string
-	AddSeriesStates    map[int64]*userAddSeries
-	DeleteSeriesStates map[int64]*userDeleteSeries
-	LibraryStates      map[int64]*userLibrary
+	// Bot receives updates, Sender sends messages. Both are the same BotAPI
+	// unless Sender is replaced, e.g. in tests.
+	Bot    *tgbotapi.BotAPI
+	Sender TelegramSender
+	// Files downloads documents sent to the bot
+	Files               TelegramFiles
+	ActiveCommand       map[int64]string
+	AddSeriesStates     map[int64]*userAddSeries
+	DeleteSeriesStates  map[int64]*userDeleteSeries
+	LibraryStates       map[int64]*userLibrary
+	ImportListStates    map[int64]*userImportLists
+	ProviderStates      map[int64]*userProviders
+	SystemStates        map[int64]*userSystem
+	TagStates           map[int64]*userTags
+	ProfileStates       map[int64]*userProfiles
+	SettingsStates      map[int64]*userSettingsMenu
+	BulkAddStates       map[int64]*userBulkAdd
+	ImportLibraryStates map[int64]*userImportLibrary
+	DiscoverStates      map[int64]*userDiscover
+	// Discovery finds series for /discover, nil if not configured
+	Discovery DiscoveryProvider
+	// MediaServer provides the watch status in /library, nil if not configured
+	MediaServer MediaServer
+	// AuditLog records destructive actions, defaults to slog.Default()
+	AuditLog *slog.Logger
+	// Config and Sonarr server can be swapped at runtime, see Reload
+	config       *config.Config
+	sonarrServer SonarrClient
+	// Times of the last successful long poll and of the last received update
+	started        time.Time
+	lastPoll       atomic.Value
+	lastUpdate     atomic.Value
+	updateContexts map[int64]*updateContext
+	// Deletions waiting for their grace period, see scheduleDelete
+	pendingDeletes      map[int]*pendingDelete
+	textInputs          map[int64]*textInput
+	lastPendingDeleteID int
+	// Set by CancelPendingDeletes, runningDeletes tracks their goroutines
+	pendingDeletesCancelled bool
+	runningDeletes          sync.WaitGroup
+	// Latest report of the cleanup rules, see runCleanupRules
+	cleanupReport       *cleanupReport
+	lastCleanupReportID int
+	// Defaults of the add series wizard per user, see LoadSettings
+	userSettings map[int64]UserSettings
+	settingsFile string
 	// Mutexes for synchronization
-	muActiveCommand      sync.Mutex
-	muAddSeriesStates    sync.Mutex
-	muDeleteSeriesStates sync.Mutex
-	muLibraryStates      sync.Mutex
+	muConfig              sync.RWMutex
+	muCleanupReport       sync.Mutex
+	muUpdateContexts      sync.Mutex
+	muActiveCommand       sync.Mutex
+	muAddSeriesStates     sync.Mutex
+	muDeleteSeriesStates  sync.Mutex
+	muLibraryStates       sync.Mutex
+	muImportListStates    sync.Mutex
+	muProviderStates      sync.Mutex
+	muSystemStates        sync.Mutex
+	muTagStates           sync.Mutex
+	muProfileStates       sync.Mutex
+	muSettingsStates      sync.Mutex
+	muBulkAddStates       sync.Mutex
+	muImportLibraryStates sync.Mutex
+	muDiscoverStates      sync.Mutex
+	muPendingDeletes      sync.Mutex
+	muTextInputs          sync.Mutex
+	muUserSettings        sync.Mutex
 }
 
 type Command interface {
@@ -119,32 +201,131 @@ func (c *userAddSeries) GetMessageID() int {
 	return c.messageID
 }
 
-func New(config *config.Config, botAPI *tgbotapi.BotAPI, sonarrServer *sonarr.Sonarr) *Bot {
-	return &Bot{
-		Config:             config,
-		Bot:                botAPI,
-		SonarrServer:       sonarrServer,
-		ActiveCommand:      make(map[int64]string),
-		AddSeriesStates:    make(map[int64]*userAddSeries),
-		DeleteSeriesStates: make(map[int64]*userDeleteSeries),
-		LibraryStates:      make(map[int64]*userLibrary),
-	}
+// Implement the interface for userImportLists
+func (c *userImportLists) GetChatID() int64 {
+	return c.chatID
+}
+
+func (c *userImportLists) GetMessageID() int {
+	return c.messageID
+}
+
+// Implement the interface for userProviders
+func (c *userProviders) GetChatID() int64 {
+	return c.chatID
+}
+
+func (c *userProviders) GetMessageID() int {
+	return c.messageID
+}
+
+// Implement the interface for userSystem
+func (c *userSystem) GetChatID() int64 {
+	return c.chatID
+}
+
+func (c *userSystem) GetMessageID() int {
+	return c.messageID
+}
+
+// Implement the interface for userTags
+func (c *userTags) GetChatID() int64 {
+	return c.chatID
 }
 
-func (b *Bot) HandleUpdates(updates <-chan tgbotapi.Update) {
-	for update := range updates {
-		b.HandleUpdate(update)
+func (c *userTags) GetMessageID() int {
+	return c.messageID
+}
+
+// Implement the interface for userProfiles
+func (c *userProfiles) GetChatID() int64 {
+	return c.chatID
+}
+
+func (c *userProfiles) GetMessageID() int {
+	return c.messageID
+}
+
+// Implement the interface for userSettingsMenu
+func (c *userSettingsMenu) GetChatID() int64 {
+	return c.chatID
+}
+
+func (c *userSettingsMenu) GetMessageID() int {
+	return c.messageID
+}
+
+// Implement the interface for userBulkAdd
+func (c *userBulkAdd) GetChatID() int64 {
+	return c.chatID
+}
+
+func (c *userBulkAdd) GetMessageID() int {
+	return c.messageID
+}
+
+// Implement the interface for userImportLibrary
+func (c *userImportLibrary) GetChatID() int64 {
+	return c.chatID
+}
+
+func (c *userImportLibrary) GetMessageID() int {
+	return c.messageID
+}
+
+// Implement the interface for userDiscover
+func (c *userDiscover) GetChatID() int64 {
+	return c.chatID
+}
+
+func (c *userDiscover) GetMessageID() int {
+	return c.messageID
+}
+
+func New(config *config.Config, botAPI *tgbotapi.BotAPI, sonarrServer SonarrClient) *Bot {
+	b := &Bot{
+		config:              config,
+		Bot:                 botAPI,
+		sonarrServer:        sonarrServer,
+		ActiveCommand:       make(map[int64]string),
+		AddSeriesStates:     make(map[int64]*userAddSeries),
+		DeleteSeriesStates:  make(map[int64]*userDeleteSeries),
+		LibraryStates:       make(map[int64]*userLibrary),
+		ImportListStates:    make(map[int64]*userImportLists),
+		ProviderStates:      make(map[int64]*userProviders),
+		SystemStates:        make(map[int64]*userSystem),
+		TagStates:           make(map[int64]*userTags),
+		ProfileStates:       make(map[int64]*userProfiles),
+		SettingsStates:      make(map[int64]*userSettingsMenu),
+		BulkAddStates:       make(map[int64]*userBulkAdd),
+		ImportLibraryStates: make(map[int64]*userImportLibrary),
+		DiscoverStates:      make(map[int64]*userDiscover),
+		AuditLog:            slog.Default(),
+		updateContexts:      make(map[int64]*updateContext),
+		pendingDeletes:      make(map[int]*pendingDelete),
+		textInputs:          make(map[int64]*textInput),
+		userSettings:        make(map[int64]UserSettings),
+		started:             time.Now(),
 	}
+	if botAPI != nil {
+		b.Sender = botAPI
+		b.Files = botAPIFiles{api: botAPI}
+	}
+	return b
 }
 
 func (b *Bot) HandleUpdate(update tgbotapi.Update) {
+	b.lastUpdate.Store(time.Now())
 	chatID, err := b.getChatID(update)
 	if err != nil {
-		fmt.Printf("Cannot handle update: %v", err)
+		slog.Warn("Cannot handle update", "update_id", update.UpdateID, "error", err)
 		return
 	}
+	b.setUpdateContext(chatID, update)
+	defer b.clearUpdateContext(chatID)
 
-	if update.Message != nil && !b.Config.AllowedChatIDs[chatID] {
+	if update.Message != nil && !b.getConfig().AllowedChatIDs[chatID] {
+		b.logger(chatID).Warn("Access denied")
 		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Access denied. You are not authorized.")
 		b.sendMessage(msg)
 		return
@@ -153,6 +334,18 @@ func (b *Bot) HandleUpdate(update tgbotapi.Update) {
 	activeCommand, _ := b.getActiveCommand(chatID)
 
 	if update.CallbackQuery != nil {
+		metrics.Callbacks.Inc(callbackPrefix(update.CallbackQuery.Data))
+		// Undo works independently of the active command
+		if strings.HasPrefix(update.CallbackQuery.Data, UndoDelete) {
+			b.handleUndoDelete(update)
+			return
+		}
+		if strings.HasPrefix(update.CallbackQuery.Data, CleanupApprove) || strings.HasPrefix(update.CallbackQuery.Data, CleanupDismiss) {
+			b.handleCleanupCallback(update)
+			return
+		}
+		// A pressed button answers the conversation instead of a pending prompt
+		b.cancelTextInput(chatID)
 		switch activeCommand {
 		case AddSeriesCommand:
 			if !b.addSeries(update) {
@@ -178,6 +371,42 @@ func (b *Bot) HandleUpdate(update tgbotapi.Update) {
 			if !b.librarySeasonEdit(update) {
 				return
 			}
+		case ImportListsCommand:
+			if !b.importLists(update) {
+				return
+			}
+		case ProvidersCommand:
+			if !b.providers(update) {
+				return
+			}
+		case SystemCommand:
+			if !b.system(update) {
+				return
+			}
+		case TagsCommand:
+			if !b.tags(update) {
+				return
+			}
+		case ProfilesCommand:
+			if !b.qualityProfiles(update) {
+				return
+			}
+		case SettingsCommand:
+			if !b.settings(update) {
+				return
+			}
+		case BulkAddCommand:
+			if !b.bulkAdd(update) {
+				return
+			}
+		case ImportLibraryCommand:
+			if !b.importLibrary(update) {
+				return
+			}
+		case DiscoverCommand:
+			if !b.discover(update) {
+				return
+			}
 		default:
 			b.clearState(update)
 			msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, CommandsClearedMessage)
@@ -188,8 +417,20 @@ func (b *Bot) HandleUpdate(update tgbotapi.Update) {
 		return
 	}
 
-	// If no command was passed, handle a search command.
-	if update.Message.Entities == nil {
+	// Documents are library exports sent for /importlibrary
+	if update.Message.Document != nil {
+		b.cancelTextInput(chatID)
+		b.handleDocument(update)
+		return
+	}
+
+	if !update.Message.IsCommand() && b.handleTextInput(update) {
+		return
+	}
+
+	// If no command was passed, handle a search command. Links to series
+	// come with url entities.
+	if !update.Message.IsCommand() {
 		update.Message.Text = fmt.Sprintf("/q %s", update.Message.Text)
 		update.Message.Entities = []tgbotapi.MessageEntity{{
 			Type:   "bot_command",
@@ -198,16 +439,23 @@ func (b *Bot) HandleUpdate(update tgbotapi.Update) {
 	}
 
 	if update.Message.IsCommand() {
-		b.handleCommand(update, b.SonarrServer)
+		b.cancelTextInput(chatID)
+		b.handleCommand(update, b.getSonarrServer())
 	}
 }
 
 func (b *Bot) clearState(update tgbotapi.Update) {
 	chatID, err := b.getChatID(update)
 	if err != nil {
-		fmt.Printf("Cannot clear state: %v", err)
+		slog.Warn("Cannot clear state", "update_id", update.UpdateID, "error", err)
 		return
 	}
+	b.clearChatState(chatID)
+}
+
+// clearChatState ends the conversation of the chat.
+func (b *Bot) clearChatState(chatID int64) {
+	b.cancelTextInput(chatID)
 
 	// Safely clear states using mutexes
 	b.muActiveCommand.Lock()
@@ -229,6 +477,51 @@ func (b *Bot) clearState(update tgbotapi.Update) {
 	defer b.muLibraryStates.Unlock()
 
 	delete(b.LibraryStates, chatID)
+
+	b.muImportListStates.Lock()
+	defer b.muImportListStates.Unlock()
+
+	delete(b.ImportListStates, chatID)
+
+	b.muProviderStates.Lock()
+	defer b.muProviderStates.Unlock()
+
+	delete(b.ProviderStates, chatID)
+
+	b.muSystemStates.Lock()
+	defer b.muSystemStates.Unlock()
+
+	delete(b.SystemStates, chatID)
+
+	b.muTagStates.Lock()
+	defer b.muTagStates.Unlock()
+
+	delete(b.TagStates, chatID)
+
+	b.muProfileStates.Lock()
+	defer b.muProfileStates.Unlock()
+
+	delete(b.ProfileStates, chatID)
+
+	b.muSettingsStates.Lock()
+	defer b.muSettingsStates.Unlock()
+
+	delete(b.SettingsStates, chatID)
+
+	b.muBulkAddStates.Lock()
+	defer b.muBulkAddStates.Unlock()
+
+	delete(b.BulkAddStates, chatID)
+
+	b.muImportLibraryStates.Lock()
+	defer b.muImportLibraryStates.Unlock()
+
+	delete(b.ImportLibraryStates, chatID)
+
+	b.muDiscoverStates.Lock()
+	defer b.muDiscoverStates.Unlock()
+
+	delete(b.DiscoverStates, chatID)
 }
 
 func (b *Bot) getChatID(update tgbotapi.Update) (int64, error) {
@@ -245,6 +538,35 @@ func (b *Bot) getChatID(update tgbotapi.Update) (int64, error) {
 	return chatID, nil
 }
 
+// Reload atomically replaces the configuration and the Sonarr server.
+// Ongoing conversations are kept.
+func (b *Bot) Reload(config *config.Config, sonarrServer SonarrClient) {
+	b.muConfig.Lock()
+	defer b.muConfig.Unlock()
+	b.config = config
+	b.sonarrServer = sonarrServer
+}
+
+func (b *Bot) getConfig() *config.Config {
+	b.muConfig.RLock()
+	defer b.muConfig.RUnlock()
+	return b.config
+}
+
+func (b *Bot) getSonarrServer() SonarrClient {
+	b.muConfig.RLock()
+	defer b.muConfig.RUnlock()
+	return b.sonarrServer
+}
+
+// NotifyAdmins sends a plain text message to all admin chats.
+func (b *Bot) NotifyAdmins(text string) {
+	for chatID := range b.getConfig().AdminChatIDs {
+		msg := tgbotapi.NewMessage(chatID, text)
+		b.sendMessage(msg)
+	}
+}
+
 func (b *Bot) getActiveCommand(chatID int64) (string, bool) {
 	b.muActiveCommand.Lock()
 	defer b.muActiveCommand.Unlock()
@@ -297,10 +619,130 @@ func (b *Bot) setLibraryState(chatID int64, state *userLibrary) {
 	b.LibraryStates[chatID] = state
 }
 
+func (b *Bot) getImportListState(chatID int64) (*userImportLists, bool) {
+	b.muImportListStates.Lock()
+	defer b.muImportListStates.Unlock()
+	state, exists := b.ImportListStates[chatID]
+	return state, exists
+}
+
+func (b *Bot) setImportListState(chatID int64, state *userImportLists) {
+	b.muImportListStates.Lock()
+	defer b.muImportListStates.Unlock()
+	b.ImportListStates[chatID] = state
+}
+
+func (b *Bot) getProviderState(chatID int64) (*userProviders, bool) {
+	b.muProviderStates.Lock()
+	defer b.muProviderStates.Unlock()
+	state, exists := b.ProviderStates[chatID]
+	return state, exists
+}
+
+func (b *Bot) setProviderState(chatID int64, state *userProviders) {
+	b.muProviderStates.Lock()
+	defer b.muProviderStates.Unlock()
+	b.ProviderStates[chatID] = state
+}
+
+func (b *Bot) getSystemState(chatID int64) (*userSystem, bool) {
+	b.muSystemStates.Lock()
+	defer b.muSystemStates.Unlock()
+	state, exists := b.SystemStates[chatID]
+	return state, exists
+}
+
+func (b *Bot) setSystemState(chatID int64, state *userSystem) {
+	b.muSystemStates.Lock()
+	defer b.muSystemStates.Unlock()
+	b.SystemStates[chatID] = state
+}
+
+func (b *Bot) getTagState(chatID int64) (*userTags, bool) {
+	b.muTagStates.Lock()
+	defer b.muTagStates.Unlock()
+	state, exists := b.TagStates[chatID]
+	return state, exists
+}
+
+func (b *Bot) setTagState(chatID int64, state *userTags) {
+	b.muTagStates.Lock()
+	defer b.muTagStates.Unlock()
+	b.TagStates[chatID] = state
+}
+
+func (b *Bot) getProfileState(chatID int64) (*userProfiles, bool) {
+	b.muProfileStates.Lock()
+	defer b.muProfileStates.Unlock()
+	state, exists := b.ProfileStates[chatID]
+	return state, exists
+}
+
+func (b *Bot) setProfileState(chatID int64, state *userProfiles) {
+	b.muProfileStates.Lock()
+	defer b.muProfileStates.Unlock()
+	b.ProfileStates[chatID] = state
+}
+
+func (b *Bot) getSettingsState(chatID int64) (*userSettingsMenu, bool) {
+	b.muSettingsStates.Lock()
+	defer b.muSettingsStates.Unlock()
+	state, exists := b.SettingsStates[chatID]
+	return state, exists
+}
+
+func (b *Bot) setSettingsState(chatID int64, state *userSettingsMenu) {
+	b.muSettingsStates.Lock()
+	defer b.muSettingsStates.Unlock()
+	b.SettingsStates[chatID] = state
+}
+
+func (b *Bot) getBulkAddState(chatID int64) (*userBulkAdd, bool) {
+	b.muBulkAddStates.Lock()
+	defer b.muBulkAddStates.Unlock()
+	state, exists := b.BulkAddStates[chatID]
+	return state, exists
+}
+
+func (b *Bot) setBulkAddState(chatID int64, state *userBulkAdd) {
+	b.muBulkAddStates.Lock()
+	defer b.muBulkAddStates.Unlock()
+	b.BulkAddStates[chatID] = state
+}
+
+func (b *Bot) getImportLibraryState(chatID int64) (*userImportLibrary, bool) {
+	b.muImportLibraryStates.Lock()
+	defer b.muImportLibraryStates.Unlock()
+	state, exists := b.ImportLibraryStates[chatID]
+	return state, exists
+}
+
+func (b *Bot) setImportLibraryState(chatID int64, state *userImportLibrary) {
+	b.muImportLibraryStates.Lock()
+	defer b.muImportLibraryStates.Unlock()
+	b.ImportLibraryStates[chatID] = state
+}
+
+func (b *Bot) getDiscoverState(chatID int64) (*userDiscover, bool) {
+	b.muDiscoverStates.Lock()
+	defer b.muDiscoverStates.Unlock()
+	state, exists := b.DiscoverStates[chatID]
+	return state, exists
+}
+
+func (b *Bot) setDiscoverState(chatID int64, state *userDiscover) {
+	b.muDiscoverStates.Lock()
+	defer b.muDiscoverStates.Unlock()
+	b.DiscoverStates[chatID] = state
+}
+
 func (b *Bot) sendMessage(msg tgbotapi.Chattable) (tgbotapi.Message, error) {
-	message, err := b.Bot.Send(msg)
+	message, err := b.Sender.Send(msg)
 	if err != nil {
-		log.Println("Error sending message:", err)
+		metrics.MessagesFailed.Inc()
+		b.logger(chattableChatID(msg)).Error("Error sending message", "error", telegramError(err))
+	} else {
+		metrics.MessagesSent.Inc()
 	}
 	return message, err
 }
@@ -313,7 +755,7 @@ func (b *Bot) sendMessageWithEdit(command Command, text string) {
 	)
 	_, err := b.sendMessage(editMsg)
 	if err != nil {
-		log.Printf("Error editing message: %v", err)
+		b.logger(command.GetChatID()).Error("Error editing message", "error", err)
 	}
 }
 
@@ -326,11 +768,25 @@ func (b *Bot) sendMessageWithEditAndKeyboard(command Command, keyboard tgbotapi.
 	)
 	_, err := b.sendMessage(editMsg)
 	if err != nil {
-		log.Printf("Error editing message with keyboard: %v", err)
+		b.logger(command.GetChatID()).Error("Error editing message with keyboard", "error", err)
+	}
+}
+
+func chattableChatID(msg tgbotapi.Chattable) int64 {
+	switch m := msg.(type) {
+	case tgbotapi.MessageConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return m.ChatID
+	case tgbotapi.DocumentConfig:
+		return m.ChatID
 	}
+	return 0
 }
 
-func prettyPrint(i interface{}) string {
-	s, _ := json.MarshalIndent(i, "", "\t")
-	return string(s)
+// callbackPrefix strips IDs from callback data, e.g. "TVDBID_123" becomes "TVDBID_"
+func callbackPrefix(data string) string {
+	return strings.TrimRightFunc(data, unicode.IsDigit)
 }
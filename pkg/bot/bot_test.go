@@ -0,0 +1,1044 @@
+package bot_test
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/bot"
+	"github.com/woiza/telegram-bot-sonarr/pkg/bottest"
+	"github.com/woiza/telegram-bot-sonarr/pkg/config"
+	"github.com/woiza/telegram-bot-sonarr/pkg/discovery"
+	"github.com/woiza/telegram-bot-sonarr/pkg/sonarrapi"
+)
+
+const chatID = 42
+
+type testBot struct {
+	*bot.Bot
+	sonarr   *bottest.Sonarr
+	telegram *bottest.Telegram
+	config   *config.Config
+	t        *testing.T
+}
+
+func newTestBot(t *testing.T) *testBot {
+	t.Helper()
+	fakeSonarr := bottest.NewSonarr()
+	fakeSonarr.Catalog = []*sonarr.Series{
+		{TvdbID: 81189, Title: "Breaking Bad", Year: 2008, Status: "ended"},
+		{TvdbID: 121361, Title: "Game of Thrones", Year: 2011, Status: "ended"},
+		{TvdbID: 305288, Title: "Stranger Things", Year: 2016, Status: "continuing"},
+	}
+	cfg := &config.Config{
+		AllowedChatIDs: map[int64]bool{chatID: true},
+		AdminChatIDs:   map[int64]bool{chatID: true},
+		MaxItems:       10,
+	}
+	b := bot.New(cfg, nil, fakeSonarr)
+	fakeTelegram := bottest.NewTelegram()
+	b.Sender = fakeTelegram
+	b.Files = fakeTelegram
+	b.AuditLog = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return &testBot{Bot: b, sonarr: fakeSonarr, telegram: fakeTelegram, config: cfg, t: t}
+}
+
+// addToLibrary adds a catalog series to the fake library directly.
+func (tb *testBot) addToLibrary(tvdbID int64, seasons ...*sonarr.Season) *sonarr.Series {
+	tb.t.Helper()
+	for _, series := range tb.sonarr.Catalog {
+		if series.TvdbID == tvdbID {
+			added, err := tb.sonarr.AddSeries(&sonarr.AddSeriesInput{
+				TvdbID:           series.TvdbID,
+				Title:            series.Title,
+				QualityProfileID: 1,
+				RootFolderPath:   "/tv",
+				Monitored:        true,
+				Seasons:          seasons,
+			})
+			if err != nil {
+				tb.t.Fatal(err)
+			}
+			return added
+		}
+	}
+	tb.t.Fatalf("series %d not in catalog", tvdbID)
+	return nil
+}
+
+func (tb *testBot) command(text string) {
+	tb.HandleUpdate(bottest.Command(chatID, text))
+}
+
+// text sends text without a command, e.g. a search or a prompted answer.
+func (tb *testBot) text(text string) {
+	tb.HandleUpdate(bottest.Text(chatID, text))
+}
+
+// press presses the button labelled label on the latest message.
+func (tb *testBot) press(label string) {
+	tb.t.Helper()
+	msg := tb.telegram.Last(chatID)
+	data := msg.Button(label)
+	if data == "" {
+		tb.t.Fatalf("no button %q in message %q", label, msg.Text)
+	}
+	tb.HandleUpdate(bottest.Callback(chatID, msg.MessageID, data))
+}
+
+func (tb *testBot) expectText(substr string) {
+	tb.t.Helper()
+	msg := tb.telegram.Last(chatID)
+	if msg == nil || !strings.Contains(msg.Text, substr) {
+		tb.t.Fatalf("expected last message to contain %q, got %+v", substr, msg)
+	}
+}
+
+// waitForText waits for the last message to contain substr, e.g. after a
+// scheduled deletion.
+func (tb *testBot) waitForText(substr string) {
+	tb.t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if msg := tb.telegram.Last(chatID); msg != nil && strings.Contains(msg.Text, substr) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	tb.expectText(substr)
+}
+
+func TestAddSeries(t *testing.T) {
+	tb := newTestBot(t)
+
+	tb.command("/q breaking")
+	tb.expectText("Series found")
+	tb.press("Breaking Bad")
+	tb.expectText("Is this the correct series?")
+	tb.press("Yes, add this series")
+	tb.press("Standard")
+	tb.expectText("Select one monitoring option")
+	tb.press("Future Episodes")
+	tb.press("Add + search missing")
+	tb.expectText("Breaking Bad")
+
+	series := tb.sonarr.FindSeries(81189)
+	if series == nil {
+		t.Fatal("series was not added")
+	}
+	if series.RootFolderPath != "/tv" || series.QualityProfileID != 1 || series.SeriesType != "standard" {
+		t.Errorf("unexpected series settings: %+v", series)
+	}
+	if _, active := tb.ActiveCommand[chatID]; active {
+		t.Error("active command was not cleared")
+	}
+}
+
+func TestAddSeriesAlreadyInLibrary(t *testing.T) {
+	tb := newTestBot(t)
+	tb.addToLibrary(81189)
+
+	tb.command("/q breaking")
+	tb.press("Breaking Bad")
+	tb.press("Yes, add this series")
+	tb.expectText("Series already in library")
+}
+
+func TestAddSeriesNoResults(t *testing.T) {
+	tb := newTestBot(t)
+
+	tb.command("/q unknown")
+	tb.expectText("No series found")
+}
+
+func TestSearchWithoutCommand(t *testing.T) {
+	tb := newTestBot(t)
+
+	tb.HandleUpdate(bottest.Text(chatID, "thrones"))
+	tb.expectText("Series found")
+}
+
+func TestDeleteSeries(t *testing.T) {
+	tb := newTestBot(t)
+	breakingBad := tb.addToLibrary(81189)
+	tb.addToLibrary(121361)
+
+	tb.command("/delete")
+	tb.press("Breaking Bad")
+	tb.press("Submit")
+	tb.expectText("Do you want to delete the following series")
+	tb.press("Yes, delete this series")
+	tb.expectText("Deleted Series")
+
+	if len(tb.sonarr.Deleted) != 1 || tb.sonarr.Deleted[0].ID != breakingBad.ID || !tb.sonarr.Deleted[0].DeleteFiles {
+		t.Errorf("unexpected deletions: %+v", tb.sonarr.Deleted)
+	}
+	if tb.sonarr.FindSeries(121361) == nil {
+		t.Error("other series was deleted")
+	}
+}
+
+func TestDeleteSeriesOptions(t *testing.T) {
+	tb := newTestBot(t)
+	tb.addToLibrary(81189,
+		&sonarr.Season{SeasonNumber: 1, Statistics: &sonarr.Statistics{SizeOnDisk: 1 << 30}},
+		&sonarr.Season{SeasonNumber: 2, Statistics: &sonarr.Statistics{SizeOnDisk: 1 << 30}})
+
+	tb.command("/delete breaking")
+	tb.expectText(`Files: deleted, 2\.0 GB reclaimed`)
+	tb.press("Delete files")
+	tb.expectText("Files: kept on disk")
+	tb.press("Add import list exclusion")
+	tb.expectText("Import list exclusion: added")
+	tb.press("Yes, delete this series")
+
+	if len(tb.sonarr.Deleted) != 1 || tb.sonarr.Deleted[0].DeleteFiles || !tb.sonarr.Deleted[0].ImportExclude {
+		t.Errorf("unexpected deletions: %+v", tb.sonarr.Deleted)
+	}
+}
+
+func TestDeleteSeriesCancel(t *testing.T) {
+	tb := newTestBot(t)
+	tb.addToLibrary(81189)
+
+	tb.command("/delete breaking")
+	tb.press("Cancel")
+	tb.expectText(bot.CommandsCleared)
+
+	if len(tb.sonarr.Deleted) != 0 {
+		t.Errorf("unexpected deletions: %+v", tb.sonarr.Deleted)
+	}
+}
+
+func TestDeleteSeriesAfterGracePeriod(t *testing.T) {
+	tb := newTestBot(t)
+	tb.config.DeleteGracePeriod = 20 * time.Millisecond
+	tb.addToLibrary(81189)
+
+	tb.command("/delete breaking")
+	tb.press("Yes, delete this series")
+	tb.expectText("Deleting in")
+	tb.waitForText("Deleted Series")
+
+	if len(tb.sonarr.Deleted) != 1 {
+		t.Errorf("unexpected deletions: %+v", tb.sonarr.Deleted)
+	}
+}
+
+func TestDeleteSeriesUndo(t *testing.T) {
+	tb := newTestBot(t)
+	tb.config.DeleteGracePeriod = time.Minute
+	tb.addToLibrary(81189)
+
+	tb.command("/library breaking")
+	tb.press("Delete")
+	tb.press("Yes")
+	tb.expectText("Deleting in 1m0s")
+	tb.press("Undo")
+	tb.waitForText("Deletion undone")
+
+	if len(tb.sonarr.Deleted) != 0 || tb.sonarr.FindSeries(81189) == nil {
+		t.Errorf("series was deleted: %+v", tb.sonarr.Deleted)
+	}
+}
+
+func TestDeleteSeriesCancelledOnShutdown(t *testing.T) {
+	tb := newTestBot(t)
+	tb.config.DeleteGracePeriod = time.Minute
+	tb.addToLibrary(81189)
+
+	tb.command("/library breaking")
+	tb.press("Delete")
+	tb.press("Yes")
+	tb.expectText("Deleting in 1m0s")
+	tb.CancelPendingDeletes()
+	tb.expectText("Deletion cancelled")
+
+	if len(tb.sonarr.Deleted) != 0 || tb.sonarr.FindSeries(81189) == nil {
+		t.Errorf("series was deleted: %+v", tb.sonarr.Deleted)
+	}
+}
+
+func TestLibraryDeleteSeasonFiles(t *testing.T) {
+	tb := newTestBot(t)
+	series := tb.addToLibrary(305288,
+		&sonarr.Season{SeasonNumber: 1, Monitored: true},
+		&sonarr.Season{SeasonNumber: 2, Monitored: true})
+	tb.sonarr.EpisodeFiles = []*sonarr.EpisodeFile{
+		{ID: 1, SeriesID: series.ID, SeasonNumber: 1},
+		{ID: 2, SeriesID: series.ID, SeasonNumber: 2},
+	}
+
+	tb.command("/library stranger")
+	tb.press("Seasons")
+	tb.press("Season 1")
+	tb.press("Delete")
+
+	files, _ := tb.sonarr.GetSeriesEpisodeFiles(series.ID)
+	if len(files) != 1 || files[0].SeasonNumber != 2 {
+		t.Errorf("unexpected remaining files: %+v", files)
+	}
+	if season := tb.sonarr.FindSeries(305288).Seasons[0]; season.Monitored {
+		t.Error("season is still monitored")
+	}
+}
+
+func TestLibraryUnmonitorSeries(t *testing.T) {
+	tb := newTestBot(t)
+	tb.addToLibrary(305288, &sonarr.Season{SeasonNumber: 1, Monitored: true})
+
+	tb.command("/library stranger")
+	tb.expectText("Stranger Things")
+	tb.press("Unmonitor")
+
+	if series := tb.sonarr.FindSeries(305288); series.Monitored {
+		t.Error("series is still monitored")
+	}
+}
+
+func TestLibrarySearchSeries(t *testing.T) {
+	tb := newTestBot(t)
+	series := tb.addToLibrary(305288)
+
+	tb.command("/library")
+	tb.press("All Series")
+	tb.press("Stranger Things")
+	tb.press("Search")
+
+	if len(tb.sonarr.Commands) != 1 || tb.sonarr.Commands[0].Name != "SeriesSearch" || tb.sonarr.Commands[0].SeriesID != series.ID {
+		t.Errorf("unexpected commands: %+v", tb.sonarr.Commands)
+	}
+}
+
+func TestUnknownCallbackClearsCommands(t *testing.T) {
+	tb := newTestBot(t)
+
+	tb.HandleUpdate(bottest.Callback(chatID, 1, bot.DeleteSeriesYes))
+	tb.expectText(bot.CommandsClearedMessage)
+}
+
+func TestAccessDenied(t *testing.T) {
+	tb := newTestBot(t)
+
+	tb.HandleUpdate(bottest.Command(7, "/q breaking"))
+	msg := tb.telegram.Last(7)
+	if msg == nil || !strings.Contains(msg.Text, "Access denied") {
+		t.Fatalf("expected access denied, got %+v", msg)
+	}
+	if len(tb.sonarr.Commands) != 0 || tb.telegram.Last(chatID) != nil {
+		t.Error("unauthorized update was handled")
+	}
+}
+
+func TestImportLists(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.ImportLists = []*sonarr.ImportListOutput{
+		{ID: 1, Name: "Trakt Trending", ImplementationName: "Trakt Popular List", EnableAutomaticAdd: true, ShouldMonitor: "all", QualityProfileID: 1, RootFolderPath: "/tv"},
+	}
+	tb.sonarr.Exclusions = []*sonarr.Exclusion{
+		{ID: 1, TVDBID: 81189, Title: "Breaking Bad"},
+		{ID: 2, TVDBID: 121361, Title: "Game of Thrones"},
+	}
+
+	tb.command("/lists")
+	tb.expectText("Trakt Trending")
+	tb.press("Trakt Trending")
+	tb.press("Disable automatic add")
+	tb.expectText("Automatic add: ❌")
+	if lists, _ := tb.sonarr.GetImportLists(); lists[0].EnableAutomaticAdd {
+		t.Error("automatic add is still enabled")
+	}
+
+	tb.press("Sync lists now")
+	if len(tb.sonarr.Commands) != 1 || tb.sonarr.Commands[0].Name != "ImportListSync" {
+		t.Errorf("unexpected commands: %+v", tb.sonarr.Commands)
+	}
+}
+
+func TestImportListExclusions(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.Exclusions = []*sonarr.Exclusion{
+		{ID: 1, TVDBID: 81189, Title: "Breaking Bad"},
+		{ID: 2, TVDBID: 121361, Title: "Game of Thrones"},
+	}
+
+	tb.command("/exclusions thrones")
+	tb.expectText("1 exclusions")
+	tb.press("Game of Thrones")
+	tb.press("Yes, remove exclusion")
+	tb.expectText("No exclusions found")
+
+	exclusions, _ := tb.sonarr.GetExclusions()
+	if len(exclusions) != 1 || exclusions[0].Title != "Breaking Bad" {
+		t.Errorf("unexpected exclusions: %+v", exclusions)
+	}
+}
+
+func TestIndexers(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.Indexers = []*sonarr.IndexerOutput{
+		{ID: 1, Name: "NZBgeek", ImplementationName: "Newznab", Protocol: "usenet", Priority: 25, EnableRss: true, EnableAutomaticSearch: true, EnableInteractiveSearch: true, SupportsRss: true, SupportsSearch: true},
+		{ID: 2, Name: "Nyaa", ImplementationName: "Torznab", Protocol: "torrent", Priority: 30, EnableRss: true, SupportsRss: true, SupportsSearch: true},
+	}
+	tb.sonarr.IndexerStatus = []*sonarrapi.ProviderStatus{
+		{ProviderID: 2, EscalationLevel: 2, InitialFailure: time.Now().Add(-time.Hour), DisabledTill: time.Now().Add(time.Hour)},
+	}
+	tb.sonarr.IndexerTestFailures = map[int64]string{2: "Unable to connect to indexer"}
+
+	tb.command("/indexers")
+	tb.expectText("temporarily disabled until")
+	tb.press("Test all")
+	tb.expectText("Unable to connect to indexer")
+	tb.expectText("✅ passed")
+
+	tb.press("NZBgeek")
+	tb.press("Disable RSS")
+	tb.expectText("RSS: ❌")
+	indexers, _ := tb.sonarr.GetIndexers()
+	if indexers[0].EnableRss || !indexers[0].EnableAutomaticSearch || !indexers[0].EnableInteractiveSearch {
+		t.Errorf("unexpected indexer flags: %+v", indexers[0])
+	}
+	tb.press("Enable RSS")
+	tb.expectText("RSS: ✅")
+}
+
+func TestDownloadClients(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.DownloadClients = []*sonarr.DownloadClientOutput{
+		{ID: 1, Name: "SABnzbd", ImplementationName: "SABnzbd", Protocol: "usenet", Priority: 1},
+	}
+
+	tb.command("/clients")
+	tb.press("SABnzbd")
+	tb.press("Enable")
+	tb.expectText("Enabled: ✅")
+	tb.press("Test")
+	tb.expectText("✅ passed")
+	if clients, _ := tb.sonarr.GetDownloadClients(); !clients[0].Enable {
+		t.Error("download client is still disabled")
+	}
+}
+
+func TestSystem(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.Status.Branch = "main"
+	tb.sonarr.Status.StartTime = time.Now().Add(-26 * time.Hour)
+	tb.sonarr.Tasks = []*sonarrapi.Task{
+		{ID: 1, Name: "Refresh Series", TaskName: "RefreshSeries", Interval: 720, NextExecution: time.Now().Add(time.Hour)},
+	}
+
+	tb.command("/system")
+	tb.expectText("Branch: main")
+	tb.expectText("up 1d 2h")
+	tb.press("Tasks")
+	tb.expectText("Refresh Series")
+	tb.press("Run Refresh Series")
+	if len(tb.sonarr.Commands) != 1 || tb.sonarr.Commands[0].Name != "RefreshSeries" {
+		t.Errorf("unexpected commands: %+v", tb.sonarr.Commands)
+	}
+}
+
+func TestSystemLogs(t *testing.T) {
+	tb := newTestBot(t)
+	for i := 0; i < 15; i++ {
+		level := "info"
+		if i%5 == 0 {
+			level = "error"
+		}
+		tb.sonarr.Logs = append(tb.sonarr.Logs, &sonarrapi.LogRecord{ID: int64(i), Time: time.Now(), Level: level, Logger: "RssSyncService", Message: "entry"})
+	}
+
+	tb.command("/system logs error")
+	tb.expectText("ERROR")
+	if text := tb.telegram.Last(chatID).Text; strings.Contains(text, "INFO") {
+		t.Errorf("info entries shown with level error:\n%v", text)
+	}
+	tb.press("Download log file")
+	document := tb.telegram.Last(chatID).Document
+	if document == nil {
+		t.Fatal("no log file sent")
+	}
+	if lines := strings.Count(string(document.File.(tgbotapi.FileBytes).Bytes), "\n"); lines != 3 {
+		t.Errorf("log file has %d lines, want 3", lines)
+	}
+}
+
+func TestSystemLogsLongMessages(t *testing.T) {
+	tb := newTestBot(t)
+	for i := 0; i < 25; i++ {
+		tb.sonarr.Logs = append(tb.sonarr.Logs, &sonarrapi.LogRecord{ID: int64(i), Time: time.Now(), Level: "error", Logger: "DownloadService", Message: strings.Repeat("failed_to_import ", 30)})
+	}
+
+	tb.command("/system logs")
+	if text := tb.telegram.Last(chatID).Text; len(text) > 4096 {
+		t.Errorf("logs page has %d characters", len(text))
+	}
+	tb.press("⏭️")
+	tb.expectText("DownloadService")
+	// A next page button of an older message stays on the last page
+	tb.HandleUpdate(bottest.Callback(chatID, tb.telegram.Last(chatID).MessageID, bot.SystemLogsNextPage))
+	if tb.telegram.Last(chatID).Button("3/3") == "" {
+		t.Error("not on the last page")
+	}
+}
+
+func TestTags(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.Tags = []*starr.Tag{{ID: 1, Label: "anime"}}
+	tb.addToLibrary(81189)
+	tb.sonarr.Series[0].Tags = []int{1}
+
+	tb.command("/tags")
+	tb.expectText("*anime*: 1 series")
+	tb.press("New tag")
+	tb.expectText("Send the name of the new tag")
+	tb.text("Kids TV")
+	tb.expectText("*kids\\-tv*: unused")
+
+	tb.press("kids-tv")
+	tb.press("Rename")
+	tb.text("family")
+	tb.expectText("*family*")
+	tb.press("Delete")
+	tb.press("Yes, delete tag")
+	tags, _ := tb.sonarr.GetTags()
+	if len(tags) != 1 || tags[0].Label != "anime" {
+		t.Errorf("unexpected tags: %+v", tags)
+	}
+}
+
+func TestAddSeriesNewTag(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.Tags = []*starr.Tag{{ID: 1, Label: "anime"}}
+
+	tb.command("/q breaking")
+	tb.press("Breaking Bad")
+	tb.press("Yes, add this series")
+	tb.press("New tag")
+	tb.text("drama")
+	tb.expectText("Select tags")
+	tb.press("Done - Continue")
+	tb.press("Standard")
+	tb.press("Future Episodes")
+	tb.press("Add + search missing")
+
+	series := tb.sonarr.FindSeries(81189)
+	if series == nil {
+		t.Fatal("series was not added")
+	}
+	tags, _ := tb.sonarr.GetTags()
+	if len(tags) != 2 || len(series.Tags) != 1 || series.Tags[0] != tags[1].ID {
+		t.Errorf("new tag not added or not selected: series tags %v, tags %+v", series.Tags, tags)
+	}
+}
+
+func TestDeleteSeriesJumpToPageAndSearch(t *testing.T) {
+	tb := newTestBot(t)
+	tb.config.MaxItems = 1
+	tb.addToLibrary(81189)
+	tb.addToLibrary(121361)
+	tb.addToLibrary(305288)
+
+	tb.command("/delete")
+	tb.expectText("page 1/3")
+	tb.press("1/3")
+	tb.expectText("Send the page number to jump to (1-3)")
+	tb.text("7")
+	tb.expectText("Invalid input")
+	tb.text("3")
+	tb.expectText("page 3/3")
+
+	tb.press("Search")
+	tb.text("thrones")
+	tb.expectText("page 1/1")
+	tb.press("Game of Thrones")
+	if tb.telegram.Last(chatID).Button("Game of Thrones ✅") == "" {
+		t.Error("Game of Thrones was not selected")
+	}
+}
+
+func TestDeleteSeriesRefineLookup(t *testing.T) {
+	tb := newTestBot(t)
+	tb.addToLibrary(81189)
+	tb.addToLibrary(121361)
+	tb.addToLibrary(305288)
+
+	tb.command("/delete e")
+	tb.expectText("page 1/1")
+	if tb.telegram.Last(chatID).Button("Game of Thrones - 2011 - ") == "" {
+		t.Error("lookup results are not labelled like /q results")
+	}
+	tb.press("Refine search")
+	tb.text("nothing")
+	tb.expectText("Invalid input")
+	tb.text("thrones")
+	if results := tb.telegram.Messages(chatID)[0]; !strings.Contains(results.Text, "Do you want to delete the following series?") {
+		t.Errorf("search not refined: %q", results.Text)
+	}
+}
+
+func TestLibraryRefineLookup(t *testing.T) {
+	tb := newTestBot(t)
+	tb.addToLibrary(81189)
+	tb.addToLibrary(121361)
+	tb.addToLibrary(305288)
+
+	tb.command("/library e")
+	tb.expectText("Search Results - page 1/1")
+	tb.press("Refine search")
+	tb.text("stranger")
+	tb.expectText("Stranger Things")
+	if tb.telegram.Last(chatID).Button("Refine search") != "" {
+		t.Error("single match did not open the series")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.QualityProfiles = []*sonarr.QualityProfile{{
+		ID:             1,
+		Name:           "HD-1080p",
+		Cutoff:         1001,
+		UpgradeAllowed: true,
+		Qualities: []*starr.Quality{
+			{Quality: &starr.BaseQuality{ID: 1, Name: "SDTV"}},
+			{Quality: &starr.BaseQuality{ID: 9, Name: "HDTV-1080p"}, Allowed: true},
+			{ID: 1001, Name: "WEB 1080p", Allowed: true, Items: []*starr.Quality{
+				{Quality: &starr.BaseQuality{ID: 15, Name: "WEBRip-1080p"}, Allowed: true},
+				{Quality: &starr.BaseQuality{ID: 3, Name: "WEBDL-1080p"}, Allowed: true},
+			}},
+		},
+		FormatItems: []*starr.FormatItem{{Format: 1, Name: "x265", Score: -100}, {Format: 2, Name: "Repack"}},
+	}}
+	tb.addToLibrary(81189)
+
+	tb.command("/profiles")
+	tb.expectText("*HD\\-1080p*: cutoff WEB 1080p, 1 series")
+	tb.press("HD-1080p")
+	tb.expectText("1\\. WEB 1080p \\(WEBDL\\-1080p, WEBRip\\-1080p\\)\n2\\. HDTV\\-1080p\n")
+	tb.expectText("x265: \\-100\n")
+	tb.expectText("Breaking Bad")
+	if strings.Contains(tb.telegram.Last(chatID).Text, "SDTV") || strings.Contains(tb.telegram.Last(chatID).Text, "Repack") {
+		t.Errorf("unexpected quality or format in %q", tb.telegram.Last(chatID).Text)
+	}
+	tb.press("\U0001F519")
+	tb.expectText("Quality Profiles")
+}
+
+func TestLibraryQuality(t *testing.T) {
+	tb := newTestBot(t)
+	series := tb.addToLibrary(305288, &sonarr.Season{SeasonNumber: 1, Monitored: true})
+	webdl := &starr.Quality{Quality: &starr.BaseQuality{ID: 3, Name: "WEBDL-1080p"}}
+	hdtv := &starr.Quality{Quality: &starr.BaseQuality{ID: 4, Name: "HDTV-720p"}}
+	tb.sonarr.EpisodeFiles = []*sonarr.EpisodeFile{
+		{ID: 1, SeriesID: series.ID, SeasonNumber: 1, Size: 1500 * 1024 * 1024, Quality: webdl,
+			MediaInfo: &sonarr.MediaInfo{Resolution: "1920x1080", VideoCodec: "x265", AudioCodec: "EAC3", AudioLanguages: "English/German", Subtitles: "English"}},
+		{ID: 2, SeriesID: series.ID, SeasonNumber: 1, Size: 300 * 1024 * 1024, Quality: hdtv, QualityCutoffNotMet: true,
+			MediaInfo: &sonarr.MediaInfo{Resolution: "1280x720", VideoCodec: "h264", AudioCodec: "AAC", AudioLanguages: "English"}},
+	}
+	tb.sonarr.Episodes = []*sonarr.Episode{
+		{ID: 11, SeriesID: series.ID, SeasonNumber: 1, EpisodeNumber: 1, EpisodeFileID: 1, HasFile: true},
+		{ID: 12, SeriesID: series.ID, SeasonNumber: 1, EpisodeNumber: 2, EpisodeFileID: 2, HasFile: true},
+	}
+
+	tb.command("/library stranger")
+	tb.expectText("Size: 1\\.8 GB")
+	tb.press("Quality")
+	tb.expectText("Quality: HDTV\\-720p 1, WEBDL\\-1080p 1\n")
+	tb.expectText("Audio languages: English 2, German 1\n")
+	tb.expectText("Subtitles: English 1, none 1\n")
+	tb.expectText("S01E02: HDTV\\-720p")
+	tb.press("Search upgrades (1)")
+
+	cmd := tb.sonarr.Commands[len(tb.sonarr.Commands)-1]
+	if cmd.Name != "EpisodeSearch" || len(cmd.EpisodeIDs) != 1 || cmd.EpisodeIDs[0] != 12 {
+		t.Errorf("unexpected command: %+v", cmd)
+	}
+}
+
+func TestAddSeriesByIDAndURL(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.Catalog[0].ImdbID = "tt0903747"
+	tb.sonarr.Catalog[0].TvMazeID = 169
+
+	tb.command("/q tvdb:81189")
+	tb.expectText("Is this the correct series?")
+	tb.expectText("Breaking Bad")
+
+	tb.command("/q imdb:tt0903747")
+	tb.expectText("Is this the correct series?")
+
+	// Links arrive with a url entity instead of a command
+	update := bottest.Text(chatID, "look at https://www.tvmaze.com/shows/169/breaking-bad")
+	update.Message.Entities = []tgbotapi.MessageEntity{{Type: "url", Offset: 8, Length: 46}}
+	tb.HandleUpdate(update)
+	tb.expectText("Is this the correct series?")
+	tb.press("Yes, add this series")
+	tb.expectText("Select series type")
+
+	// Trakt links only have a slug, they are searched by title
+	tb.command("/q https://trakt.tv/shows/breaking-bad")
+	tb.expectText("Series found")
+}
+
+func TestAddSeriesSearchPagination(t *testing.T) {
+	tb := newTestBot(t)
+	tb.config.MaxItems = 2
+	tb.sonarr.Catalog[0].Network = "AMC"
+	tb.addToLibrary(121361)
+
+	tb.command("/q e")
+	tb.expectText("*Found 3 series* \\- page 1/2")
+	tb.expectText("Breaking Bad](https://www.imdb.com/title/) \\- _2008_ \\- AMC \\- ended\n")
+	tb.expectText("\U0001F4DA already in your library")
+	if tb.telegram.Last(chatID).Button("Game of Thrones - 2011 - ended - \U0001F4DA") == "" {
+		t.Error("library marker missing on button")
+	}
+	tb.press("▶️")
+	tb.expectText("page 2/2")
+	tb.press("Stranger Things")
+	tb.press("\U0001F519")
+
+	tb.press("Refine search")
+	tb.text("nothing")
+	tb.expectText("Invalid input")
+	tb.text("stranger")
+	if results := tb.telegram.Messages(chatID)[0]; !strings.Contains(results.Text, "Series found") {
+		t.Errorf("search not refined: %q", results.Text)
+	}
+}
+
+func TestAddSeriesPreset(t *testing.T) {
+	tb := newTestBot(t)
+	tb.config.Presets = []config.Preset{
+		{Name: "anime-1080p", QualityProfile: "HD-1080p", RootFolder: "/anime", SeriesType: "anime", Monitor: "all", Search: "missing"},
+		{Name: "kids", QualityProfile: "hd-1080p", RootFolder: "/tv", Tags: []string{"kids"}, SeriesType: "standard", Monitor: "future"},
+	}
+
+	tb.command("/q --preset unknown breaking")
+	tb.expectText("Unknown preset \"unknown\", presets: anime-1080p, kids")
+
+	tb.command("/q --preset kids breaking")
+	tb.press("Breaking Bad")
+	if first := tb.telegram.Last(chatID).Keyboard.InlineKeyboard[0][0].Text; first != "⚡ Add as kids" {
+		t.Errorf("chosen preset is not the first button: %q", first)
+	}
+	tb.press("Add as kids")
+	tb.expectText("added")
+
+	series := tb.sonarr.FindSeries(81189)
+	tags, _ := tb.sonarr.GetTags()
+	if series == nil || series.RootFolderPath != "/tv" || series.SeriesType != "standard" || len(tags) != 1 || len(series.Tags) != 1 || series.Tags[0] != tags[0].ID {
+		t.Errorf("series not added with preset: %+v, tags %+v", series, tags)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	tb := newTestBot(t)
+	path := filepath.Join(t.TempDir(), "settings.json")
+	if err := tb.LoadSettings(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tb.command("/settings")
+	tb.expectText("Monitor: ask")
+	tb.press("Monitor")
+	tb.press("Future Episodes")
+	tb.press("Add option")
+	tb.press("Add + search missing")
+	tb.press("Toggle season folders")
+	tb.expectText("Monitor: Future Episodes")
+	tb.press("Done")
+	tb.expectText("Settings saved")
+
+	// The monitor and add option steps are skipped
+	tb.command("/q breaking")
+	tb.press("Breaking Bad")
+	tb.press("Yes, add this series")
+	tb.press("Standard")
+	tb.expectText("added")
+
+	series := tb.sonarr.FindSeries(81189)
+	if series == nil || series.SeasonFolder {
+		t.Fatalf("series not added with settings: %+v", series)
+	}
+
+	reloaded := newTestBot(t)
+	if err := reloaded.LoadSettings(path); err != nil {
+		t.Fatal(err)
+	}
+	reloaded.command("/settings")
+	reloaded.expectText("Add option: Add \\+ search missing")
+
+	// Without a settings file the settings would be lost on restart
+	unsaved := newTestBot(t)
+	unsaved.command("/settings")
+	unsaved.expectText("SBOT_SETTINGS_FILE is not set")
+}
+
+func TestAddSeriesChooseSeasons(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.Catalog[0].Seasons = []*sonarr.Season{{SeasonNumber: 0}, {SeasonNumber: 1}, {SeasonNumber: 2}, {SeasonNumber: 3}}
+
+	tb.command("/q breaking")
+	tb.press("Breaking Bad")
+	tb.press("Yes, add this series")
+	tb.press("Standard")
+	tb.press("Choose seasons")
+	tb.expectText("Select the seasons to monitor")
+	tb.press("Season 2")
+	tb.press("Season 3")
+	tb.press("Done - Continue")
+	tb.press("Add + search missing")
+	tb.expectText("added")
+
+	series := tb.sonarr.FindSeries(81189)
+	if series == nil || !series.Monitored || len(series.Seasons) != 4 {
+		t.Fatalf("series not added with seasons: %+v", series)
+	}
+	for _, season := range series.Seasons {
+		if season.Monitored != (season.SeasonNumber >= 2) {
+			t.Errorf("season %d monitored %v", season.SeasonNumber, season.Monitored)
+		}
+	}
+}
+
+func TestBulkAdd(t *testing.T) {
+	tb := newTestBot(t)
+	tb.addToLibrary(305288)
+
+	tb.command("/bulkadd")
+	tb.expectText("one per line")
+	tb.text("Breaking Bad\n305288\ng\nnothing here")
+	tb.expectText("Breaking Bad (2008)")
+	tb.expectText("Stranger Things (2016): already in library")
+	tb.expectText("g: 3 matches")
+	tb.expectText("nothing here: not found")
+
+	tb.press("❓ g")
+	tb.press("Game of Thrones")
+	tb.press("Continue with 2 series")
+	tb.press("Standard")
+	if tb.telegram.Last(chatID).Button("Choose seasons") != "" {
+		t.Error("seasons can be chosen for several series")
+	}
+	tb.press("All Episodes")
+	tb.press("Add + search missing")
+	tb.expectText("Added 2 of 2 series")
+
+	if tb.sonarr.FindSeries(81189) == nil || tb.sonarr.FindSeries(121361) == nil {
+		t.Error("series were not added")
+	}
+}
+
+func TestExportAndImportLibrary(t *testing.T) {
+	source := newTestBot(t)
+	source.sonarr.Tags = []*starr.Tag{{ID: 1, Label: "kids"}}
+	source.addToLibrary(81189, &sonarr.Season{SeasonNumber: 1}, &sonarr.Season{SeasonNumber: 2, Monitored: true})
+	source.sonarr.Series[0].Tags = []int{1}
+	source.addToLibrary(121361)
+
+	for _, format := range []string{"json", "csv"} {
+		source.command("/export " + format)
+		export := source.telegram.Last(chatID)
+		if export.Document == nil {
+			t.Fatalf("no %v export sent: %+v", format, export)
+		}
+
+		target := newTestBot(t)
+		target.addToLibrary(121361)
+		target.telegram.Files["export"] = export.Document.File.(tgbotapi.FileBytes).Bytes
+		target.command("/importlibrary")
+		target.HandleUpdate(bottest.Document(chatID, "export", "library."+format, ""))
+		target.expectText("Library import (dry run) of 2 series")
+		target.expectText("➕ To add (1):\nBreaking Bad")
+		target.expectText("New tags: kids")
+		target.expectText("1 series are already in the library")
+		target.press("Apply - add 1 series")
+		target.expectText("Imported 1 of 1 series")
+
+		added := target.sonarr.FindSeries(81189)
+		if added == nil || len(added.Tags) != 1 || len(added.Seasons) != 2 || added.Seasons[0].Monitored || !added.Seasons[1].Monitored {
+			t.Errorf("%v: series not imported as exported: %+v", format, added)
+		}
+	}
+}
+
+func TestImportLibraryTagLabels(t *testing.T) {
+	tb := newTestBot(t)
+	tb.sonarr.Tags = []*starr.Tag{{ID: 1, Label: "kids"}}
+	tb.telegram.Files["export"] = []byte("title,tvdb_id,quality_profile,root_folder,tags\n" +
+		"Breaking Bad,81189,HD-1080p,/tv,Kids k!ds\n")
+
+	tb.command("/importlibrary")
+	tb.HandleUpdate(bottest.Document(chatID, "export", "library.csv", ""))
+	tb.expectText("Tags not imported (1):\nBreaking Bad: \"k!ds\" is not a valid tag name")
+	if strings.Contains(tb.telegram.Last(chatID).Text, "New tags") {
+		t.Errorf("existing tag created again: %q", tb.telegram.Last(chatID).Text)
+	}
+	tb.press("Apply - add 1 series")
+	tb.expectText("Imported 1 of 1 series")
+
+	added := tb.sonarr.FindSeries(81189)
+	if added == nil || len(added.Tags) != 1 || added.Tags[0] != 1 {
+		t.Errorf("series not added with the existing tag: %+v", added)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	tb := newTestBot(t)
+	tb.addToLibrary(81189)
+	tb.Discovery = &discovery.Fixture{
+		Lists: map[discovery.List][]*discovery.Show{
+			discovery.Trending: {
+				{Title: "Breaking Bad", Year: 2008, TvdbID: 81189},
+				{Title: "Stranger Things", Year: 2016, TvdbID: 305288},
+			},
+		},
+		Related: map[int64][]*discovery.Show{
+			81189: {{Title: "Game of Thrones", Year: 2011, TvdbID: 121361}},
+		},
+	}
+
+	tb.command("/discover")
+	tb.press("Trending")
+	tb.expectText("📚 Breaking Bad (2008)")
+	if tb.telegram.Last(chatID).Button("Breaking Bad") != "" {
+		t.Error("series in the library can be added")
+	}
+	tb.press("➕ Stranger Things")
+	tb.expectText("Is this the correct series?")
+	tb.press("🔙")
+	tb.expectText("Trending on fixture")
+
+	tb.command("/discover similar breaking")
+	tb.expectText("Similar to Breaking Bad")
+	tb.press("➕ Game of Thrones")
+	tb.press("Yes, add this series")
+	tb.press("Standard")
+	tb.press("All Episodes")
+	tb.press("Add + search missing")
+
+	if tb.sonarr.FindSeries(121361) == nil {
+		t.Error("series was not added")
+	}
+}
+
+func TestLibraryWatchStatus(t *testing.T) {
+	tb := newTestBot(t)
+	mediaServer := bottest.NewMediaServer()
+	tb.MediaServer = mediaServer
+	series := tb.addToLibrary(305288,
+		&sonarr.Season{SeasonNumber: 1, Monitored: true, Statistics: &sonarr.Statistics{TotalEpisodeCount: 2, EpisodeFileCount: 2, SizeOnDisk: 2e9}},
+		&sonarr.Season{SeasonNumber: 2, Monitored: true, Statistics: &sonarr.Statistics{TotalEpisodeCount: 2, EpisodeFileCount: 1, SizeOnDisk: 1e9}})
+	tb.sonarr.Series[0].Ended = true
+	tb.sonarr.Episodes = []*sonarr.Episode{
+		{ID: 1, SeriesID: series.ID, SeasonNumber: 1, EpisodeNumber: 1, HasFile: true},
+		{ID: 2, SeriesID: series.ID, SeasonNumber: 1, EpisodeNumber: 2, HasFile: true},
+		{ID: 3, SeriesID: series.ID, SeasonNumber: 2, EpisodeNumber: 1, HasFile: true},
+		{ID: 4, SeriesID: series.ID, SeasonNumber: 2, EpisodeNumber: 2},
+	}
+	tb.sonarr.EpisodeFiles = []*sonarr.EpisodeFile{
+		{ID: 1, SeriesID: series.ID, SeasonNumber: 1},
+		{ID: 2, SeriesID: series.ID, SeasonNumber: 1},
+		{ID: 3, SeriesID: series.ID, SeasonNumber: 2},
+	}
+	mediaServer.MarkWatched(305288, 1, 1, 2)
+
+	tb.command("/library")
+	tb.press("Fully Watched & Ended")
+	tb.expectText("No series found")
+
+	tb.command("/library stranger")
+	tb.expectText("Watched: 2 episodes, 1 unwatched on disk")
+	tb.press("Clean up watched seasons (1)")
+	tb.expectText("Fully watched seasons of Stranger Things on Mock: 1")
+	tb.press("Season 1")
+	tb.expectText("Fully watched")
+	tb.press("Delete")
+
+	files, _ := tb.sonarr.GetSeriesEpisodeFiles(series.ID)
+	if len(files) != 1 || files[0].SeasonNumber != 2 {
+		t.Errorf("unexpected remaining files: %+v", files)
+	}
+
+	mediaServer.MarkWatched(305288, 2, 1, 2)
+	tb.command("/library")
+	tb.press("Fully Watched & Ended")
+	tb.expectText("Fully Watched & Ended Series")
+	tb.press("Stranger Things")
+}
+
+func TestCleanupRules(t *testing.T) {
+	tb := newTestBot(t)
+	tb.config.CleanupRules = []config.CleanupRule{
+		{Name: "old-ended", Action: config.CleanupDeleteFiles, Status: "ended", UntouchedDays: 180, KeepSeasons: 1},
+		{Name: "complete", Action: config.CleanupUnmonitor, Complete: true},
+	}
+	series := tb.addToLibrary(81189,
+		&sonarr.Season{SeasonNumber: 1, Monitored: true, Statistics: &sonarr.Statistics{TotalEpisodeCount: 1, EpisodeFileCount: 1, SizeOnDisk: 1e9}},
+		&sonarr.Season{SeasonNumber: 2, Monitored: true, Statistics: &sonarr.Statistics{TotalEpisodeCount: 1, EpisodeFileCount: 1, SizeOnDisk: 1e9}})
+	tb.sonarr.Series[0].Status = "ended"
+	added := time.Now().AddDate(-1, 0, 0)
+	tb.sonarr.EpisodeFiles = []*sonarr.EpisodeFile{
+		{ID: 1, SeriesID: series.ID, SeasonNumber: 1, DateAdded: added},
+		{ID: 2, SeriesID: series.ID, SeasonNumber: 2, DateAdded: added},
+	}
+
+	tb.command("/cleanup")
+	tb.expectText("Cleanup report: 2 actions")
+	tb.expectText("old-ended - delete-files:\nBreaking Bad - Season 1")
+	tb.expectText("complete - unmonitor:\nBreaking Bad - Season 2")
+	if files, _ := tb.sonarr.GetSeriesEpisodeFiles(series.ID); len(files) != 2 {
+		t.Fatalf("files deleted before approval: %+v", files)
+	}
+	tb.press("Approve")
+	tb.expectText("2 of 2 actions done")
+
+	files, _ := tb.sonarr.GetSeriesEpisodeFiles(series.ID)
+	if len(files) != 1 || files[0].SeasonNumber != 2 {
+		t.Errorf("unexpected remaining files: %+v", files)
+	}
+	for _, season := range tb.sonarr.FindSeries(81189).Seasons {
+		if season.Monitored {
+			t.Errorf("season %d is still monitored", season.SeasonNumber)
+		}
+	}
+}
+
+func TestCleanupApprovalSkipsChanges(t *testing.T) {
+	tb := newTestBot(t)
+	tb.config.CleanupRules = []config.CleanupRule{
+		{Name: "old-ended", Action: config.CleanupDeleteFiles, Status: "ended", KeepSeasons: 1},
+		{Name: "complete", Action: config.CleanupUnmonitor, Complete: true},
+	}
+	series := tb.addToLibrary(81189,
+		&sonarr.Season{SeasonNumber: 1, Monitored: true, Statistics: &sonarr.Statistics{TotalEpisodeCount: 2, EpisodeFileCount: 1, SizeOnDisk: 1e9}},
+		&sonarr.Season{SeasonNumber: 2, Monitored: true, Statistics: &sonarr.Statistics{TotalEpisodeCount: 1, EpisodeFileCount: 1, SizeOnDisk: 1e9}})
+	tb.sonarr.Series[0].Status = "ended"
+	tb.sonarr.EpisodeFiles = []*sonarr.EpisodeFile{
+		{ID: 1, SeriesID: series.ID, SeasonNumber: 1},
+		{ID: 2, SeriesID: series.ID, SeasonNumber: 2},
+	}
+
+	tb.command("/cleanup")
+	tb.expectText("Cleanup report: 2 actions")
+
+	// A file imported and a season unmonitored after the report are left alone
+	tb.sonarr.EpisodeFiles = append(tb.sonarr.EpisodeFiles, &sonarr.EpisodeFile{ID: 3, SeriesID: series.ID, SeasonNumber: 1})
+	tb.sonarr.Series[0].Seasons[1].Monitored = false
+	tb.press("Approve")
+	tb.expectText("1 of 2 actions done, 1 skipped")
+
+	files, _ := tb.sonarr.GetSeriesEpisodeFiles(series.ID)
+	if len(files) != 2 || files[0].ID != 2 || files[1].ID != 3 {
+		t.Errorf("unexpected remaining files: %+v", files)
+	}
+}
@@ -0,0 +1,308 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr/sonarr"
+)
+
+const (
+	BulkAddItem        = "BULKADD_ITEM_"
+	BulkAddSelect      = "BULKADD_SELECT_"
+	BulkAddSkip        = "BULKADD_SKIP"
+	BulkAddGoBack      = "BULKADD_GOBACK"
+	BulkAddContinue    = "BULKADD_CONTINUE"
+	BulkAddCancel      = "BULKADD_CANCEL"
+	BulkAddPrompt      = "Send the titles or TVDB IDs of the series, one per line:"
+	AmbiguousIcon      = "❓"
+	SkippedIcon        = "⏭"
+	bulkAddMaxItems    = 50
+	bulkAddMaxMatches  = 8
+	bulkAddPlaceholder = "e.g. Breaking Bad"
+)
+
+// bulkAddItem is a line of /bulkadd and what Sonarr found for it.
+type bulkAddItem struct {
+	query   string
+	matches []*sonarr.Series
+	series  *sonarr.Series // nil until a match is chosen
+	skipped bool
+	err     error
+}
+
+type userBulkAdd struct {
+	items     []*bulkAddItem
+	item      *bulkAddItem // being disambiguated
+	chatID    int64
+	messageID int
+}
+
+func (b *Bot) processBulkAddCommand(update tgbotapi.Update, chatID int64) {
+	if text := update.Message.CommandArguments(); strings.TrimSpace(text) != "" {
+		b.startBulkAdd(chatID, text)
+		return
+	}
+	b.askForText(chatID, BulkAddPrompt, bulkAddPlaceholder, func(text string) bool {
+		if len(bulkAddQueries(text)) == 0 {
+			return false
+		}
+		b.startBulkAdd(chatID, text)
+		return true
+	})
+}
+
+// bulkAddQueries returns the non-empty lines of text without duplicates.
+func bulkAddQueries(text string) []string {
+	var queries []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[strings.ToLower(line)] {
+			continue
+		}
+		seen[strings.ToLower(line)] = true
+		queries = append(queries, line)
+	}
+	return queries
+}
+
+// startBulkAdd looks up every line of text and shows the review.
+func (b *Bot) startBulkAdd(chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, "Handling bulk add command... please wait")
+	message, _ := b.sendMessage(msg)
+	command := userBulkAdd{
+		chatID:    message.Chat.ID,
+		messageID: message.MessageID,
+	}
+
+	queries := bulkAddQueries(text)
+	if len(queries) > bulkAddMaxItems {
+		b.sendMessageWithEdit(&command, fmt.Sprintf("Please send at most %d series at once, got %d", bulkAddMaxItems, len(queries)))
+		return
+	}
+	for _, query := range queries {
+		command.items = append(command.items, b.lookupBulkAddItem(chatID, query))
+	}
+	b.setActiveCommand(chatID, BulkAddCommand)
+	b.showBulkAddReview(&command)
+}
+
+// lookupBulkAddItem looks up a title, ID or link. Bare numbers are TVDB IDs.
+// A single match or the only exact title match is chosen right away.
+func (b *Bot) lookupBulkAddItem(chatID int64, query string) *bulkAddItem {
+	item := &bulkAddItem{query: query}
+	term, exact := lookupTerm(query)
+	if digitsPattern.MatchString(query) {
+		term, exact = "tvdb:"+query, true
+	}
+	matches, err := b.getSonarrServer().Lookup(term)
+	if err != nil {
+		b.logger(chatID).Error("Sonarr request failed", "query", query, "error", err)
+		item.err = err
+		return item
+	}
+	item.matches = matches
+	if len(matches) == 1 || exact && len(matches) > 0 {
+		item.series = matches[0]
+		return item
+	}
+	var titleMatches []*sonarr.Series
+	for _, series := range matches {
+		if strings.EqualFold(series.Title, query) {
+			titleMatches = append(titleMatches, series)
+		}
+	}
+	if len(titleMatches) == 1 {
+		item.series = titleMatches[0]
+	}
+	return item
+}
+
+func (b *Bot) bulkAdd(update tgbotapi.Update) bool {
+	chatID, err := b.getChatID(update)
+	if err != nil {
+		slog.Warn("Cannot bulk add series", "update_id", update.UpdateID, "error", err)
+		return false
+	}
+
+	command, exists := b.getBulkAddState(chatID)
+	if !exists {
+		return false
+	}
+	data := update.CallbackQuery.Data
+	switch {
+	case data == BulkAddGoBack:
+		return b.showBulkAddReview(command)
+	case data == BulkAddSkip:
+		if command.item != nil {
+			command.item.series = nil
+			command.item.skipped = true
+		}
+		return b.showBulkAddReview(command)
+	case data == BulkAddContinue:
+		return b.handleBulkAddContinue(update, command)
+	case data == BulkAddCancel:
+		b.clearState(update)
+		b.sendMessageWithEdit(command, CommandsCleared)
+		return false
+	case strings.HasPrefix(data, BulkAddItem):
+		index, err := strconv.Atoi(strings.TrimPrefix(data, BulkAddItem))
+		if err != nil || index < 0 || index >= len(command.items) {
+			return b.showBulkAddReview(command)
+		}
+		command.item = command.items[index]
+		return b.showBulkAddMatches(command)
+	case strings.HasPrefix(data, BulkAddSelect):
+		tvdbID := strings.TrimPrefix(data, BulkAddSelect)
+		if command.item != nil {
+			for _, series := range command.item.matches {
+				if strconv.FormatInt(series.TvdbID, 10) == tvdbID {
+					command.item.series = series
+					command.item.skipped = false
+				}
+			}
+		}
+		return b.showBulkAddReview(command)
+	}
+	return false
+}
+
+// bulkAddSeries returns the chosen series that are not in the library yet,
+// each series once.
+func bulkAddSeries(items []*bulkAddItem) []*sonarr.Series {
+	var result []*sonarr.Series
+	seen := make(map[int64]bool)
+	for _, item := range items {
+		if item.skipped || item.series == nil || item.series.ID != 0 || seen[item.series.TvdbID] {
+			continue
+		}
+		seen[item.series.TvdbID] = true
+		result = append(result, item.series)
+	}
+	return result
+}
+
+func (b *Bot) showBulkAddReview(command *userBulkAdd) bool {
+	command.item = nil
+	var text strings.Builder
+	var buttonLabels []string
+	var buttonData []string
+
+	fmt.Fprintf(&text, "Review %d series, tap one to choose another match or skip it:\n\n", len(command.items))
+	for i, item := range command.items {
+		var label, detail string
+		switch {
+		case item.err != nil:
+			label, detail = UnmonitorIcon+" "+item.query, ": "+item.err.Error()
+		case len(item.matches) == 0:
+			label, detail = UnmonitorIcon+" "+item.query, ": not found"
+		case item.skipped:
+			label, detail = SkippedIcon+" "+item.query, ": skipped"
+		case item.series == nil:
+			label, detail = AmbiguousIcon+" "+item.query, fmt.Sprintf(": %d matches", len(item.matches))
+		case item.series.ID != 0:
+			label, detail = fmt.Sprintf("%v %v (%d)", InLibraryIcon, item.series.Title, item.series.Year), ": already in library"
+		default:
+			label = fmt.Sprintf("%v %v (%d)", MonitorIcon, item.series.Title, item.series.Year)
+		}
+		fmt.Fprintf(&text, "%v%v\n", label, detail)
+		if len(item.matches) > 0 {
+			buttonLabels = append(buttonLabels, label)
+			buttonData = append(buttonData, BulkAddItem+strconv.Itoa(i))
+		}
+	}
+
+	if count := len(bulkAddSeries(command.items)); count > 0 {
+		buttonLabels = append(buttonLabels, fmt.Sprintf("Continue with %d series", count))
+		buttonData = append(buttonData, BulkAddContinue)
+	}
+	buttonLabels = append(buttonLabels, "Cancel - clear command")
+	buttonData = append(buttonData, BulkAddCancel)
+
+	b.setBulkAddState(command.chatID, command)
+	b.sendMessageWithEditAndKeyboard(command, b.createKeyboard(buttonLabels, buttonData), text.String())
+	return false
+}
+
+// showBulkAddReviewAgain returns from the add wizard to the review.
+func (b *Bot) showBulkAddReviewAgain(chatID int64) bool {
+	command, exists := b.getBulkAddState(chatID)
+	if !exists {
+		return false
+	}
+	b.setActiveCommand(chatID, BulkAddCommand)
+	return b.showBulkAddReview(command)
+}
+
+func (b *Bot) showBulkAddMatches(command *userBulkAdd) bool {
+	var buttonLabels []string
+	var buttonData []string
+	for i, series := range command.item.matches {
+		if i == bulkAddMaxMatches {
+			break
+		}
+		label := searchResultLabel(series)
+		if series == command.item.series {
+			label += " " + SelectedIcon
+		}
+		buttonLabels = append(buttonLabels, label)
+		buttonData = append(buttonData, BulkAddSelect+strconv.FormatInt(series.TvdbID, 10))
+	}
+	buttonLabels = append(buttonLabels, SkippedIcon+" Skip", "\U0001F519")
+	buttonData = append(buttonData, BulkAddSkip, BulkAddGoBack)
+
+	text := fmt.Sprintf("Choose the series for %q:", command.item.query)
+	if len(command.item.matches) > bulkAddMaxMatches {
+		text += fmt.Sprintf("\nShowing %d of %d matches, add a year or use an ID to narrow it down.", bulkAddMaxMatches, len(command.item.matches))
+	}
+	b.setBulkAddState(command.chatID, command)
+	b.sendMessageWithEditAndKeyboard(command, b.createKeyboard(buttonLabels, buttonData), text)
+	return false
+}
+
+// handleBulkAddContinue asks for the options shared by all series with the
+// add wizard.
+func (b *Bot) handleBulkAddContinue(update tgbotapi.Update, command *userBulkAdd) bool {
+	series := bulkAddSeries(command.items)
+	if len(series) == 0 {
+		return b.showBulkAddReview(command)
+	}
+	addCommand := userAddSeries{
+		series:    series[0],
+		bulk:      series,
+		chatID:    command.chatID,
+		messageID: command.messageID,
+	}
+	if !b.loadAddSeriesChoices(update, &addCommand) {
+		return false
+	}
+	b.setBulkAddState(command.chatID, command)
+	b.setAddSeriesState(command.chatID, &addCommand)
+	b.setActiveCommand(command.chatID, AddSeriesCommand)
+	return b.showAddSeriesProfiles(&addCommand)
+}
+
+// addBulkSeriesToLibrary adds the series of /bulkadd and reports the result
+// of each.
+func (b *Bot) addBulkSeriesToLibrary(command *userAddSeries) bool {
+	var lines strings.Builder
+	added := 0
+	for _, series := range command.bulk {
+		if _, err := b.getSonarrServer().AddSeries(addSeriesInput(command, series)); err != nil {
+			b.logger(command.chatID).Error("Sonarr request failed", "series", series.Title, "error", err)
+			fmt.Fprintf(&lines, "%v %v: %v\n", UnmonitorIcon, series.Title, err)
+			continue
+		}
+		added++
+		b.audit(command.chatID, "added series", series.Title, "tvdb_id", series.TvdbID,
+			"quality_profile_id", command.profileID, "root_folder", command.rootFolder.Path, "monitor", command.monitor)
+		fmt.Fprintf(&lines, "%v %v\n", MonitorIcon, series.Title)
+	}
+	b.sendMessageWithEdit(command, fmt.Sprintf("Added %d of %d series\n\n%v", added, len(command.bulk), lines.String()))
+	b.clearChatState(command.chatID)
+	return true
+}
@@ -0,0 +1,413 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/config"
+	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
+)
+
+const (
+	CleanupApprove = "CLEANUP_APPROVE_"
+	CleanupDismiss = "CLEANUP_DISMISS_"
+	// Lines of the report, the rest is summarized to stay below Telegram's
+	// message size limit
+	cleanupMaxLines = 60
+)
+
+// cleanupAction is a season selected by a cleanup rule.
+type cleanupAction struct {
+	rule   string
+	action string // config.CleanupDeleteFiles or config.CleanupUnmonitor
+	series *sonarr.Series
+	season *sonarr.Season
+	// fileIDs are the episode files of the season when the report was made,
+	// only they are deleted
+	fileIDs []int64
+}
+
+// key identifies the action across evaluations.
+func (a *cleanupAction) key() string {
+	return fmt.Sprintf("%d/%d/%v", a.series.ID, a.season.SeasonNumber, a.action)
+}
+
+// cleanupReport is the result of an evaluation of the cleanup rules waiting
+// for the approval of an admin. Only the latest report can be approved.
+type cleanupReport struct {
+	id       int
+	actions  []*cleanupAction
+	messages map[int64]int // message ID of the report in each admin chat
+	handled  bool
+}
+
+// ScheduleCleanup evaluates the cleanup rules every SBOT_CLEANUP_INTERVAL
+// until ctx is done and sends the report to the admins. Rules changed by a
+// config reload apply from the next evaluation.
+func (b *Bot) ScheduleCleanup(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.getConfig().CleanupInterval):
+		}
+		if len(b.getConfig().CleanupRules) == 0 {
+			continue
+		}
+		report, err := b.runCleanupRules()
+		if err != nil {
+			b.NotifyAdmins(fmt.Sprintf("Evaluating the cleanup rules failed: %v", err))
+			continue
+		}
+		if len(report.actions) == 0 {
+			slog.Info("Cleanup rules found nothing to clean up")
+		}
+	}
+}
+
+// processCleanupCommand evaluates the cleanup rules right away for /cleanup.
+func (b *Bot) processCleanupCommand(chatID int64) {
+	if !b.getConfig().AdminChatIDs[chatID] {
+		msg := tgbotapi.NewMessage(chatID, "Only admins can run the cleanup rules")
+		b.sendMessage(msg)
+		return
+	}
+	if len(b.getConfig().CleanupRules) == 0 {
+		msg := tgbotapi.NewMessage(chatID, "No cleanup rules configured, see SBOT_CLEANUP_RULE_<NAME>")
+		b.sendMessage(msg)
+		return
+	}
+	report, err := b.runCleanupRules()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	if len(report.actions) == 0 {
+		msg := tgbotapi.NewMessage(chatID, "The cleanup rules found nothing to clean up")
+		b.sendMessage(msg)
+	}
+}
+
+// runCleanupRules evaluates the rules and, if they found something, sends
+// the report to the admins. The report replaces any report not handled yet.
+func (b *Bot) runCleanupRules() (*cleanupReport, error) {
+	actions, err := b.evaluateCleanupRules(b.getConfig().CleanupRules)
+	if err != nil {
+		return nil, err
+	}
+	b.muCleanupReport.Lock()
+	defer b.muCleanupReport.Unlock()
+	b.lastCleanupReportID++
+	report := &cleanupReport{id: b.lastCleanupReportID, actions: actions, messages: make(map[int64]int)}
+	if len(actions) == 0 {
+		return report, nil
+	}
+	b.cleanupReport = report
+
+	keyboard := b.createKeyboard(
+		[]string{fmt.Sprintf("%v Approve - run %d actions", MonitorIcon, len(actions)), "Dismiss"},
+		[]string{CleanupApprove + strconv.Itoa(report.id), CleanupDismiss + strconv.Itoa(report.id)},
+	)
+	text := cleanupReportText(actions)
+	for chatID := range b.getConfig().AdminChatIDs {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = keyboard
+		if message, err := b.sendMessage(msg); err == nil {
+			report.messages[chatID] = message.MessageID
+		}
+	}
+	return report, nil
+}
+
+// evaluateCleanupRules returns the seasons selected by the rules. Specials are
+// never selected. Deleting files also unmonitors, so seasons whose files are
+// deleted are not unmonitored separately.
+func (b *Bot) evaluateCleanupRules(rules []config.CleanupRule) ([]*cleanupAction, error) {
+	library, err := b.getSonarrServer().GetSeries(0)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := b.getSonarrServer().GetTags()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(library, func(i, j int) bool {
+		return utils.IgnoreArticles(strings.ToLower(library[i].Title)) < utils.IgnoreArticles(strings.ToLower(library[j].Title))
+	})
+
+	var actions []*cleanupAction
+	selected := make(map[string]bool)
+	episodeFiles := make(map[int64][]*sonarr.EpisodeFile)
+	for _, rule := range rules {
+		for _, series := range library {
+			matches, err := b.cleanupRuleMatches(rule, series, tags, episodeFiles)
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				continue
+			}
+			for _, season := range cleanupSeasons(rule, series) {
+				action := &cleanupAction{rule: rule.Name, action: rule.Action, series: series, season: season}
+				if selected[action.key()] {
+					continue
+				}
+				selected[action.key()] = true
+				if action.action == config.CleanupDeleteFiles {
+					files, err := b.cachedEpisodeFiles(series.ID, episodeFiles)
+					if err != nil {
+						return nil, err
+					}
+					// Not nil, deleteSeasonFiles would delete every file
+					action.fileIDs = []int64{}
+					for _, file := range files {
+						if file.SeasonNumber == season.SeasonNumber {
+							action.fileIDs = append(action.fileIDs, file.ID)
+						}
+					}
+				}
+				actions = append(actions, action)
+			}
+		}
+	}
+	return slices.DeleteFunc(actions, func(action *cleanupAction) bool {
+		return action.action == config.CleanupUnmonitor &&
+			selected[fmt.Sprintf("%d/%d/%v", action.series.ID, action.season.SeasonNumber, config.CleanupDeleteFiles)]
+	}), nil
+}
+
+// cachedEpisodeFiles returns the episode files of a series, requesting them
+// only once per evaluation.
+func (b *Bot) cachedEpisodeFiles(seriesID int64, episodeFiles map[int64][]*sonarr.EpisodeFile) ([]*sonarr.EpisodeFile, error) {
+	if files, cached := episodeFiles[seriesID]; cached {
+		return files, nil
+	}
+	files, err := b.getSonarrServer().GetSeriesEpisodeFiles(seriesID)
+	if err != nil {
+		return nil, err
+	}
+	episodeFiles[seriesID] = files
+	return files, nil
+}
+
+// cleanupRuleMatches checks the conditions of a rule that apply to the whole
+// series. episodeFiles caches the episode files of the series.
+func (b *Bot) cleanupRuleMatches(rule config.CleanupRule, series *sonarr.Series, tags []*starr.Tag, episodeFiles map[int64][]*sonarr.EpisodeFile) (bool, error) {
+	if rule.Status != "" && series.Status != rule.Status {
+		return false, nil
+	}
+	if rule.SeriesType != "" && series.SeriesType != rule.SeriesType {
+		return false, nil
+	}
+	if len(rule.Tags) > 0 && !slices.ContainsFunc(series.Tags, func(tagID int) bool {
+		tag := findTagByID(tags, tagID)
+		return tag != nil && slices.Contains(rule.Tags, strings.ToLower(tag.Label))
+	}) {
+		return false, nil
+	}
+	if rule.UntouchedDays > 0 {
+		files, err := b.cachedEpisodeFiles(series.ID, episodeFiles)
+		if err != nil {
+			return false, err
+		}
+		lastFile := series.Added
+		for _, file := range files {
+			if file.DateAdded.After(lastFile) {
+				lastFile = file.DateAdded
+			}
+		}
+		if time.Since(lastFile) < time.Duration(rule.UntouchedDays)*24*time.Hour {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// cleanupSeasons returns the seasons of a matching series the rule's action
+// applies to.
+func cleanupSeasons(rule config.CleanupRule, series *sonarr.Series) []*sonarr.Season {
+	var seasons []*sonarr.Season
+	for _, season := range series.Seasons {
+		if season.SeasonNumber > 0 {
+			seasons = append(seasons, season)
+		}
+	}
+	sort.Slice(seasons, func(i, j int) bool {
+		return seasons[i].SeasonNumber < seasons[j].SeasonNumber
+	})
+	if rule.KeepSeasons > 0 {
+		seasons = seasons[:max(0, len(seasons)-rule.KeepSeasons)]
+	}
+
+	return slices.DeleteFunc(seasons, func(season *sonarr.Season) bool {
+		var statistics sonarr.Statistics
+		if season.Statistics != nil {
+			statistics = *season.Statistics
+		}
+		if rule.Complete && (statistics.TotalEpisodeCount == 0 || statistics.EpisodeFileCount < statistics.TotalEpisodeCount) {
+			return true
+		}
+		if rule.Action == config.CleanupDeleteFiles {
+			return statistics.EpisodeFileCount == 0
+		}
+		return !season.Monitored
+	})
+}
+
+// cleanupReportText lists the actions by rule.
+func cleanupReportText(actions []*cleanupAction) string {
+	var text strings.Builder
+	var size int64
+	var lines int
+	fmt.Fprintf(&text, "%v Cleanup report: %d actions\n", CleanupIcon, len(actions))
+	for i, action := range actions {
+		if i == 0 || action.rule != actions[i-1].rule || action.action != actions[i-1].action {
+			fmt.Fprintf(&text, "\n%v - %v:\n", action.rule, action.action)
+		}
+		var seasonSize int64
+		if action.season.Statistics != nil {
+			seasonSize = action.season.Statistics.SizeOnDisk
+		}
+		if action.action == config.CleanupDeleteFiles {
+			size += seasonSize
+		}
+		if lines++; lines > cleanupMaxLines {
+			continue
+		}
+		if action.action == config.CleanupDeleteFiles {
+			fmt.Fprintf(&text, "%v (%v)\n", seasonTitle(action.series, action.season), utils.ByteCountSI(seasonSize))
+		} else {
+			fmt.Fprintf(&text, "%v\n", seasonTitle(action.series, action.season))
+		}
+	}
+	if lines > cleanupMaxLines {
+		fmt.Fprintf(&text, "\n... and %d more\n", lines-cleanupMaxLines)
+	}
+	if size > 0 {
+		fmt.Fprintf(&text, "\nDeleting files frees %v\n", utils.ByteCountSI(size))
+	}
+	fmt.Fprintf(&text, "\nNothing is changed until an admin approves.")
+	return text.String()
+}
+
+// handleCleanupCallback approves or dismisses a cleanup report. It works
+// independently of the active command.
+func (b *Bot) handleCleanupCallback(update tgbotapi.Update) {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	if !b.getConfig().AdminChatIDs[chatID] {
+		b.logger(chatID).Warn("Cleanup report answered by a non-admin")
+		return
+	}
+	data := update.CallbackQuery.Data
+	approve := strings.HasPrefix(data, CleanupApprove)
+	id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(data, CleanupApprove), CleanupDismiss))
+	if err != nil {
+		b.logger(chatID).Error("Cannot convert cleanup report ID to int", "error", err)
+		return
+	}
+
+	report := b.takeCleanupReport(id)
+	if report == nil {
+		edit := tgbotapi.NewEditMessageText(chatID, update.CallbackQuery.Message.MessageID, "This cleanup report is outdated or was already handled.")
+		b.sendMessage(edit)
+		return
+	}
+	user := update.SentFrom()
+	if !approve {
+		b.logger(chatID).Info("Cleanup report dismissed")
+		b.editCleanupReport(report, fmt.Sprintf("Cleanup report dismissed by %v", user))
+		return
+	}
+
+	b.logger(chatID).Info("Cleanup report approved", "actions", len(report.actions))
+	b.editCleanupReport(report, fmt.Sprintf("Cleanup approved by %v, running %d actions... please wait", user, len(report.actions)))
+
+	// The library may have changed since the report, actions the rules no
+	// longer select are skipped
+	current, err := b.evaluateCleanupRules(b.getConfig().CleanupRules)
+	if err != nil {
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.editCleanupReport(report, fmt.Sprintf("Cleanup approved by %v, but evaluating the rules again failed: %v", user, err))
+		return
+	}
+	stillSelected := make(map[string]bool, len(current))
+	for _, action := range current {
+		stillSelected[action.key()] = true
+	}
+
+	var failures strings.Builder
+	var done, skipped int
+	for _, action := range report.actions {
+		if !stillSelected[action.key()] {
+			skipped++
+			continue
+		}
+		var err error
+		if action.action == config.CleanupDeleteFiles {
+			err = b.deleteSeasonFiles(user, chatID, action.series.ID, action.season.SeasonNumber, action.fileIDs)
+		} else {
+			err = b.unmonitorSeason(user, chatID, action.series.ID, action.season.SeasonNumber)
+		}
+		if err != nil {
+			b.logger(chatID).Error("Sonarr request failed", "series", action.series.Title, "season", action.season.SeasonNumber, "error", err)
+			fmt.Fprintf(&failures, "%v: %v\n", seasonTitle(action.series, action.season), err)
+			continue
+		}
+		done++
+	}
+	text := fmt.Sprintf("Cleanup approved by %v: %d of %d actions done", user, done, len(report.actions))
+	if skipped > 0 {
+		text += fmt.Sprintf(", %d skipped because the rules no longer select them", skipped)
+	}
+	b.editCleanupReport(report, fmt.Sprintf("%v\n\n%v", text, failures.String()))
+}
+
+// takeCleanupReport returns the latest report if it has the ID and was not
+// handled yet, and marks it as handled.
+func (b *Bot) takeCleanupReport(id int) *cleanupReport {
+	b.muCleanupReport.Lock()
+	defer b.muCleanupReport.Unlock()
+	report := b.cleanupReport
+	if report == nil || report.id != id || report.handled {
+		return nil
+	}
+	report.handled = true
+	return report
+}
+
+// editCleanupReport replaces the report in all admin chats.
+func (b *Bot) editCleanupReport(report *cleanupReport, text string) {
+	for chatID, messageID := range report.messages {
+		b.sendMessage(tgbotapi.NewEditMessageText(chatID, messageID, text))
+	}
+}
+
+// unmonitorSeason unmonitors a season if it is monitored.
+func (b *Bot) unmonitorSeason(user *tgbotapi.User, chatID int64, seriesID int64, seasonNumber int) error {
+	series, err := b.getSonarrServer().GetSeriesByID(seriesID)
+	if err != nil {
+		return err
+	}
+	season := getSeasonByNumber(series, seasonNumber)
+	if season == nil || !season.Monitored {
+		return nil
+	}
+	season.Monitored = *starr.False()
+	if _, err := b.getSonarrServer().UpdateSeries(seriesToAddSeriesInput(series), *starr.False()); err != nil {
+		return err
+	}
+	b.auditAs(user, chatID, "unmonitored season", seasonTitle(series, season), "series_id", seriesID)
+	return nil
+}
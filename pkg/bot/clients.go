@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/discovery"
+	"github.com/woiza/telegram-bot-sonarr/pkg/mediaserver"
+	"github.com/woiza/telegram-bot-sonarr/pkg/sonarrapi"
+)
+
+// SonarrClient is the part of the Sonarr API used by the bot. It is
+// implemented by *sonarrapi.Client and by the fake in package bottest.
+type SonarrClient interface {
+	Ping() error
+	GetSystemStatusDetails() (*sonarrapi.SystemStatus, error)
+	GetSystemTasks() ([]*sonarrapi.Task, error)
+	GetHealth() ([]*sonarrapi.Health, error)
+	GetLogs(page, pageSize int, level string) (*sonarrapi.LogPage, error)
+	Lookup(term string) ([]*sonarr.Series, error)
+	GetSeries(tvdbID int64) ([]*sonarr.Series, error)
+	GetSeriesByID(seriesID int64) (*sonarr.Series, error)
+	AddSeries(series *sonarr.AddSeriesInput) (*sonarr.Series, error)
+	UpdateSeries(series *sonarr.AddSeriesInput, moveFiles bool) (*sonarr.Series, error)
+	DeleteSeries(seriesID int, deleteFiles bool, importExclude bool) error
+	GetSeriesEpisodes(getEpisode *sonarr.GetEpisode) ([]*sonarr.Episode, error)
+	GetSeriesEpisodeFiles(seriesID int64) ([]*sonarr.EpisodeFile, error)
+	DeleteEpisodeFile(episodeFileID int64) error
+	GetCalendar(filter sonarr.Calendar) ([]*sonarr.Episode, error)
+	GetQualityProfiles() ([]*sonarr.QualityProfile, error)
+	GetRootFolders() ([]*sonarr.RootFolder, error)
+	GetTags() ([]*starr.Tag, error)
+	GetTagDetails() ([]*sonarrapi.TagDetails, error)
+	AddTag(tag *starr.Tag) (*starr.Tag, error)
+	UpdateTag(tag *starr.Tag) (*starr.Tag, error)
+	DeleteTag(tagID int) error
+	SendCommand(cmd *sonarr.CommandRequest) (*sonarr.CommandResponse, error)
+	GetImportLists() ([]*sonarr.ImportListOutput, error)
+	PatchImportList(importListID int64, fields map[string]any) (*sonarr.ImportListOutput, error)
+	GetExclusions() ([]*sonarr.Exclusion, error)
+	DeleteExclusions(ids []int64) error
+	GetIndexers() ([]*sonarr.IndexerOutput, error)
+	GetIndexerStatus() ([]*sonarrapi.ProviderStatus, error)
+	TestSavedIndexer(indexerID int64) (*sonarrapi.ProviderTestResult, error)
+	TestAllIndexers() ([]*sonarrapi.ProviderTestResult, error)
+	PatchIndexer(indexerID int64, fields map[string]any) (*sonarr.IndexerOutput, error)
+	GetDownloadClients() ([]*sonarr.DownloadClientOutput, error)
+	GetDownloadClientStatus() ([]*sonarrapi.ProviderStatus, error)
+	TestSavedDownloadClient(downloadClientID int64) (*sonarrapi.ProviderTestResult, error)
+	TestAllDownloadClients() ([]*sonarrapi.ProviderTestResult, error)
+	PatchDownloadClient(downloadClientID int64, fields map[string]any) (*sonarr.DownloadClientOutput, error)
+}
+
+// TelegramSender sends and edits Telegram messages. It is implemented by
+// *tgbotapi.BotAPI and by the recorder in package bottest.
+type TelegramSender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// TelegramFiles downloads files sent to the bot, e.g. /importlibrary
+// documents. It is implemented by botAPIFiles and by the recorder in package
+// bottest.
+type TelegramFiles interface {
+	DownloadFile(fileID string) ([]byte, error)
+}
+
+// maxDownloadSize limits downloaded files, the Bot API serves up to 20 MB.
+const maxDownloadSize = 20 << 20
+
+// botAPIFiles downloads files via their Bot API file URL.
+type botAPIFiles struct {
+	api *tgbotapi.BotAPI
+}
+
+func (f botAPIFiles) DownloadFile(fileID string) ([]byte, error) {
+	fileURL, err := f.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{Timeout: time.Minute}
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		// The error contains the URL and with it the bot token
+		return nil, errors.New("downloading the file failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading the file failed: %v", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize))
+}
+
+// telegramError drops the request URL from transport errors of the Bot API,
+// as it contains the bot token.
+func telegramError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// DiscoveryProvider finds series for /discover. It is implemented by
+// *discovery.Trakt, *discovery.TMDB and *discovery.Fixture.
+type DiscoveryProvider interface {
+	Name() string
+	List(list discovery.List, limit int) ([]*discovery.Show, error)
+	Similar(tvdbID int64, limit int) ([]*discovery.Show, error)
+}
+
+// MediaServer reads the watch history shown in /library. It is implemented by
+// *mediaserver.Plex, *mediaserver.Jellyfin and by the fake in package
+// bottest.
+type MediaServer interface {
+	Name() string
+	Watched() (mediaserver.Watched, error)
+}
+
+var (
+	_ SonarrClient      = (*sonarrapi.Client)(nil)
+	_ TelegramSender    = (*tgbotapi.BotAPI)(nil)
+	_ TelegramFiles     = botAPIFiles{}
+	_ DiscoveryProvider = (*discovery.Trakt)(nil)
+	_ DiscoveryProvider = (*discovery.TMDB)(nil)
+	_ DiscoveryProvider = (*discovery.Fixture)(nil)
+	_ MediaServer       = (*mediaserver.Plex)(nil)
+	_ MediaServer       = (*mediaserver.Jellyfin)(nil)
+)
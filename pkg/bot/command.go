@@ -2,24 +2,49 @@ package bot
 
 import (
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/woiza/telegram-bot-sonarr/pkg/metrics"
 	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
 	"golift.io/starr"
 	"golift.io/starr/sonarr"
 )
 
-func (b *Bot) handleCommand(update tgbotapi.Update, s *sonarr.Sonarr) {
+// knownCommands limits the command label of the metrics, anything users type
+// is counted as "other".
+var knownCommands = map[string]bool{
+	"q": true, "query": true, "add": true, "series": true, "library": true, "l": true,
+	"delete": true, "remove": true, "d": true, "lists": true, "importlists": true,
+	"exclusions": true, "indexers": true, "indexer": true, "clients": true, "downloadclients": true,
+	"tags": true, "tag": true, "profiles": true, "profile": true, "qualityprofiles": true,
+	"settings": true, "bulkadd": true, "export": true, "importlibrary": true, "discover": true,
+	"cleanup": true, "clear": true, "cancel": true, "stop": true, "diskspace": true, "disk": true,
+	"free": true, "rootfolder": true, "rootfolders": true, "up": true, "upcoming": true, "rss": true,
+	"system": true, "systemstatus": true, "getid": true, "id": true, "start": true, "help": true,
+}
+
+func commandLabel(command string) string {
+	command = strings.ToLower(command)
+	if !knownCommands[command] {
+		return "other"
+	}
+	return command
+}
+
+func (b *Bot) handleCommand(update tgbotapi.Update, s SonarrClient) {
 
 	chatID, err := b.getChatID(update)
 	if err != nil {
-		fmt.Printf("Cannot handle command: %v", err)
+		slog.Warn("Cannot handle command", "update_id", update.UpdateID, "error", err)
 		return
 	}
 
 	msg := tgbotapi.NewMessage(chatID, "")
 
+	metrics.Commands.Inc(commandLabel(update.Message.Command()))
 	switch update.Message.Command() {
 
 	case "q", "query", "add", "Q", "Query", "Add":
@@ -34,6 +59,45 @@ func (b *Bot) handleCommand(update tgbotapi.Update, s *sonarr.Sonarr) {
 		b.setActiveCommand(chatID, DeleteSeriesCommand)
 		b.processDeleteCommand(update, chatID, s)
 
+	case "lists", "importlists":
+		b.setActiveCommand(chatID, ImportListsCommand)
+		b.processImportListsCommand(update, chatID, s, false)
+
+	case "exclusions":
+		b.setActiveCommand(chatID, ImportListsCommand)
+		b.processImportListsCommand(update, chatID, s, true)
+
+	case "indexers", "indexer":
+		b.setActiveCommand(chatID, ProvidersCommand)
+		b.processProvidersCommand(chatID, providerKindIndexer)
+
+	case "clients", "downloadclients":
+		b.setActiveCommand(chatID, ProvidersCommand)
+		b.processProvidersCommand(chatID, providerKindDownloadClient)
+
+	case "tags", "tag":
+		b.setActiveCommand(chatID, TagsCommand)
+		b.processTagsCommand(chatID)
+
+	case "profiles", "profile", "qualityprofiles":
+		b.setActiveCommand(chatID, ProfilesCommand)
+		b.processProfilesCommand(chatID)
+	case "settings":
+		b.setActiveCommand(chatID, SettingsCommand)
+		b.processSettingsCommand(chatID)
+	case "bulkadd":
+		b.setActiveCommand(chatID, BulkAddCommand)
+		b.processBulkAddCommand(update, chatID)
+	case "export":
+		b.processExportCommand(update, chatID)
+	case "importlibrary":
+		b.setActiveCommand(chatID, ImportLibraryCommand)
+		b.processImportLibraryCommand(chatID)
+	case "discover":
+		b.processDiscoverCommand(update, chatID)
+	case "cleanup":
+		b.processCleanupCommand(chatID)
+
 	case "clear", "cancel", "stop":
 		b.clearState(update)
 		msg.Text = "All commands have been cleared"
@@ -43,7 +107,7 @@ func (b *Bot) handleCommand(update tgbotapi.Update, s *sonarr.Sonarr) {
 		rootFolders, err := s.GetRootFolders()
 		if err != nil {
 			msg.Text = err.Error()
-			fmt.Println(err)
+			b.logger(chatID).Error("Sonarr request failed", "error", err)
 			b.sendMessage(msg)
 			break
 		}
@@ -61,7 +125,7 @@ func (b *Bot) handleCommand(update tgbotapi.Update, s *sonarr.Sonarr) {
 		upcoming, err := s.GetCalendar(calendar)
 		if err != nil {
 			msg.Text = err.Error()
-			fmt.Println(err)
+			b.logger(chatID).Error("Sonarr request failed", "error", err)
 			b.sendMessage(msg)
 			break
 		}
@@ -80,7 +144,7 @@ func (b *Bot) handleCommand(update tgbotapi.Update, s *sonarr.Sonarr) {
 		_, err := s.SendCommand(&command)
 		if err != nil {
 			msg.Text = err.Error()
-			fmt.Println(err)
+			b.logger(chatID).Error("Sonarr request failed", "error", err)
 			b.sendMessage(msg)
 			break
 		}
@@ -144,15 +208,8 @@ func (b *Bot) handleCommand(update tgbotapi.Update, s *sonarr.Sonarr) {
 	// 	b.sendMessage(msg)
 
 	case "system", "System", "systemstatus", "Systemstatus":
-		status, err := s.GetSystemStatus()
-		if err != nil {
-			msg.Text = err.Error()
-			fmt.Println(err)
-			b.sendMessage(msg)
-			break
-		}
-		msg.Text = prettyPrint(status)
-		b.sendMessage(msg)
+		b.setActiveCommand(chatID, SystemCommand)
+		b.processSystemCommand(update, chatID)
 
 	case "getid", "id":
 		msg.Text = fmt.Sprintf("Your user ID: %d", chatID)
@@ -161,16 +218,28 @@ func (b *Bot) handleCommand(update tgbotapi.Update, s *sonarr.Sonarr) {
 	default:
 		msg.Text = fmt.Sprintf("Hello %v!\n", update.Message.From)
 		msg.Text += "Here's a list of commands at your disposal:\n\n"
-		msg.Text += "/q [series] - searches a series \n"
+		msg.Text += "/q [--preset name] [series] - searches a series \n"
+		msg.Text += "/bulkadd [titles or IDs] - adds several series, one per line\n"
+		msg.Text += "/discover [trending|popular|anticipated|similar series] - finds new series\n"
 		msg.Text += "/library [series] - manage series(s)\n"
 		msg.Text += "/delete [series] - deletes a series\n"
+		msg.Text += "/lists - manage import lists\n"
+		msg.Text += "/exclusions [series] - manage import list exclusions\n"
+		msg.Text += "/export [json|csv] - exports your library\n"
+		msg.Text += "/importlibrary - adds the series of an export\n"
+		msg.Text += "/cleanup - evaluates the cleanup rules now (admins)\n"
+		msg.Text += "/tags - manage tags\n"
+		msg.Text += "/profiles - show quality profiles\n"
+		msg.Text += "/settings - your defaults for adding series\n"
+		msg.Text += "/indexers - show and test indexers\n"
+		msg.Text += "/clients - show and test download clients\n"
 		msg.Text += "/clear - deletes all sent commands\n"
 		msg.Text += "/free  - lists free disk space \n"
 		msg.Text += "/up\t\t\t\t - lists upcoming episodes in the next 30 days\n"
 		msg.Text += "/rss \t\t - performs a RSS sync\n"
 		//msg.Text += "/searchmonitored - searches all monitored series\n"
 		//msg.Text += "/updateall - updates metadata and rescans files/folders\n"
-		msg.Text += "/system - shows your Sonarr configuration\n"
+		msg.Text += "/system [tasks|health|logs [level]] - shows Sonarr status, tasks, health and logs\n"
 		msg.Text += "/id - shows your Telegram user ID"
 		b.sendMessage(msg)
 	}
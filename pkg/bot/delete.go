@@ -2,29 +2,34 @@ package bot
 
 import (
 	"fmt"
+	"log/slog"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
-	"golift.io/starr"
 	"golift.io/starr/sonarr"
 )
 
 const (
-	DeleteSeriesConfirm      = "DELETE_SERIES_SUBMIT"
-	DeleteSeriesCancel       = "DELETE_SERIES_CANCEL"
-	DeleteSeriesGoBack       = "DELETE_SERIES_GOBACK"
-	DeleteSeriesYes          = "DELETE_SERIES_YES"
-	DeleteSeriesTvdbID       = "DELETE_SERIES_TVDBID_"
-	DeleteSeriesFirstPage    = "DELETE_SERIES_FIRST_PAGE"
-	DeleteSeriesPreviousPage = "DELETE_SERIES_PREV_PAGE"
-	DeleteSeriesNextPage     = "DELETE_SERIES_NEXT_PAGE"
-	DeleteSeriesLastPage     = "DELETE_SERIES_LAST_PAGE"
+	DeleteSeriesConfirm       = "DELETE_SERIES_SUBMIT"
+	DeleteSeriesCancel        = "DELETE_SERIES_CANCEL"
+	DeleteSeriesGoBack        = "DELETE_SERIES_GOBACK"
+	DeleteSeriesYes           = "DELETE_SERIES_YES"
+	DeleteSeriesTvdbID        = "DELETE_SERIES_TVDBID_"
+	DeleteSeriesFirstPage     = "DELETE_SERIES_FIRST_PAGE"
+	DeleteSeriesPreviousPage  = "DELETE_SERIES_PREV_PAGE"
+	DeleteSeriesNextPage      = "DELETE_SERIES_NEXT_PAGE"
+	DeleteSeriesLastPage      = "DELETE_SERIES_LAST_PAGE"
+	DeleteSeriesToggleFiles   = "DELETE_SERIES_TOGGLE_FILES"
+	DeleteSeriesToggleExclude = "DELETE_SERIES_TOGGLE_EXCLUDE"
+	DeleteSeriesSearch        = "DELETE_SERIES_SEARCH"
+	DeleteSeriesRefine        = "DELETE_SERIES_REFINE"
 )
 
-func (b *Bot) processDeleteCommand(update tgbotapi.Update, chatID int64, s *sonarr.Sonarr) {
+func (b *Bot) processDeleteCommand(update tgbotapi.Update, chatID int64, s SonarrClient) {
 	msg := tgbotapi.NewMessage(chatID, "Handling delete command... please wait")
 	message, _ := b.sendMessage(msg)
 
@@ -35,7 +40,8 @@ func (b *Bot) processDeleteCommand(update tgbotapi.Update, chatID int64, s *sona
 		return
 	}
 	command := userDeleteSeries{
-		library: make(map[string]*sonarr.Series, len(series)),
+		library:     make(map[string]*sonarr.Series, len(series)),
+		deleteFiles: true,
 	}
 	for _, series := range series {
 		tvdbid := strconv.Itoa(int(series.TvdbID))
@@ -59,7 +65,8 @@ func (b *Bot) processDeleteCommand(update tgbotapi.Update, chatID int64, s *sona
 	}
 
 	//update.Message.Text = fmt.Sprintf("/q \"%s\"", update.Message.Text)
-	searchResults, err := s.Lookup("\"" + criteria + "\"")
+	term, _ := lookupTerm(criteria)
+	searchResults, err := s.Lookup(term)
 	if err != nil {
 		msg := tgbotapi.NewMessage(chatID, err.Error())
 		b.sendMessage(msg)
@@ -73,7 +80,7 @@ func (b *Bot) processDeleteCommand(update tgbotapi.Update, chatID int64, s *sona
 func (b *Bot) deleteSeries(update tgbotapi.Update) bool {
 	chatID, err := b.getChatID(update)
 	if err != nil {
-		fmt.Printf("Cannot delete Series: %v", err)
+		slog.Warn("Cannot delete Series", "update_id", update.UpdateID, "error", err)
 		return false
 	}
 
@@ -83,8 +90,22 @@ func (b *Bot) deleteSeries(update tgbotapi.Update) bool {
 	}
 
 	switch update.CallbackQuery.Data {
-	// ignore click on page number
 	case "current_page":
+		totalPages := (len(command.seriesForSelection) + b.getConfig().MaxItems - 1) / b.getConfig().MaxItems
+		b.askForPage(chatID, totalPages, func(page int) {
+			command.page = page
+			b.showDeleteSerieSelection(command)
+		})
+		return false
+	case DeleteSeriesSearch:
+		b.askForText(chatID, "Send a title to search your library for:", "e.g. breaking", func(text string) bool {
+			return b.handleDeleteSeriesSearch(command, text)
+		})
+		return false
+	case DeleteSeriesRefine:
+		b.askForText(chatID, "Send a new search (title, ID or link):", "e.g. breaking bad", func(text string) bool {
+			return b.handleDeleteSeriesRefine(command, text)
+		})
 		return false
 	case DeleteSeriesFirstPage:
 		command.page = 0
@@ -98,11 +119,17 @@ func (b *Bot) deleteSeries(update tgbotapi.Update) bool {
 		command.page++
 		return b.showDeleteSerieSelection(command)
 	case DeleteSeriesLastPage:
-		totalPages := (len(command.seriesForSelection) + b.Config.MaxItems - 1) / b.Config.MaxItems
+		totalPages := (len(command.seriesForSelection) + b.getConfig().MaxItems - 1) / b.getConfig().MaxItems
 		command.page = totalPages - 1
 		return b.showDeleteSerieSelection(command)
 	case DeleteSeriesConfirm:
 		return b.processSeriesSelectionForDelete(command)
+	case DeleteSeriesToggleFiles:
+		command.deleteFiles = !command.deleteFiles
+		return b.processSeriesSelectionForDelete(command)
+	case DeleteSeriesToggleExclude:
+		command.importExclude = !command.importExclude
+		return b.processSeriesSelectionForDelete(command)
 	case DeleteSeriesYes:
 		return b.handleDeleteSeriesYes(update, command)
 	case DeleteSeriesGoBack:
@@ -127,7 +154,7 @@ func (b *Bot) showDeleteSerieSelection(command *userDeleteSeries) bool {
 
 	// Pagination parameters
 	page := command.page
-	pageSize := b.Config.MaxItems
+	pageSize := b.getConfig().MaxItems
 	totalPages := (len(series) + pageSize - 1) / pageSize
 
 	// Calculate start and end index for the current page
@@ -144,6 +171,9 @@ func (b *Bot) showDeleteSerieSelection(command *userDeleteSeries) bool {
 
 		// Create button text with or without check mark
 		buttonText := series.Title
+		if command.lookup {
+			buttonText = searchResultLabel(series)
+		}
 		if isSelected {
 			buttonText += " \u2705"
 		}
@@ -176,16 +206,20 @@ func (b *Bot) showDeleteSerieSelection(command *userDeleteSeries) bool {
 		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, paginationButtons)
 	}
 
+	searchText, searchData := "\U0001F50D Search", DeleteSeriesSearch
+	if command.lookup {
+		searchText, searchData = "\U0001F50D Refine search", DeleteSeriesRefine
+	}
 	var keyboardConfirmCancel tgbotapi.InlineKeyboardMarkup
 	if len(command.selectedSeries) > 0 {
 		keyboardConfirmCancel = b.createKeyboard(
-			[]string{"Submit - Confirm Series", "Cancel - clear command"},
-			[]string{DeleteSeriesConfirm, DeleteSeriesCancel},
+			[]string{"Submit - Confirm Series", searchText, "Cancel - clear command"},
+			[]string{DeleteSeriesConfirm, searchData, DeleteSeriesCancel},
 		)
 	} else {
 		keyboardConfirmCancel = b.createKeyboard(
-			[]string{"Cancel - clear command"},
-			[]string{DeleteSeriesCancel},
+			[]string{searchText, "Cancel - clear command"},
+			[]string{searchData, DeleteSeriesCancel},
 		)
 	}
 
@@ -205,21 +239,61 @@ func (b *Bot) showDeleteSerieSelection(command *userDeleteSeries) bool {
 	return false
 }
 
+// handleDeleteSeriesSearch shows the library series matching text. Series
+// already selected stay selected.
+func (b *Bot) handleDeleteSeriesSearch(command *userDeleteSeries, text string) bool {
+	var library []*sonarr.Series
+	for _, series := range command.library {
+		library = append(library, series)
+	}
+	matches := filterSeriesByTitle(library, text)
+	if len(matches) == 0 {
+		msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("No series matching %q in your library", strings.TrimSpace(text)))
+		b.sendMessage(msg)
+		return false
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return utils.IgnoreArticles(strings.ToLower(matches[i].Title)) < utils.IgnoreArticles(strings.ToLower(matches[j].Title))
+	})
+	command.seriesForSelection = matches
+	command.lookup = false
+	command.page = 0
+	b.showDeleteSerieSelection(command)
+	return true
+}
+
+// handleDeleteSeriesRefine replaces the lookup results with a new Sonarr
+// lookup.
+func (b *Bot) handleDeleteSeriesRefine(command *userDeleteSeries, text string) bool {
+	term, _ := lookupTerm(strings.TrimSpace(text))
+	searchResults, err := b.getSonarrServer().Lookup(term)
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return true
+	}
+	if !slices.ContainsFunc(searchResults, func(series *sonarr.Series) bool { return series.ID != 0 }) {
+		msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("No series in your library matching %q", strings.TrimSpace(text)))
+		b.sendMessage(msg)
+		return false
+	}
+	b.handleDeleteSearchResults(searchResults, command)
+	return true
+}
+
 func (b *Bot) handleDeleteSearchResults(searchResults []*sonarr.Series, command *userDeleteSeries) {
 	if len(searchResults) == 0 {
 		b.sendMessageWithEdit(command, "No Series found matching your search criteria")
 		return
 	}
-	if len(searchResults) > 25 {
-		b.sendMessageWithEdit(command, "Result size too large, please narrow down your search criteria")
-		return
-	}
 
-	// if Series has a radarr ID, it's in the library
+	// if Series has a radarr ID, it's in the library. The library series is
+	// used, lookup results lack the season statistics.
 	var SeriesInLibrary []*sonarr.Series
 	for _, Series := range searchResults {
-		if Series.ID != 0 {
-			SeriesInLibrary = append(SeriesInLibrary, Series)
+		if librarySeries, exists := command.library[strconv.FormatInt(Series.TvdbID, 10)]; exists && Series.ID != 0 {
+			SeriesInLibrary = append(SeriesInLibrary, librarySeries)
 		}
 	}
 	if len(SeriesInLibrary) == 0 {
@@ -234,6 +308,8 @@ func (b *Bot) handleDeleteSearchResults(searchResults []*sonarr.Series, command
 		b.processSeriesSelectionForDelete(command)
 	} else {
 		command.seriesForSelection = SeriesInLibrary
+		command.lookup = true
+		command.page = 0
 		b.setDeleteSeriesState(command.chatID, command)
 		b.showDeleteSerieSelection(command)
 	}
@@ -242,13 +318,14 @@ func (b *Bot) processSeriesSelectionForDelete(command *userDeleteSeries) bool {
 	var keyboard tgbotapi.InlineKeyboardMarkup
 	var messageText strings.Builder
 	var disablePreview bool
+	filesLabel, excludeLabel := deleteOptionLabels(command.deleteFiles, command.importExclude)
 	switch len(command.selectedSeries) {
 	case 1:
 		keyboard = b.createKeyboard(
-			[]string{"Yes, delete this series", "Cancel - clear command", "\U0001F519"},
-			[]string{DeleteSeriesYes, DeleteSeriesCancel, DeleteSeriesGoBack},
+			[]string{filesLabel, excludeLabel, "Yes, delete this series", "Cancel - clear command", "\U0001F519"},
+			[]string{DeleteSeriesToggleFiles, DeleteSeriesToggleExclude, DeleteSeriesYes, DeleteSeriesCancel, DeleteSeriesGoBack},
 		)
-		fmt.Fprintf(&messageText, "Do you want to delete the following series including all files?\n\n")
+		fmt.Fprintf(&messageText, "Do you want to delete the following series?\n\n")
 		fmt.Fprintf(&messageText, "[%v](https://www.imdb.com/title/%v) \\- _%v_\n",
 			utils.Escape(command.selectedSeries[0].Title), command.selectedSeries[0].ImdbID, command.selectedSeries[0].Year)
 		disablePreview = false
@@ -256,17 +333,18 @@ func (b *Bot) processSeriesSelectionForDelete(command *userDeleteSeries) bool {
 		return b.showDeleteSerieSelection(command)
 	default:
 		keyboard = b.createKeyboard(
-			[]string{"Yes, delete these series", "Cancel - clear command", "\U0001F519"},
-			[]string{DeleteSeriesYes, DeleteSeriesCancel, DeleteSeriesGoBack},
+			[]string{filesLabel, excludeLabel, "Yes, delete these series", "Cancel - clear command", "\U0001F519"},
+			[]string{DeleteSeriesToggleFiles, DeleteSeriesToggleExclude, DeleteSeriesYes, DeleteSeriesCancel, DeleteSeriesGoBack},
 		)
 
-		fmt.Fprintf(&messageText, "Do you want to delete the following series including all files?\n\n")
+		fmt.Fprintf(&messageText, "Do you want to delete the following series?\n\n")
 		for _, Series := range command.selectedSeries {
 			fmt.Fprintf(&messageText, "[%v](https://www.imdb.com/title/%v) \\- _%v_\n",
 				utils.Escape(Series.Title), Series.ImdbID, Series.Year)
 		}
 		disablePreview = true
 	}
+	fmt.Fprintf(&messageText, "\n%v", utils.Escape(deleteOptionsText(command.selectedSeries, command.deleteFiles, command.importExclude)))
 
 	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
 		command.chatID,
@@ -285,22 +363,32 @@ func (b *Bot) processSeriesSelectionForDelete(command *userDeleteSeries) bool {
 }
 
 func (b *Bot) handleDeleteSeriesYes(update tgbotapi.Update, command *userDeleteSeries) bool {
-	for _, series := range command.selectedSeries {
-		err := b.SonarrServer.DeleteSeries(int(series.ID), *starr.True(), *starr.False())
-		if err != nil {
-			msg := tgbotapi.NewMessage(command.chatID, err.Error())
-			fmt.Println(err)
-			b.sendMessage(msg)
-			return false
-		}
-	}
-
 	deletedSeries := make([]string, len(command.selectedSeries))
 	for i, series := range command.selectedSeries {
 		deletedSeries[i] = series.Title
 	}
-
 	messageText := fmt.Sprintf("Deleted Series:\n- %v", strings.Join(deletedSeries, "\n- "))
+
+	if b.getConfig().DeleteGracePeriod > 0 {
+		selectedSeries, deleteFiles, importExclude := command.selectedSeries, command.deleteFiles, command.importExclude
+		pendingText := fmt.Sprintf("Deleting Series:\n- %v\n\n%v", strings.Join(deletedSeries, "\n- "),
+			deleteOptionsText(selectedSeries, deleteFiles, importExclude))
+		b.clearState(update)
+		b.scheduleDelete(command, pendingText, messageText,
+			func(user *tgbotapi.User) error {
+				return b.deleteSeriesWithOptions(user, command.chatID, selectedSeries, deleteFiles, importExclude)
+			})
+		return true
+	}
+
+	err := b.deleteSeriesWithOptions(b.updateUser(command.chatID), command.chatID, command.selectedSeries, command.deleteFiles, command.importExclude)
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+
 	editMsg := tgbotapi.NewEditMessageText(
 		command.chatID,
 		command.messageID,
@@ -312,6 +400,50 @@ func (b *Bot) handleDeleteSeriesYes(update tgbotapi.Update, command *userDeleteS
 	return true
 }
 
+// deleteSeriesWithOptions deletes the series, optionally including their files
+// and adding them to the import list exclusions, and records each deletion in
+// the audit log.
+func (b *Bot) deleteSeriesWithOptions(user *tgbotapi.User, chatID int64, series []*sonarr.Series, deleteFiles bool, importExclude bool) error {
+	for _, s := range series {
+		err := b.getSonarrServer().DeleteSeries(int(s.ID), deleteFiles, importExclude)
+		if err != nil {
+			return err
+		}
+		b.auditAs(user, chatID, "deleted series", s.Title, "series_id", s.ID, "delete_files", deleteFiles, "import_exclude", importExclude)
+	}
+	return nil
+}
+
+// deleteOptionLabels returns the labels of the buttons toggling the delete options.
+func deleteOptionLabels(deleteFiles bool, importExclude bool) (string, string) {
+	filesLabel := "Delete files " + UnmonitorIcon
+	if deleteFiles {
+		filesLabel = "Delete files " + MonitorIcon
+	}
+	excludeLabel := "Add import list exclusion " + UnmonitorIcon
+	if importExclude {
+		excludeLabel = "Add import list exclusion " + MonitorIcon
+	}
+	return filesLabel, excludeLabel
+}
+
+// deleteOptionsText describes the delete options and the disk space that
+// deleting the series reclaims.
+func deleteOptionsText(series []*sonarr.Series, deleteFiles bool, importExclude bool) string {
+	var text strings.Builder
+	if deleteFiles {
+		fmt.Fprintf(&text, "Files: deleted, %v reclaimed\n", utils.ByteCountSI(sizeOnDisk(series)))
+	} else {
+		fmt.Fprintf(&text, "Files: kept on disk\n")
+	}
+	if importExclude {
+		fmt.Fprintf(&text, "Import list exclusion: added\n")
+	} else {
+		fmt.Fprintf(&text, "Import list exclusion: not added\n")
+	}
+	return text.String()
+}
+
 func (b *Bot) handleDeleteSerieSelection(update tgbotapi.Update, command *userDeleteSeries) bool {
 	seriesIDStr := strings.TrimPrefix(update.CallbackQuery.Data, DeleteSeriesTvdbID)
 	series := command.library[seriesIDStr]
@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/discovery"
+)
+
+const (
+	DiscoverList          = "DISCOVER_LIST_"
+	DiscoverSimilar       = "DISCOVER_SIMILAR"
+	DiscoverSimilarSeries = "DISCOVER_SIMILAR_"
+	DiscoverAdd           = "DISCOVER_ADD_"
+	DiscoverGoBack        = "DISCOVER_GOBACK"
+	DiscoverCancel        = "DISCOVER_CANCEL"
+	DiscoverSimilarPrompt = "Send the title of a series in your library:"
+	AddIcon               = "\u2795" // Plus
+	discoverMaxShows      = 20
+)
+
+var discoverListLabels = map[discovery.List]string{
+	discovery.Trending:    "\U0001F525 Trending",    // Fire
+	discovery.Popular:     "\u2B50 Popular",         // Star
+	discovery.Anticipated: "\U0001F4C5 Anticipated", // Calendar
+}
+
+type userDiscover struct {
+	library   map[int64]*sonarr.Series // by TVDB ID
+	title     string
+	shows     []*discovery.Show
+	chatID    int64
+	messageID int
+}
+
+func (b *Bot) processDiscoverCommand(update tgbotapi.Update, chatID int64) {
+	if b.Discovery == nil {
+		msg := tgbotapi.NewMessage(chatID, "Discovery is not configured, see SBOT_DISCOVER_PROVIDER")
+		b.sendMessage(msg)
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, "Handling discover command... please wait")
+	message, _ := b.sendMessage(msg)
+	command := userDiscover{
+		chatID:    message.Chat.ID,
+		messageID: message.MessageID,
+	}
+
+	library, err := b.getSonarrServer().GetSeries(0)
+	if err != nil {
+		msg.Text = err.Error()
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	command.library = make(map[int64]*sonarr.Series, len(library))
+	for _, series := range library {
+		command.library[series.TvdbID] = series
+	}
+	b.setActiveCommand(chatID, DiscoverCommand)
+
+	// /discover trending|popular|anticipated|similar [series]
+	name, rest, _ := strings.Cut(strings.TrimSpace(update.Message.CommandArguments()), " ")
+	if name = strings.ToLower(name); name == "similar" && strings.TrimSpace(rest) != "" {
+		if !b.handleDiscoverSimilarTitle(&command, rest) {
+			b.sendMessageWithEdit(&command, fmt.Sprintf("No series matching %q in your library", strings.TrimSpace(rest)))
+			b.clearChatState(chatID)
+		}
+		return
+	}
+	for _, list := range discovery.Lists {
+		if name == string(list) {
+			b.showDiscoverList(&command, list)
+			return
+		}
+	}
+	b.showDiscoverMenu(&command)
+}
+
+func (b *Bot) discover(update tgbotapi.Update) bool {
+	chatID, err := b.getChatID(update)
+	if err != nil {
+		slog.Warn("Cannot discover series", "update_id", update.UpdateID, "error", err)
+		return false
+	}
+
+	command, exists := b.getDiscoverState(chatID)
+	if !exists {
+		return false
+	}
+	data := update.CallbackQuery.Data
+	switch {
+	case data == DiscoverGoBack:
+		return b.showDiscoverMenu(command)
+	case data == DiscoverCancel:
+		b.clearState(update)
+		b.sendMessageWithEdit(command, CommandsCleared)
+		return false
+	case data == DiscoverSimilar:
+		b.askForText(chatID, DiscoverSimilarPrompt, "e.g. Breaking Bad", func(text string) bool {
+			return b.handleDiscoverSimilarTitle(command, text)
+		})
+		return false
+	case strings.HasPrefix(data, DiscoverList):
+		return b.showDiscoverList(command, discovery.List(strings.TrimPrefix(data, DiscoverList)))
+	case strings.HasPrefix(data, DiscoverSimilarSeries):
+		tvdbID, _ := strconv.ParseInt(strings.TrimPrefix(data, DiscoverSimilarSeries), 10, 64)
+		if series := command.library[tvdbID]; series != nil {
+			return b.showDiscoverSimilar(command, series)
+		}
+		return b.showDiscoverMenu(command)
+	case strings.HasPrefix(data, DiscoverAdd):
+		tvdbID, _ := strconv.ParseInt(strings.TrimPrefix(data, DiscoverAdd), 10, 64)
+		return b.handleDiscoverAdd(command, tvdbID)
+	}
+	return false
+}
+
+func (b *Bot) showDiscoverMenu(command *userDiscover) bool {
+	var buttonLabels []string
+	var buttonData []string
+	for _, list := range discovery.Lists {
+		buttonLabels = append(buttonLabels, discoverListLabels[list])
+		buttonData = append(buttonData, DiscoverList+string(list))
+	}
+	buttonLabels = append(buttonLabels, "\U0001F517 Similar to a series in your library", "Cancel - clear command")
+	buttonData = append(buttonData, DiscoverSimilar, DiscoverCancel)
+
+	b.setDiscoverState(command.chatID, command)
+	b.sendMessageWithEditAndKeyboard(command, b.createKeyboard(buttonLabels, buttonData),
+		fmt.Sprintf("Discover series on %v:", b.Discovery.Name()))
+	return false
+}
+
+func (b *Bot) showDiscoverList(command *userDiscover, list discovery.List) bool {
+	label, ok := discoverListLabels[list]
+	if !ok {
+		return b.showDiscoverMenu(command)
+	}
+	shows, err := b.Discovery.List(list, discoverMaxShows)
+	if err != nil {
+		b.logger(command.chatID).Error("Discovery request failed", "provider", b.Discovery.Name(), "list", list, "error", err)
+		b.sendMessageWithEdit(command, fmt.Sprintf("%v request failed: %v", b.Discovery.Name(), err))
+		b.clearChatState(command.chatID)
+		return false
+	}
+	command.title = fmt.Sprintf("%v on %v", label, b.Discovery.Name())
+	command.shows = shows
+	return b.showDiscoverShows(command)
+}
+
+// handleDiscoverSimilarTitle shows the series similar to the library series
+// matching title, or asks which one is meant. It returns false if no
+// series matches.
+func (b *Bot) handleDiscoverSimilarTitle(command *userDiscover, title string) bool {
+	title = strings.ToLower(strings.TrimSpace(title))
+	var matches []*sonarr.Series
+	for _, series := range command.library {
+		if strings.Contains(strings.ToLower(series.Title), title) {
+			matches = append(matches, series)
+		}
+	}
+	if len(matches) == 0 {
+		return false
+	}
+	if len(matches) == 1 {
+		b.showDiscoverSimilar(command, matches[0])
+		return true
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Title < matches[j].Title
+	})
+	var buttonLabels []string
+	var buttonData []string
+	for i, series := range matches {
+		if i == b.getConfig().MaxItems {
+			break
+		}
+		buttonLabels = append(buttonLabels, fmt.Sprintf("%v (%d)", series.Title, series.Year))
+		buttonData = append(buttonData, DiscoverSimilarSeries+strconv.FormatInt(series.TvdbID, 10))
+	}
+	buttonLabels = append(buttonLabels, "\U0001F519")
+	buttonData = append(buttonData, DiscoverGoBack)
+
+	b.setDiscoverState(command.chatID, command)
+	b.sendMessageWithEditAndKeyboard(command, b.createKeyboard(buttonLabels, buttonData),
+		fmt.Sprintf("%d series in your library match, which one do you mean?", len(matches)))
+	return true
+}
+
+func (b *Bot) showDiscoverSimilar(command *userDiscover, series *sonarr.Series) bool {
+	shows, err := b.Discovery.Similar(series.TvdbID, discoverMaxShows)
+	if err != nil {
+		b.logger(command.chatID).Error("Discovery request failed", "provider", b.Discovery.Name(), "tvdb_id", series.TvdbID, "error", err)
+		b.sendMessageWithEdit(command, fmt.Sprintf("%v request failed: %v", b.Discovery.Name(), err))
+		b.clearChatState(command.chatID)
+		return false
+	}
+	command.title = fmt.Sprintf("Similar to %v on %v", series.Title, b.Discovery.Name())
+	command.shows = shows
+	return b.showDiscoverShows(command)
+}
+
+// showDiscoverShows lists the shows found. Shows that are not in the library
+// get a button to add them.
+func (b *Bot) showDiscoverShows(command *userDiscover) bool {
+	var text strings.Builder
+	var buttonLabels []string
+	var buttonData []string
+	var inLibrary bool
+
+	fmt.Fprintf(&text, "%v:\n\n", command.title)
+	if len(command.shows) == 0 {
+		fmt.Fprintf(&text, "No series found\n")
+	}
+	for _, show := range command.shows {
+		if command.library[show.TvdbID] != nil {
+			fmt.Fprintf(&text, "%v %v (%d)\n", InLibraryIcon, show.Title, show.Year)
+			inLibrary = true
+			continue
+		}
+		fmt.Fprintf(&text, "%v (%d)\n", show.Title, show.Year)
+		buttonLabels = append(buttonLabels, fmt.Sprintf("%v %v (%d)", AddIcon, show.Title, show.Year))
+		buttonData = append(buttonData, DiscoverAdd+strconv.FormatInt(show.TvdbID, 10))
+	}
+	if inLibrary {
+		fmt.Fprintf(&text, "\n%v already in your library\n", InLibraryIcon)
+	}
+	buttonLabels = append(buttonLabels, "\U0001F519", "Cancel - clear command")
+	buttonData = append(buttonData, DiscoverGoBack, DiscoverCancel)
+
+	b.setDiscoverState(command.chatID, command)
+	b.sendMessageWithEditAndKeyboard(command, b.createKeyboard(buttonLabels, buttonData), text.String())
+	return false
+}
+
+// showDiscoverShowsAgain returns from the add wizard to the shows found.
+func (b *Bot) showDiscoverShowsAgain(chatID int64) bool {
+	command, exists := b.getDiscoverState(chatID)
+	if !exists {
+		return false
+	}
+	b.setActiveCommand(chatID, DiscoverCommand)
+	return b.showDiscoverShows(command)
+}
+
+// handleDiscoverAdd looks up a show in Sonarr and continues with the add
+// wizard.
+func (b *Bot) handleDiscoverAdd(command *userDiscover, tvdbID int64) bool {
+	results, err := b.getSonarrServer().Lookup(fmt.Sprintf("tvdb:%d", tvdbID))
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	if len(results) == 0 {
+		msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("Sonarr does not know TVDB ID %d", tvdbID))
+		b.sendMessage(msg)
+		return false
+	}
+	addCommand := userAddSeries{
+		searchResults: map[string]*sonarr.Series{strconv.FormatInt(tvdbID, 10): results[0]},
+		series:        results[0],
+		discover:      true,
+		chatID:        command.chatID,
+		messageID:     command.messageID,
+	}
+	b.setDiscoverState(command.chatID, command)
+	b.setActiveCommand(command.chatID, AddSeriesCommand)
+	return b.showAddSeriesDetails(&addCommand)
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/metrics"
+)
+
+// maxPollAge is the time after which the bot is considered wedged if long
+// polling did not succeed.
+const maxPollAge = 3 * time.Minute
+
+type healthStatus struct {
+	Status             string     `json:"status"`
+	Telegram           string     `json:"telegram"`
+	Sonarr             string     `json:"sonarr"`
+	LastSuccessfulPoll *time.Time `json:"lastSuccessfulPoll,omitempty"`
+	LastUpdate         *time.Time `json:"lastUpdate,omitempty"`
+}
+
+// ListenForHealth serves /healthz and /metrics on addr until ctx is cancelled.
+func (b *Bot) ListenForHealth(ctx context.Context, addr string) {
+	metrics.NewGaugeFunc("sbot_conversation_states",
+		"Active conversation states, by state map.", "state", b.conversationStates)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", b.handleHealth)
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	go func() {
+		slog.Info("Serving health and metrics", "address", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Health server stopped", "error", err)
+		}
+	}()
+}
+
+func (b *Bot) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{
+		Status:   "ok",
+		Telegram: "ok",
+		Sonarr:   "ok",
+	}
+	// Until the first poll succeeds, the bot is healthy for maxPollAge
+	lastPoll := b.started
+	if poll, ok := b.lastPoll.Load().(time.Time); ok {
+		status.LastSuccessfulPoll = &poll
+		lastPoll = poll
+	}
+	if lastUpdate, ok := b.lastUpdate.Load().(time.Time); ok {
+		status.LastUpdate = &lastUpdate
+	}
+
+	if _, err := b.Bot.GetMe(); err != nil {
+		status.Status = "unhealthy"
+		status.Telegram = telegramError(err).Error()
+	}
+	if err := b.getSonarrServer().Ping(); err != nil {
+		status.Status = "unhealthy"
+		status.Sonarr = err.Error()
+	}
+	// In webhook mode updates arrive only when there are any
+	if b.getConfig().WebhookURL == "" && time.Since(lastPoll) > maxPollAge {
+		status.Status = "unhealthy"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+func (b *Bot) conversationStates() map[string]float64 {
+	b.muActiveCommand.Lock()
+	activeCommands := len(b.ActiveCommand)
+	b.muActiveCommand.Unlock()
+
+	b.muAddSeriesStates.Lock()
+	addSeriesStates := len(b.AddSeriesStates)
+	b.muAddSeriesStates.Unlock()
+
+	b.muDeleteSeriesStates.Lock()
+	deleteSeriesStates := len(b.DeleteSeriesStates)
+	b.muDeleteSeriesStates.Unlock()
+
+	b.muLibraryStates.Lock()
+	libraryStates := len(b.LibraryStates)
+	b.muLibraryStates.Unlock()
+
+	b.muImportListStates.Lock()
+	importListStates := len(b.ImportListStates)
+	b.muImportListStates.Unlock()
+
+	b.muProviderStates.Lock()
+	providerStates := len(b.ProviderStates)
+	b.muProviderStates.Unlock()
+
+	b.muSystemStates.Lock()
+	systemStates := len(b.SystemStates)
+	b.muSystemStates.Unlock()
+
+	b.muTagStates.Lock()
+	tagStates := len(b.TagStates)
+	b.muTagStates.Unlock()
+
+	b.muTextInputs.Lock()
+	textInputs := len(b.textInputs)
+	b.muTextInputs.Unlock()
+
+	b.muProfileStates.Lock()
+	profileStates := len(b.ProfileStates)
+	b.muProfileStates.Unlock()
+
+	b.muSettingsStates.Lock()
+	settingsStates := len(b.SettingsStates)
+	b.muSettingsStates.Unlock()
+
+	b.muBulkAddStates.Lock()
+	bulkAddStates := len(b.BulkAddStates)
+	b.muBulkAddStates.Unlock()
+
+	b.muImportLibraryStates.Lock()
+	importLibraryStates := len(b.ImportLibraryStates)
+	b.muImportLibraryStates.Unlock()
+
+	b.muDiscoverStates.Lock()
+	discoverStates := len(b.DiscoverStates)
+	b.muDiscoverStates.Unlock()
+
+	return map[string]float64{
+		"active_command": float64(activeCommands),
+		"add_series":     float64(addSeriesStates),
+		"delete_series":  float64(deleteSeriesStates),
+		"library":        float64(libraryStates),
+		"import_lists":   float64(importListStates),
+		"providers":      float64(providerStates),
+		"system":         float64(systemStates),
+		"tags":           float64(tagStates),
+		"text_input":     float64(textInputs),
+		"profiles":       float64(profileStates),
+		"settings":       float64(settingsStates),
+		"bulk_add":       float64(bulkAddStates),
+		"import_library": float64(importLibraryStates),
+		"discover":       float64(discoverStates),
+	}
+}
@@ -0,0 +1,439 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
+)
+
+const (
+	ImportListsList             = "IMPORTLISTS_LIST_"
+	ImportListsToggleAutoAdd    = "IMPORTLISTS_TOGGLE_AUTOADD"
+	ImportListsSync             = "IMPORTLISTS_SYNC"
+	ImportListsExclusions       = "IMPORTLISTS_EXCLUSIONS"
+	ImportListsExclusion        = "IMPORTLISTS_EXCLUSION_"
+	ImportListsExclusionRemove  = "IMPORTLISTS_EXCLUSION_REMOVE"
+	ImportListsExclusionsGoBack = "IMPORTLISTS_EXCLUSIONS_GOBACK"
+	ImportListsGoBack           = "IMPORTLISTS_GOBACK"
+	ImportListsCancel           = "IMPORTLISTS_CANCEL"
+	ImportListsFirstPage        = "IMPORTLISTS_FIRST_PAGE"
+	ImportListsPreviousPage     = "IMPORTLISTS_PREV_PAGE"
+	ImportListsNextPage         = "IMPORTLISTS_NEXT_PAGE"
+	ImportListsLastPage         = "IMPORTLISTS_LAST_PAGE"
+)
+
+// Long titles are cut in exclusion buttons
+const importListExclusionsMaxTitle = 40
+
+type userImportLists struct {
+	importLists     []*sonarr.ImportListOutput
+	qualityProfiles []*sonarr.QualityProfile
+	importList      *sonarr.ImportListOutput
+	exclusions      []*sonarr.Exclusion // exclusions matching filter
+	exclusion       *sonarr.Exclusion
+	filter          string
+	chatID          int64
+	messageID       int
+	page            int
+}
+
+// processImportListsCommand shows the import lists, or with showExclusions the
+// import list exclusions matching the command arguments.
+func (b *Bot) processImportListsCommand(update tgbotapi.Update, chatID int64, s SonarrClient, showExclusions bool) {
+	msg := tgbotapi.NewMessage(chatID, "Handling import lists command... please wait")
+	message, _ := b.sendMessage(msg)
+
+	importLists, err := s.GetImportLists()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	qualityProfiles, err := s.GetQualityProfiles()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	sort.SliceStable(importLists, func(i, j int) bool {
+		return strings.ToLower(importLists[i].Name) < strings.ToLower(importLists[j].Name)
+	})
+
+	command := userImportLists{
+		importLists:     importLists,
+		qualityProfiles: qualityProfiles,
+		chatID:          message.Chat.ID,
+		messageID:       message.MessageID,
+	}
+	b.setImportListState(chatID, &command)
+
+	if showExclusions {
+		command.filter = update.Message.CommandArguments()
+		if !b.loadImportListExclusions(&command) {
+			return
+		}
+		b.showImportListExclusions(&command)
+		return
+	}
+	b.showImportLists(&command)
+}
+
+func (b *Bot) importLists(update tgbotapi.Update) bool {
+	chatID, err := b.getChatID(update)
+	if err != nil {
+		slog.Warn("Cannot manage import lists", "update_id", update.UpdateID, "error", err)
+		return false
+	}
+
+	command, exists := b.getImportListState(chatID)
+	if !exists {
+		return false
+	}
+	switch update.CallbackQuery.Data {
+	case "current_page":
+		totalPages := (len(command.exclusions) + b.getConfig().MaxItems - 1) / b.getConfig().MaxItems
+		b.askForPage(chatID, totalPages, func(page int) {
+			command.page = page
+			b.showImportListExclusions(command)
+		})
+		return false
+	case ImportListsFirstPage:
+		command.page = 0
+		return b.showImportListExclusions(command)
+	case ImportListsPreviousPage:
+		if command.page > 0 {
+			command.page--
+		}
+		return b.showImportListExclusions(command)
+	case ImportListsNextPage:
+		command.page++
+		return b.showImportListExclusions(command)
+	case ImportListsLastPage:
+		totalPages := (len(command.exclusions) + b.getConfig().MaxItems - 1) / b.getConfig().MaxItems
+		command.page = totalPages - 1
+		return b.showImportListExclusions(command)
+	case ImportListsToggleAutoAdd:
+		return b.handleImportListToggleAutoAdd(command)
+	case ImportListsSync:
+		return b.handleImportListsSync(command)
+	case ImportListsExclusions:
+		command.page = 0
+		if !b.loadImportListExclusions(command) {
+			return false
+		}
+		return b.showImportListExclusions(command)
+	case ImportListsExclusionRemove:
+		return b.handleImportListExclusionRemove(command)
+	case ImportListsExclusionsGoBack:
+		command.exclusion = nil
+		return b.showImportListExclusions(command)
+	case ImportListsGoBack:
+		command.importList = nil
+		return b.showImportLists(command)
+	case ImportListsCancel:
+		b.clearState(update)
+		b.sendMessageWithEdit(command, CommandsCleared)
+		return false
+	default:
+		if strings.HasPrefix(update.CallbackQuery.Data, ImportListsList) {
+			return b.handleImportListSelection(update, command)
+		}
+		if strings.HasPrefix(update.CallbackQuery.Data, ImportListsExclusion) {
+			return b.handleImportListExclusionSelection(update, command)
+		}
+		return false
+	}
+}
+
+func (b *Bot) showImportLists(command *userImportLists) bool {
+	var text strings.Builder
+	var buttonLabels []string
+	var buttonData []string
+
+	fmt.Fprintf(&text, "*Import Lists*\n\n")
+	if len(command.importLists) == 0 {
+		fmt.Fprintf(&text, "No import lists configured\n")
+	}
+	for _, importList := range command.importLists {
+		text.WriteString(b.importListText(command, importList))
+		text.WriteString("\n")
+		buttonLabels = append(buttonLabels, importList.Name)
+		buttonData = append(buttonData, ImportListsList+strconv.FormatInt(importList.ID, 10))
+	}
+	buttonLabels = append(buttonLabels, "\U0001F504 Sync all lists", "\U0001F6AB Exclusions", "Cancel - clear command")
+	buttonData = append(buttonData, ImportListsSync, ImportListsExclusions, ImportListsCancel)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		text.String(),
+		b.createKeyboard(buttonLabels, buttonData),
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setImportListState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+// importListText describes an import list in MarkdownV2.
+func (b *Bot) importListText(command *userImportLists, importList *sonarr.ImportListOutput) string {
+	var text strings.Builder
+	autoAddIcon := UnmonitorIcon
+	if importList.EnableAutomaticAdd {
+		autoAddIcon = MonitorIcon
+	}
+	profile := "unknown"
+	if qualityProfile := getQualityProfileByID(command.qualityProfiles, importList.QualityProfileID); qualityProfile != nil {
+		profile = qualityProfile.Name
+	}
+	fmt.Fprintf(&text, "*%v* \\(%v\\)\n", utils.Escape(importList.Name), utils.Escape(importList.ImplementationName))
+	fmt.Fprintf(&text, "Automatic add: %v\n", autoAddIcon)
+	fmt.Fprintf(&text, "Monitor: %v\n", utils.Escape(importList.ShouldMonitor))
+	fmt.Fprintf(&text, "Root folder: %v\n", utils.Escape(importList.RootFolderPath))
+	fmt.Fprintf(&text, "Quality profile: %v\n", utils.Escape(profile))
+	return text.String()
+}
+
+func (b *Bot) handleImportListSelection(update tgbotapi.Update, command *userImportLists) bool {
+	importListID, err := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, ImportListsList), 10, 64)
+	if err != nil {
+		b.logger(command.chatID).Error("Cannot convert import list ID to int", "error", err)
+		return false
+	}
+	for _, importList := range command.importLists {
+		if importList.ID == importListID {
+			command.importList = importList
+		}
+	}
+	if command.importList == nil {
+		return b.showImportLists(command)
+	}
+	return b.showImportList(command)
+}
+
+func (b *Bot) showImportList(command *userImportLists) bool {
+	importList := command.importList
+	var text strings.Builder
+	text.WriteString(b.importListText(command, importList))
+	fmt.Fprintf(&text, "Series type: %v\n", utils.Escape(importList.SeriesType))
+	fmt.Fprintf(&text, "Season folder: %v\n", utils.Escape(strconv.FormatBool(importList.SeasonFolder)))
+	if importList.MinRefreshInterval != "" {
+		fmt.Fprintf(&text, "Refresh interval: %v\n", utils.Escape(importList.MinRefreshInterval))
+	}
+
+	toggleLabel := "Enable automatic add"
+	if importList.EnableAutomaticAdd {
+		toggleLabel = "Disable automatic add"
+	}
+	keyboard := b.createKeyboard(
+		[]string{toggleLabel, "\U0001F504 Sync lists now", "\U0001F519"},
+		[]string{ImportListsToggleAutoAdd, ImportListsSync, ImportListsGoBack},
+	)
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		text.String(),
+		keyboard,
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setImportListState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+func (b *Bot) handleImportListToggleAutoAdd(command *userImportLists) bool {
+	if command.importList == nil {
+		return b.showImportLists(command)
+	}
+	enable := !command.importList.EnableAutomaticAdd
+	updated, err := b.getSonarrServer().PatchImportList(command.importList.ID, map[string]any{"enableAutomaticAdd": enable})
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	action := "disabled import list automatic add"
+	if enable {
+		action = "enabled import list automatic add"
+	}
+	b.audit(command.chatID, action, command.importList.Name, "import_list_id", command.importList.ID)
+
+	for i, importList := range command.importLists {
+		if importList.ID == updated.ID {
+			command.importLists[i] = updated
+		}
+	}
+	command.importList = updated
+	return b.showImportList(command)
+}
+
+func (b *Bot) handleImportListsSync(command *userImportLists) bool {
+	_, err := b.getSonarrServer().SendCommand(&sonarr.CommandRequest{Name: "ImportListSync"})
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	msg := tgbotapi.NewMessage(command.chatID, "Import list sync started")
+	b.sendMessage(msg)
+	return false
+}
+
+// loadImportListExclusions fetches the exclusions and applies the filter.
+func (b *Bot) loadImportListExclusions(command *userImportLists) bool {
+	exclusions, err := b.getSonarrServer().GetExclusions()
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	sort.SliceStable(exclusions, func(i, j int) bool {
+		return utils.IgnoreArticles(strings.ToLower(exclusions[i].Title)) < utils.IgnoreArticles(strings.ToLower(exclusions[j].Title))
+	})
+	command.exclusions = filterExclusions(exclusions, command.filter)
+	b.setImportListState(command.chatID, command)
+	return true
+}
+
+// filterExclusions returns the exclusions whose title contains filter or whose
+// TVDB ID equals filter.
+func filterExclusions(exclusions []*sonarr.Exclusion, filter string) []*sonarr.Exclusion {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if filter == "" {
+		return exclusions
+	}
+	var filtered []*sonarr.Exclusion
+	for _, exclusion := range exclusions {
+		if strings.Contains(strings.ToLower(exclusion.Title), filter) || strconv.FormatInt(exclusion.TVDBID, 10) == filter {
+			filtered = append(filtered, exclusion)
+		}
+	}
+	return filtered
+}
+
+func (b *Bot) showImportListExclusions(command *userImportLists) bool {
+	exclusions := command.exclusions
+
+	// Pagination parameters
+	page := command.page
+	pageSize := b.getConfig().MaxItems
+	totalPages := (len(exclusions) + pageSize - 1) / pageSize
+	if page >= totalPages {
+		page = max(totalPages-1, 0)
+		command.page = page
+	}
+	startIndex := page * pageSize
+	endIndex := min((page+1)*pageSize, len(exclusions))
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "*Import List Exclusions*\n\n")
+	if command.filter != "" {
+		fmt.Fprintf(&text, "Search: %v\n", utils.Escape(command.filter))
+	}
+	switch {
+	case len(exclusions) == 0:
+		fmt.Fprintf(&text, "No exclusions found\n")
+	default:
+		fmt.Fprintf(&text, "%d exclusions \\- select one to remove it\n", len(exclusions))
+	}
+
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	for _, exclusion := range exclusions[startIndex:endIndex] {
+		title := exclusion.Title
+		if len([]rune(title)) > importListExclusionsMaxTitle {
+			title = string([]rune(title)[:importListExclusionsMaxTitle]) + "…"
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(title, ImportListsExclusion+strconv.FormatInt(exclusion.ID, 10)),
+		))
+	}
+	if totalPages > 1 {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, paginationButtons(page, totalPages,
+			ImportListsFirstPage, ImportListsPreviousPage, ImportListsNextPage, ImportListsLastPage))
+	}
+	keyboardGoBack := b.createKeyboard(
+		[]string{"Cancel - clear command", "\U0001F519"},
+		[]string{ImportListsCancel, ImportListsGoBack},
+	)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, keyboardGoBack.InlineKeyboard...)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		text.String(),
+		keyboard,
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setImportListState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+func (b *Bot) handleImportListExclusionSelection(update tgbotapi.Update, command *userImportLists) bool {
+	exclusionID, err := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, ImportListsExclusion), 10, 64)
+	if err != nil {
+		b.logger(command.chatID).Error("Cannot convert exclusion ID to int", "error", err)
+		return false
+	}
+	command.exclusion = nil
+	for _, exclusion := range command.exclusions {
+		if exclusion.ID == exclusionID {
+			command.exclusion = exclusion
+		}
+	}
+	if command.exclusion == nil {
+		return b.showImportListExclusions(command)
+	}
+
+	text := fmt.Sprintf("Remove the import list exclusion for *%v* \\(TVDB %d\\)?\n\nImport lists can then add the series again\\.",
+		utils.Escape(command.exclusion.Title), command.exclusion.TVDBID)
+	keyboard := b.createKeyboard(
+		[]string{"Yes, remove exclusion", "\U0001F519"},
+		[]string{ImportListsExclusionRemove, ImportListsExclusionsGoBack},
+	)
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		text,
+		keyboard,
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setImportListState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+func (b *Bot) handleImportListExclusionRemove(command *userImportLists) bool {
+	if command.exclusion == nil {
+		return b.showImportListExclusions(command)
+	}
+	err := b.getSonarrServer().DeleteExclusions([]int64{command.exclusion.ID})
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	b.audit(command.chatID, "removed import list exclusion", command.exclusion.Title, "tvdb_id", command.exclusion.TVDBID)
+	command.exclusion = nil
+	if !b.loadImportListExclusions(command) {
+		return false
+	}
+	return b.showImportListExclusions(command)
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// textInput is a ForceReply prompt waiting for the user to type an answer.
+// handle returns false if the answer is invalid, the prompt is then sent
+// again.
+type textInput struct {
+	chatID      int64
+	messageID   int
+	prompt      string
+	placeholder string
+	handle      func(text string) bool
+}
+
+// askForText sends prompt as a ForceReply message. The next text message of
+// the chat is passed to handle instead of starting a search. Commands and
+// button presses cancel the prompt.
+func (b *Bot) askForText(chatID int64, prompt string, placeholder string, handle func(text string) bool) {
+	b.cancelTextInput(chatID)
+	msg := tgbotapi.NewMessage(chatID, prompt)
+	msg.ReplyMarkup = tgbotapi.ForceReply{
+		ForceReply:            true,
+		InputFieldPlaceholder: placeholder,
+	}
+	message, err := b.sendMessage(msg)
+	if err != nil {
+		return
+	}
+
+	b.muTextInputs.Lock()
+	defer b.muTextInputs.Unlock()
+	b.textInputs[chatID] = &textInput{
+		chatID:      chatID,
+		messageID:   message.MessageID,
+		prompt:      prompt,
+		placeholder: placeholder,
+		handle:      handle,
+	}
+}
+
+// askForPage asks for a page number between 1 and totalPages and passes it
+// zero-based to showPage.
+func (b *Bot) askForPage(chatID int64, totalPages int, showPage func(page int)) {
+	prompt := fmt.Sprintf("Send the page number to jump to (1-%d):", totalPages)
+	b.askForText(chatID, prompt, "e.g. "+strconv.Itoa((totalPages+1)/2), func(text string) bool {
+		page, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || page < 1 || page > totalPages {
+			return false
+		}
+		showPage(page - 1)
+		return true
+	})
+}
+
+// handleTextInput passes a typed message to the chat's pending prompt. It
+// returns false if there is none.
+func (b *Bot) handleTextInput(update tgbotapi.Update) bool {
+	chatID := update.Message.Chat.ID
+	input := b.takeTextInput(chatID)
+	if input == nil {
+		return false
+	}
+	// Keep the conversation's message in view
+	b.deleteMessage(chatID, input.messageID)
+	b.deleteMessage(chatID, update.Message.MessageID)
+
+	if !input.handle(update.Message.Text) {
+		b.askForText(chatID, "Invalid input. "+input.prompt, input.placeholder, input.handle)
+	}
+	return true
+}
+
+// cancelTextInput removes the chat's pending prompt, if any.
+func (b *Bot) cancelTextInput(chatID int64) {
+	if input := b.takeTextInput(chatID); input != nil {
+		b.deleteMessage(chatID, input.messageID)
+	}
+}
+
+func (b *Bot) takeTextInput(chatID int64) *textInput {
+	b.muTextInputs.Lock()
+	defer b.muTextInputs.Unlock()
+	input := b.textInputs[chatID]
+	delete(b.textInputs, chatID)
+	return input
+}
+
+func (b *Bot) deleteMessage(chatID int64, messageID int) {
+	if _, err := b.Sender.Request(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+		b.logger(chatID).Warn("Cannot delete message", "message_id", messageID, "error", err)
+	}
+}
@@ -19,8 +19,8 @@ func (b *Bot) sendUpcoming(episodes []*sonarr.Episode, msg *tgbotapi.MessageConf
 
 	seriesMap := make(map[int64]*sonarr.Series)
 
-	for i := 0; i < len(episodes); i += b.Config.MaxItems {
-		end := i + b.Config.MaxItems
+	for i := 0; i < len(episodes); i += b.getConfig().MaxItems {
+		end := i + b.getConfig().MaxItems
 		if end > len(episodes) {
 			end = len(episodes)
 		}
@@ -30,7 +30,7 @@ func (b *Bot) sendUpcoming(episodes []*sonarr.Episode, msg *tgbotapi.MessageConf
 			series, ok := seriesMap[episode.SeriesID]
 			if !ok {
 				var err error
-				series, err = b.SonarrServer.GetSeriesByID(episode.SeriesID)
+				series, err = b.getSonarrServer().GetSeriesByID(episode.SeriesID)
 				if err != nil {
 					msg.Text = err.Error()
 					b.sendMessage(msg)
@@ -49,11 +49,11 @@ func (b *Bot) sendUpcoming(episodes []*sonarr.Episode, msg *tgbotapi.MessageConf
 	}
 }
 
-func (b *Bot) getSeriesAsInlineKeyboard(series []*sonarr.Series) [][]tgbotapi.InlineKeyboardButton {
+func (b *Bot) getSeriesAsInlineKeyboard(series []*sonarr.Series, label func(series *sonarr.Series) string) [][]tgbotapi.InlineKeyboardButton {
 	var inlineKeyboard [][]tgbotapi.InlineKeyboardButton
 	for _, series := range series {
 		button := tgbotapi.NewInlineKeyboardButtonData(
-			fmt.Sprintf("%v - %v", series.Title, series.Year),
+			label(series),
 			"TVDBID_"+strconv.Itoa(int(series.TvdbID)),
 		)
 		row := []tgbotapi.InlineKeyboardButton{button}
@@ -62,6 +62,10 @@ func (b *Bot) getSeriesAsInlineKeyboard(series []*sonarr.Series) [][]tgbotapi.In
 	return inlineKeyboard
 }
 
+func libraryLabel(series *sonarr.Series) string {
+	return fmt.Sprintf("%v - %v", series.Title, series.Year)
+}
+
 func (b *Bot) createKeyboard(buttonText, buttonData []string) tgbotapi.InlineKeyboardMarkup {
 	buttons := make([][]tgbotapi.InlineKeyboardButton, len(buttonData))
 	for i := range buttonData {
@@ -125,6 +129,55 @@ func getSeasonByNumber(series *sonarr.Series, number int) *sonarr.Season {
 	return nil
 }
 
+// seasonTitle returns e.g. "Title - Season 2" or "Title - Specials"
+func seasonTitle(series *sonarr.Series, season *sonarr.Season) string {
+	if season.SeasonNumber == 0 {
+		return fmt.Sprintf("%v - Specials", series.Title)
+	}
+	return fmt.Sprintf("%v - Season %d", series.Title, season.SeasonNumber)
+}
+
+// paginationButtons returns the row of buttons to navigate between pages,
+// page is zero-based.
+func paginationButtons(page, totalPages int, firstPage, previousPage, nextPage, lastPage string) []tgbotapi.InlineKeyboardButton {
+	var buttons []tgbotapi.InlineKeyboardButton
+	if page != 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("⏮️", firstPage))
+	}
+	if page > 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("◀️", previousPage))
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, totalPages), "current_page"))
+	if page+1 < totalPages {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("▶️", nextPage))
+	}
+	if page+1 != totalPages {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("⏭️", lastPage))
+	}
+	return buttons
+}
+
+// filterSeriesByTitle returns the series whose title contains text, ignoring case.
+func filterSeriesByTitle(series []*sonarr.Series, text string) []*sonarr.Series {
+	text = strings.ToLower(strings.TrimSpace(text))
+	return filterSeries(series, func(s *sonarr.Series) bool {
+		return strings.Contains(strings.ToLower(s.Title), text)
+	})
+}
+
+// sizeOnDisk sums the size of all seasons of the series.
+func sizeOnDisk(series []*sonarr.Series) int64 {
+	var size int64
+	for _, s := range series {
+		for _, season := range s.Seasons {
+			if season.Statistics != nil {
+				size += season.Statistics.SizeOnDisk
+			}
+		}
+	}
+	return size
+}
+
 func seriesToAddSeriesInput(series *sonarr.Series) *sonarr.AddSeriesInput {
 	return &sonarr.AddSeriesInput{
 		Monitored:         series.Monitored,
@@ -0,0 +1,635 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr"
+	"golift.io/starr/sonarr"
+)
+
+const (
+	ImportLibraryApply  = "IMPORTLIBRARY_APPLY"
+	ImportLibraryCancel = "IMPORTLIBRARY_CANCEL"
+	ImportLibraryPrompt = "Send a library export from /export (JSON or CSV) as a file."
+	// libraryImportMaxLines limits each section of the import messages to
+	// stay below Telegram's message size.
+	libraryImportMaxLines = 30
+)
+
+// csvHeader are the columns of a CSV export. Tags and seasons are separated
+// by spaces.
+var csvHeader = []string{"title", "tvdb_id", "imdb_id", "monitored", "quality_profile", "root_folder", "tags",
+	"series_type", "season_folder", "monitored_seasons", "unmonitored_seasons", "size_on_disk"}
+
+// libraryEntry is a series in a library export.
+type libraryEntry struct {
+	Title          string               `json:"title"`
+	TvdbID         int64                `json:"tvdbId"`
+	ImdbID         string               `json:"imdbId,omitempty"`
+	Monitored      bool                 `json:"monitored"`
+	QualityProfile string               `json:"qualityProfile"`
+	RootFolder     string               `json:"rootFolder"`
+	Tags           []string             `json:"tags,omitempty"`
+	SeriesType     string               `json:"seriesType"`
+	SeasonFolder   bool                 `json:"seasonFolder"`
+	Seasons        []libraryEntrySeason `json:"seasons,omitempty"`
+	SizeOnDisk     int64                `json:"sizeOnDisk"`
+}
+
+type libraryEntrySeason struct {
+	SeasonNumber int  `json:"seasonNumber"`
+	Monitored    bool `json:"monitored"`
+}
+
+// libraryImportItem is a series to add with the IDs on this Sonarr server.
+type libraryImportItem struct {
+	entry      libraryEntry
+	profileID  int64
+	rootFolder string
+}
+
+type userImportLibrary struct {
+	toAdd     []libraryImportItem
+	newTags   []string
+	chatID    int64
+	messageID int
+}
+
+func (b *Bot) processExportCommand(update tgbotapi.Update, chatID int64) {
+	format := strings.ToLower(strings.TrimSpace(update.Message.CommandArguments()))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		b.sendMessage(tgbotapi.NewMessage(chatID, "Usage: /export [json|csv]"))
+		return
+	}
+
+	s := b.getSonarrServer()
+	series, err := s.GetSeries(0)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	profiles, err := s.GetQualityProfiles()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	tags, err := s.GetTags()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+
+	entries := libraryEntries(series, profiles, tags)
+	var data []byte
+	if format == "csv" {
+		data, err = encodeLibraryCSV(entries)
+	} else {
+		data, err = json.MarshalIndent(entries, "", "  ")
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Cannot encode library export", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	name := fmt.Sprintf("sonarr-library-%v.%v", time.Now().Format(time.DateOnly), format)
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	document.Caption = fmt.Sprintf("%d series, import them with /importlibrary", len(entries))
+	b.sendMessage(document)
+}
+
+// libraryEntries describes the series sorted by title.
+func libraryEntries(series []*sonarr.Series, profiles []*sonarr.QualityProfile, tags []*starr.Tag) []libraryEntry {
+	entries := make([]libraryEntry, 0, len(series))
+	for _, s := range series {
+		entry := libraryEntry{
+			Title:        s.Title,
+			TvdbID:       s.TvdbID,
+			ImdbID:       s.ImdbID,
+			Monitored:    s.Monitored,
+			RootFolder:   seriesRootFolder(s),
+			Tags:         tagLabels(s.Tags, tags),
+			SeriesType:   s.SeriesType,
+			SeasonFolder: s.SeasonFolder,
+		}
+		if profile := getQualityProfileByID(profiles, s.QualityProfileID); profile != nil {
+			entry.QualityProfile = profile.Name
+		}
+		for _, season := range s.Seasons {
+			entry.Seasons = append(entry.Seasons, libraryEntrySeason{SeasonNumber: season.SeasonNumber, Monitored: season.Monitored})
+		}
+		sort.Slice(entry.Seasons, func(i, j int) bool {
+			return entry.Seasons[i].SeasonNumber < entry.Seasons[j].SeasonNumber
+		})
+		if s.Statistics != nil {
+			entry.SizeOnDisk = s.Statistics.SizeOnDisk
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Title) < strings.ToLower(entries[j].Title)
+	})
+	return entries
+}
+
+// seriesRootFolder returns the root folder of a series, derived from its
+// path if Sonarr does not return it.
+func seriesRootFolder(series *sonarr.Series) string {
+	if series.RootFolderPath != "" {
+		return strings.TrimRight(series.RootFolderPath, `/\`)
+	}
+	path := strings.TrimRight(series.Path, `/\`)
+	if i := strings.LastIndexAny(path, `/\`); i > 0 {
+		return path[:i]
+	}
+	return path
+}
+
+func tagLabels(tagIDs []int, tags []*starr.Tag) []string {
+	var labels []string
+	for _, tag := range tags {
+		if isSelectedTag(tagIDs, tag.ID) {
+			labels = append(labels, tag.Label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func encodeLibraryCSV(entries []libraryEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		var monitored, unmonitored []string
+		for _, season := range entry.Seasons {
+			if season.Monitored {
+				monitored = append(monitored, strconv.Itoa(season.SeasonNumber))
+			} else {
+				unmonitored = append(unmonitored, strconv.Itoa(season.SeasonNumber))
+			}
+		}
+		record := []string{
+			entry.Title,
+			strconv.FormatInt(entry.TvdbID, 10),
+			entry.ImdbID,
+			strconv.FormatBool(entry.Monitored),
+			entry.QualityProfile,
+			entry.RootFolder,
+			strings.Join(entry.Tags, " "),
+			entry.SeriesType,
+			strconv.FormatBool(entry.SeasonFolder),
+			strings.Join(monitored, " "),
+			strings.Join(unmonitored, " "),
+			strconv.FormatInt(entry.SizeOnDisk, 10),
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// decodeLibraryExport reads a JSON or CSV export.
+func decodeLibraryExport(data []byte) ([]libraryEntry, error) {
+	// Spreadsheets may save CSV files with a byte order mark
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+	var entries []libraryEntry
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		var err error
+		if entries, err = decodeLibraryCSV(data); err != nil {
+			return nil, err
+		}
+	}
+	for i, entry := range entries {
+		if entry.TvdbID <= 0 {
+			return nil, fmt.Errorf("series %d (%v) has no TVDB ID", i+1, entry.Title)
+		}
+	}
+	return entries, nil
+}
+
+func decodeLibraryCSV(data []byte) ([]libraryEntry, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("the file is empty")
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["tvdb_id"]; !ok {
+		return nil, errors.New("invalid CSV: no tvdb_id column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var entries []libraryEntry
+	for line, record := range records[1:] {
+		tvdbID, err := strconv.ParseInt(field(record, "tvdb_id"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid tvdb_id %q", line+2, field(record, "tvdb_id"))
+		}
+		entry := libraryEntry{
+			Title:          field(record, "title"),
+			TvdbID:         tvdbID,
+			ImdbID:         field(record, "imdb_id"),
+			Monitored:      field(record, "monitored") != "false",
+			QualityProfile: field(record, "quality_profile"),
+			RootFolder:     field(record, "root_folder"),
+			Tags:           strings.Fields(field(record, "tags")),
+			SeriesType:     field(record, "series_type"),
+			SeasonFolder:   field(record, "season_folder") != "false",
+		}
+		for _, column := range []string{"monitored_seasons", "unmonitored_seasons"} {
+			for _, number := range strings.Fields(field(record, column)) {
+				seasonNumber, err := strconv.Atoi(number)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid season %q", line+2, number)
+				}
+				entry.Seasons = append(entry.Seasons, libraryEntrySeason{SeasonNumber: seasonNumber, Monitored: column == "monitored_seasons"})
+			}
+		}
+		sort.Slice(entry.Seasons, func(i, j int) bool {
+			return entry.Seasons[i].SeasonNumber < entry.Seasons[j].SeasonNumber
+		})
+		entry.SizeOnDisk, _ = strconv.ParseInt(field(record, "size_on_disk"), 10, 64)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (b *Bot) processImportLibraryCommand(chatID int64) {
+	b.sendMessage(tgbotapi.NewMessage(chatID, ImportLibraryPrompt))
+}
+
+// handleDocument imports a library export sent after /importlibrary or with
+// /importlibrary as caption.
+func (b *Bot) handleDocument(update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	activeCommand, _ := b.getActiveCommand(chatID)
+	if activeCommand != ImportLibraryCommand && !strings.HasPrefix(update.Message.Caption, "/importlibrary") {
+		b.sendMessage(tgbotapi.NewMessage(chatID, "To import a library export, send /importlibrary first."))
+		return
+	}
+	b.clearState(update)
+	b.setActiveCommand(chatID, ImportLibraryCommand)
+
+	msg := tgbotapi.NewMessage(chatID, "Handling library import... please wait")
+	message, _ := b.sendMessage(msg)
+	command := userImportLibrary{
+		chatID:    message.Chat.ID,
+		messageID: message.MessageID,
+	}
+
+	document := update.Message.Document
+	if document.FileSize > maxDownloadSize {
+		b.sendMessageWithEdit(&command, "The file is too large.")
+		return
+	}
+	if b.Files == nil {
+		b.sendMessageWithEdit(&command, "Cannot download files.")
+		return
+	}
+	data, err := b.Files.DownloadFile(document.FileID)
+	if err != nil {
+		b.logger(chatID).Error("Cannot download file", "file_name", document.FileName, "error", err)
+		b.sendMessageWithEdit(&command, fmt.Sprintf("Cannot download %v: %v", document.FileName, err))
+		return
+	}
+	entries, err := decodeLibraryExport(data)
+	if err != nil {
+		b.sendMessageWithEdit(&command, fmt.Sprintf("Cannot read %v: %v", document.FileName, err))
+		return
+	}
+	b.showLibraryImportPlan(&command, entries)
+}
+
+// showLibraryImportPlan compares the export with the library and shows what
+// applying it would change.
+func (b *Bot) showLibraryImportPlan(command *userImportLibrary, entries []libraryEntry) bool {
+	s := b.getSonarrServer()
+	library, err := s.GetSeries(0)
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	profiles, err := s.GetQualityProfiles()
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	rootFolders, err := s.GetRootFolders()
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	tags, err := s.GetTags()
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+
+	existing := libraryEntries(library, profiles, tags)
+	byTvdbID := make(map[int64]libraryEntry, len(existing))
+	for _, entry := range existing {
+		byTvdbID[entry.TvdbID] = entry
+	}
+
+	command.toAdd = nil
+	command.newTags = nil
+	var toAdd, differs, failed, skippedTags []string
+	unchanged := 0
+	newTags := make(map[string]bool)
+	for _, entry := range entries {
+		var invalidTags []string
+		entry.Tags, invalidTags = normalizeImportTags(entry.Tags)
+		if current, ok := byTvdbID[entry.TvdbID]; ok {
+			if changes := libraryEntryChanges(current, entry); len(changes) > 0 {
+				differs = append(differs, fmt.Sprintf("%v: %v", entry.Title, strings.Join(changes, ", ")))
+			} else {
+				unchanged++
+			}
+			continue
+		}
+		item := libraryImportItem{entry: entry}
+		for _, profile := range profiles {
+			if strings.EqualFold(profile.Name, entry.QualityProfile) {
+				item.profileID = profile.ID
+			}
+		}
+		for _, folder := range rootFolders {
+			if strings.TrimRight(folder.Path, `/\`) == strings.TrimRight(entry.RootFolder, `/\`) {
+				item.rootFolder = folder.Path
+			}
+		}
+		switch {
+		case item.profileID == 0:
+			failed = append(failed, fmt.Sprintf("%v: quality profile %q not found", entry.Title, entry.QualityProfile))
+			continue
+		case item.rootFolder == "":
+			failed = append(failed, fmt.Sprintf("%v: root folder %q not found", entry.Title, entry.RootFolder))
+			continue
+		}
+		for _, label := range invalidTags {
+			skippedTags = append(skippedTags, fmt.Sprintf("%v: %q is not a valid tag name", entry.Title, label))
+		}
+		for _, label := range entry.Tags {
+			if !containsTagLabel(tags, label) && !newTags[label] {
+				newTags[label] = true
+				command.newTags = append(command.newTags, label)
+			}
+		}
+		command.toAdd = append(command.toAdd, item)
+		toAdd = append(toAdd, entry.Title)
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Library import (dry run) of %d series\n", len(entries))
+	writeLibraryImportSection(&text, "➕ To add", toAdd)
+	writeLibraryImportSection(&text, InLibraryIcon+" In library with other settings, not changed", differs)
+	writeLibraryImportSection(&text, UnmonitorIcon+" Cannot import", failed)
+	writeLibraryImportSection(&text, FailingIcon+" Tags not imported", skippedTags)
+	if len(command.newTags) > 0 {
+		fmt.Fprintf(&text, "\nNew tags: %v\n", strings.Join(command.newTags, ", "))
+	}
+	if unchanged > 0 {
+		fmt.Fprintf(&text, "\n%d series are already in the library as exported.\n", unchanged)
+	}
+	fmt.Fprintf(&text, "\nNothing is changed until you apply the import.")
+
+	var buttonLabels []string
+	var buttonData []string
+	if len(command.toAdd) > 0 {
+		buttonLabels = append(buttonLabels, fmt.Sprintf("Apply - add %d series", len(command.toAdd)))
+		buttonData = append(buttonData, ImportLibraryApply)
+	}
+	buttonLabels = append(buttonLabels, "Cancel - clear command")
+	buttonData = append(buttonData, ImportLibraryCancel)
+
+	b.setImportLibraryState(command.chatID, command)
+	b.sendMessageWithEditAndKeyboard(command, b.createKeyboard(buttonLabels, buttonData), text.String())
+	return false
+}
+
+// libraryEntryChanges lists how the export differs from the series in the
+// library, e.g. "quality profile HD-720p → HD-1080p".
+func libraryEntryChanges(current libraryEntry, exported libraryEntry) []string {
+	var changes []string
+	change := func(name string, from string, to string) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%v %v → %v", name, from, to))
+		}
+	}
+	change("monitored", strconv.FormatBool(current.Monitored), strconv.FormatBool(exported.Monitored))
+	change("quality profile", current.QualityProfile, exported.QualityProfile)
+	change("root folder", current.RootFolder, strings.TrimRight(exported.RootFolder, `/\`))
+	change("series type", current.SeriesType, exported.SeriesType)
+	change("tags", strings.Join(current.Tags, " "), strings.Join(sortedCopy(exported.Tags), " "))
+	change("monitored seasons", monitoredSeasonsText(current.Seasons), monitoredSeasonsText(exported.Seasons))
+	return changes
+}
+
+func sortedCopy(values []string) []string {
+	values = append([]string(nil), values...)
+	sort.Strings(values)
+	return values
+}
+
+func monitoredSeasonsText(seasons []libraryEntrySeason) string {
+	var numbers []int
+	for _, season := range seasons {
+		if season.Monitored {
+			numbers = append(numbers, season.SeasonNumber)
+		}
+	}
+	sort.Ints(numbers)
+	parts := make([]string, len(numbers))
+	for i, number := range numbers {
+		parts[i] = strconv.Itoa(number)
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, " ")
+}
+
+// normalizeImportTags returns the labels of an export the way Sonarr stores
+// them, and the labels that are not valid tag names.
+func normalizeImportTags(labels []string) ([]string, []string) {
+	var tags, invalid []string
+	for _, text := range labels {
+		label, ok := normalizeTagLabel(text)
+		switch {
+		case !ok:
+			invalid = append(invalid, text)
+		case !slices.Contains(tags, label):
+			tags = append(tags, label)
+		}
+	}
+	return tags, invalid
+}
+
+func containsTagLabel(tags []*starr.Tag, label string) bool {
+	for _, tag := range tags {
+		if tag.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+func writeLibraryImportSection(text *strings.Builder, title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(text, "\n%v (%d):\n", title, len(lines))
+	for i, line := range lines {
+		if i == libraryImportMaxLines {
+			fmt.Fprintf(text, "... and %d more\n", len(lines)-libraryImportMaxLines)
+			break
+		}
+		fmt.Fprintf(text, "%v\n", line)
+	}
+}
+
+func (b *Bot) importLibrary(update tgbotapi.Update) bool {
+	chatID, err := b.getChatID(update)
+	if err != nil {
+		slog.Warn("Cannot import library", "update_id", update.UpdateID, "error", err)
+		return false
+	}
+
+	command, exists := b.getImportLibraryState(chatID)
+	if !exists {
+		return false
+	}
+	switch update.CallbackQuery.Data {
+	case ImportLibraryApply:
+		return b.handleLibraryImportApply(update, command)
+	case ImportLibraryCancel:
+		b.clearState(update)
+		b.sendMessageWithEdit(command, CommandsCleared)
+		return false
+	}
+	return false
+}
+
+// handleLibraryImportApply creates the missing tags and adds the series
+// without searching, with the monitoring of the export.
+func (b *Bot) handleLibraryImportApply(update tgbotapi.Update, command *userImportLibrary) bool {
+	allTags, err := b.getSonarrServer().GetTags()
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	for _, label := range command.newTags {
+		if containsTagLabel(allTags, label) {
+			continue
+		}
+		tag, err := b.createTag(command.chatID, label)
+		if err != nil {
+			msg := tgbotapi.NewMessage(command.chatID, err.Error())
+			b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+			b.sendMessage(msg)
+			return false
+		}
+		allTags = append(allTags, tag)
+	}
+
+	var results []string
+	var failed []string
+	for _, item := range command.toAdd {
+		entry := item.entry
+		var tagIDs []int
+		for _, tag := range allTags {
+			for _, label := range entry.Tags {
+				if tag.Label == label {
+					tagIDs = append(tagIDs, tag.ID)
+				}
+			}
+		}
+		var seasons []*sonarr.Season
+		for _, season := range entry.Seasons {
+			seasons = append(seasons, &sonarr.Season{SeasonNumber: season.SeasonNumber, Monitored: season.Monitored})
+		}
+		seriesType := entry.SeriesType
+		if seriesType == "" {
+			seriesType = "standard"
+		}
+		input := &sonarr.AddSeriesInput{
+			TvdbID:           entry.TvdbID,
+			Title:            entry.Title,
+			QualityProfileID: item.profileID,
+			RootFolderPath:   item.rootFolder,
+			SeriesType:       seriesType,
+			Tags:             tagIDs,
+			Monitored:        entry.Monitored,
+			SeasonFolder:     entry.SeasonFolder,
+			Seasons:          seasons,
+			// Without a monitor option Sonarr keeps the monitoring of the
+			// seasons
+			AddOptions: &sonarr.AddSeriesOptions{},
+		}
+		if _, err := b.getSonarrServer().AddSeries(input); err != nil {
+			b.logger(command.chatID).Error("Sonarr request failed", "series", entry.Title, "error", err)
+			failed = append(failed, fmt.Sprintf("%v: %v", entry.Title, err))
+			continue
+		}
+		b.audit(command.chatID, "imported series", entry.Title, "tvdb_id", entry.TvdbID,
+			"quality_profile_id", item.profileID, "root_folder", item.rootFolder)
+		results = append(results, entry.Title)
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Imported %d of %d series\n", len(results), len(command.toAdd))
+	writeLibraryImportSection(&text, MonitorIcon+" Added", results)
+	writeLibraryImportSection(&text, UnmonitorIcon+" Failed", failed)
+	b.sendMessageWithEdit(command, text.String())
+	b.clearState(update)
+	return false
+}
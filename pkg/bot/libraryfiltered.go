@@ -2,6 +2,8 @@ package bot
 
 import (
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,12 +14,14 @@ import (
 )
 
 const (
-	LibrarySeriesDelete     = "LIBRARY_SERIES_DELETE"
-	LibrarySeriesDeleteYes  = "LIBRARY_SERIES_DELETE_YES"
-	LibrarySeriesDeleteNo   = "LIBRARY_SERIES_DELETE_NO"
-	LibrarySeriesEdit       = "LIBRARY_SERIES_EDIT"
-	LibrarySeriesSeasonEdit = "LIBRARY_SERIES_SEASON_EDIT"
-	LibrarySeriesGoBack     = "LIBRARY_SERIES_GOBACK"
+	LibrarySeriesDelete              = "LIBRARY_SERIES_DELETE"
+	LibrarySeriesDeleteYes           = "LIBRARY_SERIES_DELETE_YES"
+	LibrarySeriesDeleteNo            = "LIBRARY_SERIES_DELETE_NO"
+	LibrarySeriesDeleteToggleFiles   = "LIBRARY_SERIES_DELETE_TOGGLE_FILES"
+	LibrarySeriesDeleteToggleExclude = "LIBRARY_SERIES_DELETE_TOGGLE_EXCLUDE"
+	LibrarySeriesEdit                = "LIBRARY_SERIES_EDIT"
+	LibrarySeriesSeasonEdit          = "LIBRARY_SERIES_SEASON_EDIT"
+	LibrarySeriesGoBack              = "LIBRARY_SERIES_GOBACK"
 	//LibraryFilteredGoBack        = "LIBRARY_FILTERED_GOBACK" already defined in librarymenu.go
 	LibrarySeriesMonitor          = "LIBRARY_SERIES_MONITOR"
 	LibrarySeriesUnmonitor        = "LIBRARY_SERIES_UNMONITOR"
@@ -36,7 +40,7 @@ const (
 func (b *Bot) libraryFiltered(update tgbotapi.Update) bool {
 	chatID, err := b.getChatID(update)
 	if err != nil {
-		fmt.Printf("Cannot manage library: %v", err)
+		slog.Warn("Cannot manage library", "update_id", update.UpdateID, "error", err)
 		return false
 	}
 
@@ -45,8 +49,22 @@ func (b *Bot) libraryFiltered(update tgbotapi.Update) bool {
 		return false
 	}
 	switch update.CallbackQuery.Data {
-	// ignore click on page number
 	case "current_page":
+		totalPages := (len(command.libraryFiltered) + b.getConfig().MaxItems - 1) / b.getConfig().MaxItems
+		b.askForPage(chatID, totalPages, func(page int) {
+			command.page = page
+			b.showLibraryMenuFiltered(command)
+		})
+		return false
+	case LibraryFilteredSearch:
+		b.askForText(chatID, "Send a title to search the list for:", "e.g. breaking", func(text string) bool {
+			return b.handleLibraryFilteredSearch(command, text)
+		})
+		return false
+	case LibraryFilteredRefine:
+		b.askForText(chatID, "Send a new search (title, ID or link):", "e.g. breaking bad", func(text string) bool {
+			return b.handleLibraryFilteredRefine(update, command, text)
+		})
 		return false
 	case LibraryFirstPage:
 		command.page = 0
@@ -60,7 +78,7 @@ func (b *Bot) libraryFiltered(update tgbotapi.Update) bool {
 		command.page++
 		return b.showLibraryMenuFiltered(command)
 	case LibraryLastPage:
-		totalPages := (len(command.libraryFiltered) + b.Config.MaxItems - 1) / b.Config.MaxItems
+		totalPages := (len(command.libraryFiltered) + b.getConfig().MaxItems - 1) / b.getConfig().MaxItems
 		command.page = totalPages - 1
 		return b.showLibraryMenuFiltered(command)
 	case LibrarySeriesGoBack:
@@ -81,6 +99,12 @@ func (b *Bot) libraryFiltered(update tgbotapi.Update) bool {
 		return b.handleLibrarySeriesSearch(update, command)
 	case LibrarySeriesDelete:
 		return b.handleLibrarySeriesDelete(command)
+	case LibrarySeriesDeleteToggleFiles:
+		command.deleteFiles = !command.deleteFiles
+		return b.showLibrarySeriesDelete(command)
+	case LibrarySeriesDeleteToggleExclude:
+		command.importExclude = !command.importExclude
+		return b.showLibrarySeriesDelete(command)
 	case LibrarySeriesDeleteYes:
 		return b.handleLibrarySeriesDeleteYes(update, command)
 	case LibrarySeriesDeleteNo:
@@ -89,13 +113,63 @@ func (b *Bot) libraryFiltered(update tgbotapi.Update) bool {
 		return b.handleLibrarySeriesEdit(command)
 	case LibrarySeriesSeasonEdit:
 		return b.handleLibrarySeasonsEdit(command)
+	case LibrarySeriesWatchedSeasons:
+		return b.showLibraryWatchedSeasons(command)
 	case LibrarySeriesMonitorSearchNow:
 		return b.handleLibrarySeriesMonitorSearchNow(update, command)
+	case LibrarySeriesQuality:
+		return b.showLibraryQuality(command, nil)
+	case LibrarySeriesQualitySearch:
+		return b.handleLibraryQualitySearchUpgrades(command, nil)
+	case LibrarySeriesQualityGoBack:
+		return b.showLibrarySeriesDetail(update, command)
 	default:
 		return b.showLibrarySeriesDetail(update, command)
 	}
 }
 
+// handleLibraryFilteredSearch narrows the shown list down to the series
+// matching text.
+func (b *Bot) handleLibraryFilteredSearch(command *userLibrary, text string) bool {
+	var shown []*sonarr.Series
+	for _, series := range command.libraryFiltered {
+		shown = append(shown, series)
+	}
+	matches := filterSeriesByTitle(shown, text)
+	if len(matches) == 0 {
+		msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("No series matching %q in this list", strings.TrimSpace(text)))
+		b.sendMessage(msg)
+		return false
+	}
+	command.searchResultsInLibrary = matches
+	command.lookup = false
+	command.filter = FilterSearchResults
+	command.page = 0
+	b.showLibraryMenuFiltered(command)
+	return true
+}
+
+// handleLibraryFilteredRefine replaces the lookup results with a new Sonarr
+// lookup.
+func (b *Bot) handleLibraryFilteredRefine(update tgbotapi.Update, command *userLibrary, text string) bool {
+	term, _ := lookupTerm(strings.TrimSpace(text))
+	searchResults, err := b.getSonarrServer().Lookup(term)
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return true
+	}
+	if !slices.ContainsFunc(searchResults, func(series *sonarr.Series) bool { return series.ID != 0 }) {
+		msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("No series in your library matching %q", strings.TrimSpace(text)))
+		b.sendMessage(msg)
+		return false
+	}
+	command.series = nil
+	b.handleSearchResults(update, searchResults, command)
+	return true
+}
+
 func (b *Bot) showLibrarySeriesDetail(update tgbotapi.Update, command *userLibrary) bool {
 	var series *sonarr.Series
 	if command.series == nil {
@@ -133,7 +207,7 @@ func (b *Bot) showLibrarySeriesDetail(update tgbotapi.Update, command *userLibra
 	}
 
 	// get all episodes
-	episodes, err := b.SonarrServer.GetSeriesEpisodes(
+	episodes, err := b.getSonarrServer().GetSeriesEpisodes(
 		&sonarr.GetEpisode{
 			SeriesID: series.ID,
 		})
@@ -145,7 +219,7 @@ func (b *Bot) showLibrarySeriesDetail(update tgbotapi.Update, command *userLibra
 	command.allEpisodes = episodes
 
 	// get all episodeFiles
-	episodeFiles, err := b.SonarrServer.GetSeriesEpisodeFiles(series.ID)
+	episodeFiles, err := b.getSonarrServer().GetSeriesEpisodeFiles(series.ID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
@@ -173,24 +247,31 @@ func (b *Bot) showLibrarySeriesDetail(update tgbotapi.Update, command *userLibra
 	fmt.Fprintf(&message, "Monitored: %s\n", monitorIcon)
 	fmt.Fprintf(&message, "Status: %s\n", utils.Escape(series.Status))
 	fmt.Fprintf(&message, "Last Manual Search: %s\n", utils.Escape(lastSearchString))
-	fmt.Fprintf(&message, "Size: %d GB\n", totalSize/(1024*1024*1024))
+	fmt.Fprintf(&message, "Size: %s\n", utils.Escape(utils.ByteCountSI(totalSize)))
 	fmt.Fprintf(&message, "Tags: %s\n", utils.Escape(tagsString))
 	fmt.Fprintf(&message, "Quality Profile: %s\n", utils.Escape(getQualityProfileByID(command.qualityProfiles, series.QualityProfileID).Name))
+	if command.watched != nil {
+		writeWatchStatus(&message, command)
+	}
 
 	messageText := message.String()
 
 	var keyboard tgbotapi.InlineKeyboardMarkup
 	if !series.Monitored {
 		keyboard = b.createKeyboard(
-			[]string{"Monitor Series", "Monitor Series & Search Now", "Delete Series", "Edit Series", "Edit Seasons", "\U0001F519"},
-			[]string{LibrarySeriesMonitor, LibrarySeriesMonitorSearchNow, LibrarySeriesDelete, LibrarySeriesEdit, LibrarySeriesSeasonEdit, LibrarySeriesGoBack},
+			[]string{"Monitor Series", "Monitor Series & Search Now", "Delete Series", "Edit Series", "Edit Seasons", "📊 Quality", "\U0001F519"},
+			[]string{LibrarySeriesMonitor, LibrarySeriesMonitorSearchNow, LibrarySeriesDelete, LibrarySeriesEdit, LibrarySeriesSeasonEdit, LibrarySeriesQuality, LibrarySeriesGoBack},
 		)
 	} else {
 		keyboard = b.createKeyboard(
-			[]string{"Unmonitor Series", "Search Series", "Delete Series", "Edit Series", "Edit Seasons", "\U0001F519"},
-			[]string{LibrarySeriesUnmonitor, LibrarySeriesSearch, LibrarySeriesDelete, LibrarySeriesEdit, LibrarySeriesSeasonEdit, LibrarySeriesGoBack},
+			[]string{"Unmonitor Series", "Search Series", "Delete Series", "Edit Series", "Edit Seasons", "📊 Quality", "\U0001F519"},
+			[]string{LibrarySeriesUnmonitor, LibrarySeriesSearch, LibrarySeriesDelete, LibrarySeriesEdit, LibrarySeriesSeasonEdit, LibrarySeriesQuality, LibrarySeriesGoBack},
 		)
 	}
+	if row := watchedSeasonsButton(command); row != nil {
+		rows := keyboard.InlineKeyboard
+		keyboard.InlineKeyboard = append(rows[:len(rows)-1:len(rows)-1], row, rows[len(rows)-1])
+	}
 
 	// Send the message containing series details along with the keyboard
 	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
@@ -209,12 +290,13 @@ func (b *Bot) showLibrarySeriesDetail(update tgbotapi.Update, command *userLibra
 func (b *Bot) handleLibrarySeriesMonitor(update tgbotapi.Update, command *userLibrary) bool {
 	command.series.Monitored = *starr.True()
 	input := seriesToAddSeriesInput(command.series)
-	_, err := b.SonarrServer.UpdateSeries(input, *starr.False())
+	_, err := b.getSonarrServer().UpdateSeries(input, *starr.False())
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
+	b.audit(command.chatID, "monitored series", command.series.Title, "series_id", command.series.ID)
 	b.setLibraryState(command.chatID, command)
 	return b.showLibrarySeriesDetail(update, command)
 }
@@ -222,12 +304,13 @@ func (b *Bot) handleLibrarySeriesMonitor(update tgbotapi.Update, command *userLi
 func (b *Bot) handleLibrarySeriesUnMonitor(update tgbotapi.Update, command *userLibrary) bool {
 	command.series.Monitored = *starr.False()
 	input := seriesToAddSeriesInput(command.series)
-	_, err := b.SonarrServer.UpdateSeries(input, *starr.False())
+	_, err := b.getSonarrServer().UpdateSeries(input, *starr.False())
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
+	b.audit(command.chatID, "unmonitored series", command.series.Title, "series_id", command.series.ID)
 	b.setLibraryState(command.chatID, command)
 	return b.showLibrarySeriesDetail(update, command)
 }
@@ -237,7 +320,7 @@ func (b *Bot) handleLibrarySeriesSearch(update tgbotapi.Update, command *userLib
 		Name:     "SeriesSearch",
 		SeriesID: command.series.ID,
 	}
-	_, err := b.SonarrServer.SendCommand(&cmd)
+	_, err := b.getSonarrServer().SendCommand(&cmd)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
@@ -251,18 +334,19 @@ func (b *Bot) handleLibrarySeriesSearch(update tgbotapi.Update, command *userLib
 func (b *Bot) handleLibrarySeriesMonitorSearchNow(update tgbotapi.Update, command *userLibrary) bool {
 	command.series.Monitored = *starr.True()
 	input := seriesToAddSeriesInput(command.series)
-	_, err := b.SonarrServer.UpdateSeries(input, *starr.False())
+	_, err := b.getSonarrServer().UpdateSeries(input, *starr.False())
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
+	b.audit(command.chatID, "monitored series", command.series.Title, "series_id", command.series.ID)
 
 	cmd := sonarr.CommandRequest{
 		Name:     "SeriesSearch",
 		SeriesID: command.series.ID,
 	}
-	_, err = b.SonarrServer.SendCommand(&cmd)
+	_, err = b.getSonarrServer().SendCommand(&cmd)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
@@ -274,10 +358,19 @@ func (b *Bot) handleLibrarySeriesMonitorSearchNow(update tgbotapi.Update, comman
 }
 
 func (b *Bot) handleLibrarySeriesDelete(command *userLibrary) bool {
-	messageText := fmt.Sprintf("[%v](https://www.imdb.com/title/%v) \\- _%v_\n\n", utils.Escape(command.series.Title), command.series.ImdbID, command.series.Year)
+	command.deleteFiles = true
+	command.importExclude = false
+	return b.showLibrarySeriesDelete(command)
+}
+
+func (b *Bot) showLibrarySeriesDelete(command *userLibrary) bool {
+	series := []*sonarr.Series{command.series}
+	messageText := fmt.Sprintf("[%v](https://www.imdb.com/title/%v) \\- _%v_\n\n%v", utils.Escape(command.series.Title), command.series.ImdbID, command.series.Year,
+		utils.Escape(deleteOptionsText(series, command.deleteFiles, command.importExclude)))
+	filesLabel, excludeLabel := deleteOptionLabels(command.deleteFiles, command.importExclude)
 	keyboard := b.createKeyboard(
-		[]string{"Yes, delete this series", "\U0001F519"},
-		[]string{LibrarySeriesDeleteYes, LibrarySeriesDeleteNo},
+		[]string{filesLabel, excludeLabel, "Yes, delete this series", "\U0001F519"},
+		[]string{LibrarySeriesDeleteToggleFiles, LibrarySeriesDeleteToggleExclude, LibrarySeriesDeleteYes, LibrarySeriesDeleteNo},
 	)
 	// Send the message containing series details along with the keyboard
 	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
@@ -294,13 +387,25 @@ func (b *Bot) handleLibrarySeriesDelete(command *userLibrary) bool {
 }
 
 func (b *Bot) handleLibrarySeriesDeleteYes(update tgbotapi.Update, command *userLibrary) bool {
-	err := b.SonarrServer.DeleteSeries(int(command.series.ID), *starr.True(), *starr.False())
+	series := []*sonarr.Series{command.series}
+	deleteFiles, importExclude := command.deleteFiles, command.importExclude
+	text := fmt.Sprintf("Series '%v' deleted\n", command.series.Title)
+
+	if b.getConfig().DeleteGracePeriod > 0 {
+		b.clearState(update)
+		b.scheduleDelete(command, fmt.Sprintf("Deleting series '%v'\n\n%v", command.series.Title, deleteOptionsText(series, deleteFiles, importExclude)), text,
+			func(user *tgbotapi.User) error {
+				return b.deleteSeriesWithOptions(user, command.chatID, series, deleteFiles, importExclude)
+			})
+		return true
+	}
+
+	err := b.deleteSeriesWithOptions(b.updateUser(command.chatID), command.chatID, series, deleteFiles, importExclude)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
-	text := fmt.Sprintf("Series '%v' deleted\n", command.series.Title)
 	b.clearState(update)
 	b.sendMessageWithEdit(command, text)
 	return true
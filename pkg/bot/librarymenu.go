@@ -2,6 +2,7 @@ package bot
 
 import (
 	"fmt"
+	"log/slog"
 	"sort"
 	"strconv"
 	"strings"
@@ -22,6 +23,8 @@ const (
 	LibraryPreviousPage   = "LIBRARY_PREV_PAGE"
 	LibraryNextPage       = "LIBRARY_NEXT_PAGE"
 	LibraryLastPage       = "LIBRARY_LAST_PAGE"
+	LibraryFilteredSearch = "LIBRARY_FILTERED_SEARCH"
+	LibraryFilteredRefine = "LIBRARY_FILTERED_REFINE"
 )
 
 const (
@@ -33,9 +36,10 @@ const (
 	FilterOnDisk          = "FILTER_ONDISK"
 	FilterShowAll         = "FILTER_SHOWALL"
 	FilterSearchResults   = "FILTER_SEARCHRESULTS"
+	FilterWatchedEnded    = "FILTER_WATCHEDENDED"
 )
 
-func (b *Bot) processLibraryCommand(update tgbotapi.Update, chatID int64, s *sonarr.Sonarr) {
+func (b *Bot) processLibraryCommand(update tgbotapi.Update, chatID int64, s SonarrClient) {
 	msg := tgbotapi.NewMessage(chatID, "Handling library command... please wait")
 	message, _ := b.sendMessage(msg)
 
@@ -62,6 +66,7 @@ func (b *Bot) processLibraryCommand(update tgbotapi.Update, chatID int64, s *son
 	command.qualityProfiles = qualityProfiles
 	command.allTags = tags
 	command.library = series
+	command.watched = b.loadWatched(chatID)
 	command.filter = ""
 	command.chatID = message.Chat.ID
 	command.messageID = message.MessageID
@@ -74,7 +79,8 @@ func (b *Bot) processLibraryCommand(update tgbotapi.Update, chatID int64, s *son
 		return
 	}
 
-	searchResults, err := s.Lookup("\"" + criteria + "\"")
+	term, _ := lookupTerm(criteria)
+	searchResults, err := s.Lookup(term)
 	if err != nil {
 		msg := tgbotapi.NewMessage(chatID, err.Error())
 		b.sendMessage(msg)
@@ -88,7 +94,7 @@ func (b *Bot) processLibraryCommand(update tgbotapi.Update, chatID int64, s *son
 func (b *Bot) libraryMenu(update tgbotapi.Update) bool {
 	chatID, err := b.getChatID(update)
 	if err != nil {
-		fmt.Printf("Cannot manage library: %v", err)
+		slog.Warn("Cannot manage library", "update_id", update.UpdateID, "error", err)
 		return false
 	}
 
@@ -138,6 +144,10 @@ func (b *Bot) showLibraryMenu(command *userLibrary) bool {
 			tgbotapi.NewInlineKeyboardButtonData("Cancel - clear command", LibraryCancel),
 		},
 	}
+	if command.watched != nil {
+		row := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Fully Watched & Ended", FilterWatchedEnded))
+		keyboard = append(keyboard[:len(keyboard)-1], row, keyboard[len(keyboard)-1])
+	}
 	command.page = 0
 	b.setLibraryState(command.chatID, command)
 	b.sendMessageWithEditAndKeyboard(command, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: keyboard}, "Select an option:")
@@ -195,6 +205,12 @@ func (b *Bot) showLibraryMenuFiltered(command *userLibrary) bool {
 		})
 		command.filter = FilterMissingEpisodes
 		responseText = "Series with Missing Episodes"
+	case FilterWatchedEnded:
+		filteredSeries = filterSeries(command.library, func(series *sonarr.Series) bool {
+			return series.Ended && command.watched != nil && seriesFullyWatched(command.watched, series)
+		})
+		command.filter = FilterWatchedEnded
+		responseText = "Fully Watched & Ended Series"
 	case FilterShowAll:
 		filteredSeries = filterSeries(command.library, func(series *sonarr.Series) bool {
 			return true
@@ -222,7 +238,7 @@ func (b *Bot) showLibraryMenuFiltered(command *userLibrary) bool {
 
 		// Pagination parameters
 		page := command.page
-		pageSize := b.Config.MaxItems
+		pageSize := b.getConfig().MaxItems
 		totalPages := (len(filteredSeries) + pageSize - 1) / pageSize
 
 		// Calculate start and end index for the current page
@@ -237,7 +253,11 @@ func (b *Bot) showLibraryMenuFiltered(command *userLibrary) bool {
 		sort.SliceStable(filteredSeries, func(i, j int) bool {
 			return utils.IgnoreArticles(strings.ToLower(filteredSeries[i].Title)) < utils.IgnoreArticles(strings.ToLower(filteredSeries[j].Title))
 		})
-		inlineKeyboard = b.getSeriesAsInlineKeyboard(filteredSeries[startIndex:endIndex])
+		label, searchText, searchData := libraryLabel, "\U0001F50D Search", LibraryFilteredSearch
+		if command.filter == FilterSearchResults && command.lookup {
+			label, searchText, searchData = searchResultLabel, "\U0001F50D Refine search", LibraryFilteredRefine
+		}
+		inlineKeyboard = b.getSeriesAsInlineKeyboard(filteredSeries[startIndex:endIndex], label)
 
 		// Create pagination buttons
 		if len(filteredSeries) > pageSize {
@@ -259,7 +279,10 @@ func (b *Bot) showLibraryMenuFiltered(command *userLibrary) bool {
 			inlineKeyboard = append(inlineKeyboard, paginationButtons)
 		}
 
-		row = append(row, tgbotapi.NewInlineKeyboardButtonData("\U0001F519", LibraryFilteredGoBack))
+		row = append(row,
+			tgbotapi.NewInlineKeyboardButtonData(searchText, searchData),
+			tgbotapi.NewInlineKeyboardButtonData("\U0001F519", LibraryFilteredGoBack),
+		)
 		inlineKeyboard = append(inlineKeyboard, row)
 	}
 
@@ -299,10 +322,6 @@ func (b *Bot) handleSearchResults(update tgbotapi.Update, searchResults []*sonar
 		b.sendMessageWithEdit(command, "No series found matching your search criteria")
 		return
 	}
-	if len(searchResults) > 25 {
-		b.sendMessageWithEdit(command, "Result size too large, please narrow down your search criteria")
-		return
-	}
 
 	// if series has a sonarr ID, it's in the library
 	var seriesInLibrary []*sonarr.Series
@@ -317,6 +336,8 @@ func (b *Bot) handleSearchResults(update tgbotapi.Update, searchResults []*sonar
 	}
 
 	command.searchResultsInLibrary = seriesInLibrary
+	command.lookup = true
+	command.page = 0
 
 	// go to series details
 	if len(seriesInLibrary) == 1 {
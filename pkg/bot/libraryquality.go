@@ -0,0 +1,305 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
+)
+
+const (
+	LibrarySeriesQuality        = "LIBRARY_SERIES_QUALITY"
+	LibrarySeriesQualitySearch  = "LIBRARY_SERIES_QUALITY_SEARCH"
+	LibrarySeriesQualityGoBack  = "LIBRARY_SERIES_QUALITY_GOBACK"
+	LibrarySeasonQuality        = "LIBRARY_SEASON_QUALITY"
+	LibrarySeasonQualitySearch  = "LIBRARY_SEASON_QUALITY_SEARCH"
+	LibrarySeasonQualityGoBack  = "LIBRARY_SEASON_QUALITY_GOBACK"
+	BelowCutoffIcon             = "⬆️"
+	libraryQualityMaxBelowFiles = 20
+)
+
+// showLibraryQuality shows what the episode files of the series, or only of
+// season if not nil, are made of and which of them are below the cutoff.
+func (b *Bot) showLibraryQuality(command *userLibrary, season *sonarr.Season) bool {
+	files, err := b.getSonarrServer().GetSeriesEpisodeFiles(command.series.ID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	if season != nil {
+		var seasonFiles []*sonarr.EpisodeFile
+		for _, file := range files {
+			if file.SeasonNumber == season.SeasonNumber {
+				seasonFiles = append(seasonFiles, file)
+			}
+		}
+		files = seasonFiles
+	}
+	profile := getQualityProfileByID(command.qualityProfiles, command.series.QualityProfileID)
+
+	series := command.series
+	var text strings.Builder
+	switch {
+	case season == nil:
+		fmt.Fprintf(&text, "[%v](https://www.imdb.com/title/%v) \\- _%v_\n\n", utils.Escape(series.Title), series.ImdbID, series.Year)
+	case season.SeasonNumber == 0:
+		fmt.Fprintf(&text, "[%v](https://www.imdb.com/title/%v) \\- _%v_ \\- Specials\n\n", utils.Escape(series.Title), series.ImdbID, series.Year)
+	default:
+		fmt.Fprintf(&text, "[%v](https://www.imdb.com/title/%v) \\- _%v_ \\- Season _%v_\n\n", utils.Escape(series.Title), series.ImdbID, series.Year, season.SeasonNumber)
+	}
+	text.WriteString(episodeFilesQualityText(files, command.allEpisodes, profile))
+
+	belowCutoff := episodesBelowCutoff(files, command.allEpisodes)
+	searchData, goBackData := LibrarySeriesQualitySearch, LibrarySeriesQualityGoBack
+	if season != nil {
+		searchData, goBackData = LibrarySeasonQualitySearch, LibrarySeasonQualityGoBack
+	}
+	var buttonLabels []string
+	var buttonData []string
+	if len(belowCutoff) > 0 {
+		buttonLabels = append(buttonLabels, fmt.Sprintf("🔍 Search upgrades (%d)", len(belowCutoff)))
+		buttonData = append(buttonData, searchData)
+	}
+	buttonLabels = append(buttonLabels, "\U0001F519")
+	buttonData = append(buttonData, goBackData)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		text.String(),
+		b.createKeyboard(buttonLabels, buttonData),
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setLibraryState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+// handleLibraryQualitySearchUpgrades searches the episodes whose files are
+// below the cutoff of the series, or only of season if not nil.
+func (b *Bot) handleLibraryQualitySearchUpgrades(command *userLibrary, season *sonarr.Season) bool {
+	files, err := b.getSonarrServer().GetSeriesEpisodeFiles(command.series.ID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	var episodeIDs []int64
+	for _, episode := range episodesBelowCutoff(files, command.allEpisodes) {
+		if season == nil || episode.SeasonNumber == season.SeasonNumber {
+			episodeIDs = append(episodeIDs, episode.ID)
+		}
+	}
+	if len(episodeIDs) == 0 {
+		return b.showLibraryQuality(command, season)
+	}
+
+	cmd := sonarr.CommandRequest{
+		Name:       "EpisodeSearch",
+		EpisodeIDs: episodeIDs,
+	}
+	if _, err := b.getSonarrServer().SendCommand(&cmd); err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	b.audit(command.chatID, "searched upgrades", command.series.Title, "series_id", command.series.ID, "episodes", len(episodeIDs))
+	msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("Searching upgrades for %d episodes of %v", len(episodeIDs), command.series.Title))
+	b.sendMessage(msg)
+	return false
+}
+
+// episodeFilesQualityText counts the files per quality, resolution, codec,
+// language and custom format score in MarkdownV2 and lists the files below
+// the cutoff.
+func episodeFilesQualityText(files []*sonarr.EpisodeFile, episodes []*sonarr.Episode, profile *sonarr.QualityProfile) string {
+	var text strings.Builder
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.Size
+	}
+	fmt.Fprintf(&text, "Files: %d, %v\n", len(files), utils.Escape(utils.ByteCountSI(totalSize)))
+	if len(files) == 0 {
+		return text.String()
+	}
+
+	breakdowns := []struct {
+		label  string
+		values func(file *sonarr.EpisodeFile) []string
+	}{
+		{"Quality", func(file *sonarr.EpisodeFile) []string {
+			if file.Quality == nil || file.Quality.Quality == nil {
+				return []string{"unknown"}
+			}
+			return []string{file.Quality.Quality.Name}
+		}},
+		{"Resolution", func(file *sonarr.EpisodeFile) []string {
+			return []string{mediaInfoValue(file, func(info *sonarr.MediaInfo) string { return info.Resolution })}
+		}},
+		{"Video codec", func(file *sonarr.EpisodeFile) []string {
+			return []string{mediaInfoValue(file, func(info *sonarr.MediaInfo) string { return info.VideoCodec })}
+		}},
+		{"Audio codec", func(file *sonarr.EpisodeFile) []string {
+			return []string{mediaInfoValue(file, func(info *sonarr.MediaInfo) string { return info.AudioCodec })}
+		}},
+		{"Audio languages", func(file *sonarr.EpisodeFile) []string {
+			if file.MediaInfo == nil {
+				return []string{"unknown"}
+			}
+			return mediaInfoLanguages(file.MediaInfo.AudioLanguages)
+		}},
+		{"Subtitles", func(file *sonarr.EpisodeFile) []string {
+			if file.MediaInfo == nil {
+				return []string{"unknown"}
+			}
+			return mediaInfoLanguages(file.MediaInfo.Subtitles)
+		}},
+		{"Custom format score", func(file *sonarr.EpisodeFile) []string {
+			return []string{fmt.Sprintf("%+d", customFormatScore(file, profile))}
+		}},
+	}
+	for _, breakdown := range breakdowns {
+		counts := make(map[string]int)
+		for _, file := range files {
+			for _, value := range breakdown.values(file) {
+				counts[value]++
+			}
+		}
+		fmt.Fprintf(&text, "%v: %v\n", breakdown.label, utils.Escape(countsText(counts)))
+	}
+
+	var below []*sonarr.EpisodeFile
+	for _, file := range files {
+		if file.QualityCutoffNotMet {
+			below = append(below, file)
+		}
+	}
+	if len(below) == 0 {
+		fmt.Fprintf(&text, "\nAll files meet the cutoff %v\n", MonitorIcon)
+		return text.String()
+	}
+	fmt.Fprintf(&text, "\n%v *Below cutoff* \\(%d\\)\n", BelowCutoffIcon, len(below))
+	names := episodeFileNames(episodes)
+	sort.SliceStable(below, func(i, j int) bool {
+		return names[below[i].ID] < names[below[j].ID]
+	})
+	for i, file := range below {
+		if i == libraryQualityMaxBelowFiles {
+			fmt.Fprintf(&text, "\\.\\.\\. and %d more\n", len(below)-libraryQualityMaxBelowFiles)
+			break
+		}
+		name := names[file.ID]
+		if name == "" {
+			name = file.RelativePath
+		}
+		quality := "unknown"
+		if file.Quality != nil && file.Quality.Quality != nil {
+			quality = file.Quality.Quality.Name
+		}
+		fmt.Fprintf(&text, "%v: %v\n", utils.Escape(name), utils.Escape(quality))
+	}
+	return text.String()
+}
+
+// customFormatScore adds up the profile scores of the file's custom formats.
+func customFormatScore(file *sonarr.EpisodeFile, profile *sonarr.QualityProfile) int64 {
+	if profile == nil {
+		return 0
+	}
+	var score int64
+	for _, format := range file.CustomFormats {
+		for _, item := range profile.FormatItems {
+			if item.Format == format.ID {
+				score += item.Score
+			}
+		}
+	}
+	return score
+}
+
+func mediaInfoValue(file *sonarr.EpisodeFile, value func(info *sonarr.MediaInfo) string) string {
+	if file.MediaInfo == nil || value(file.MediaInfo) == "" {
+		return "unknown"
+	}
+	return value(file.MediaInfo)
+}
+
+// mediaInfoLanguages splits Sonarr's "English/German" language lists.
+func mediaInfoLanguages(languages string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, language := range strings.Split(languages, "/") {
+		language = strings.TrimSpace(language)
+		if language != "" && !seen[language] {
+			seen[language] = true
+			result = append(result, language)
+		}
+	}
+	if len(result) == 0 {
+		return []string{"none"}
+	}
+	return result
+}
+
+// countsText formats counts as "WEBDL-1080p 8, HDTV-720p 2", most common
+// first.
+func countsText(counts map[string]int) string {
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprintf("%v %d", value, counts[value])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// episodeFileNames maps episode file IDs to names like "S01E03".
+func episodeFileNames(episodes []*sonarr.Episode) map[int64]string {
+	names := make(map[int64]string)
+	for _, episode := range episodes {
+		if episode.EpisodeFileID == 0 {
+			continue
+		}
+		name := fmt.Sprintf("S%02dE%02d", episode.SeasonNumber, episode.EpisodeNumber)
+		if existing, ok := names[episode.EpisodeFileID]; ok {
+			// Multi-episode files, e.g. "S01E01-E02"
+			name = existing + fmt.Sprintf("-E%02d", episode.EpisodeNumber)
+		}
+		names[episode.EpisodeFileID] = name
+	}
+	return names
+}
+
+// episodesBelowCutoff returns the episodes whose files are below the cutoff.
+func episodesBelowCutoff(files []*sonarr.EpisodeFile, episodes []*sonarr.Episode) []*sonarr.Episode {
+	below := make(map[int64]bool)
+	for _, file := range files {
+		if file.QualityCutoffNotMet {
+			below[file.ID] = true
+		}
+	}
+	var result []*sonarr.Episode
+	for _, episode := range episodes {
+		if episode.EpisodeFileID != 0 && below[episode.EpisodeFileID] {
+			result = append(result, episode)
+		}
+	}
+	return result
+}
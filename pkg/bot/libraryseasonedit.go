@@ -2,7 +2,8 @@ package bot
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -30,7 +31,7 @@ const (
 func (b *Bot) librarySeasonEdit(update tgbotapi.Update) bool {
 	chatID, err := b.getChatID(update)
 	if err != nil {
-		fmt.Printf("Cannot manage library: %v", err)
+		slog.Warn("Cannot manage library", "update_id", update.UpdateID, "error", err)
 		return false
 	}
 
@@ -60,7 +61,13 @@ func (b *Bot) librarySeasonEdit(update tgbotapi.Update) bool {
 	case LibrarySeasonMonitorSearchNow:
 		return b.handleLibrarySeriesSeasonMonitorSearchNow(command)
 	case LibrarySeasonDelete:
-		return b.handleLibrarySeasonDeleteSeasonUnmonitor(command)
+		return b.handleLibrarySeasonDeleteSeasonUnmonitor(update, command)
+	case LibrarySeasonQuality:
+		return b.showLibraryQuality(command, command.selectedSeason)
+	case LibrarySeasonQualitySearch:
+		return b.handleLibraryQualitySearchUpgrades(command, command.selectedSeason)
+	case LibrarySeasonQualityGoBack:
+		return b.showLibrarySeriesSeasonDetail(command)
 	default:
 		// Check if it starts with "SEASON_"
 		if strings.HasPrefix(update.CallbackQuery.Data, "SEASON_") {
@@ -126,7 +133,7 @@ func (b *Bot) handleLibrarySeasonEditSelectSeason(update tgbotapi.Update, comman
 	seasonNumberStr := strings.TrimPrefix(update.CallbackQuery.Data, "SEASON_")
 	seasonNumber, err := strconv.Atoi(seasonNumberStr)
 	if err != nil {
-		log.Println("Failed to convert season number to integer:", err)
+		b.logger(command.chatID).Error("Failed to convert season number to integer", "error", err)
 		return false
 	}
 	command.selectedSeason = getSeasonByNumber(command.series, seasonNumber)
@@ -160,7 +167,7 @@ func (b *Bot) showLibrarySeriesSeasonDetail(command *userLibrary) bool {
 		}
 	}
 
-	seariesEpisodeFiles, err := b.SonarrServer.GetSeriesEpisodeFiles(command.series.ID)
+	seariesEpisodeFiles, err := b.getSonarrServer().GetSeriesEpisodeFiles(command.series.ID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
@@ -187,7 +194,13 @@ func (b *Bot) showLibrarySeriesSeasonDetail(command *userLibrary) bool {
 	fmt.Fprintf(&message, "Last Manual Search: %s\n", utils.Escape(lastSearchString))
 	fmt.Fprintf(&message, "Episodes: %d\n", seasonEpisodesCounter)
 	fmt.Fprintf(&message, "Episodes on Disk: %d\n", len(seasonEpisodeFiles))
-	fmt.Fprintf(&message, "Size: %d GB\n", totalSize/(1024*1024*1024))
+	fmt.Fprintf(&message, "Size: %s\n", utils.Escape(utils.ByteCountSI(totalSize)))
+	if command.watched != nil {
+		fmt.Fprintf(&message, "Watched: %d\n", command.watched.SeasonCount(series.TvdbID, season.SeasonNumber))
+		if len(seasonEpisodeFiles) > 0 && seasonFullyWatched(command.watched, series, season) {
+			fmt.Fprintf(&message, "\n%v Fully watched, its files can be deleted\n", CleanupIcon)
+		}
+	}
 
 	messageText := message.String()
 
@@ -200,8 +213,8 @@ func (b *Bot) showLibrarySeriesSeasonDetail(command *userLibrary) bool {
 		)
 	} else if season.Monitored && len(seasonEpisodeFiles) > 0 {
 		keyboard = b.createKeyboard(
-			[]string{"Unmonitor Season", "Search Season", "Delete Season & Unmonitor", "\U0001F519"},
-			[]string{LibrarySeasonUnmonitor, LibrarySeasonSearch, LibrarySeasonDelete, LibrarySeasonGoBack},
+			[]string{"Unmonitor Season", "Search Season", "Delete Season & Unmonitor", "📊 Quality", "\U0001F519"},
+			[]string{LibrarySeasonUnmonitor, LibrarySeasonSearch, LibrarySeasonDelete, LibrarySeasonQuality, LibrarySeasonGoBack},
 		)
 	} else if !season.Monitored && len(seasonEpisodeFiles) == 0 {
 		keyboard = b.createKeyboard(
@@ -210,8 +223,8 @@ func (b *Bot) showLibrarySeriesSeasonDetail(command *userLibrary) bool {
 		)
 	} else if !season.Monitored && len(seasonEpisodeFiles) > 0 {
 		keyboard = b.createKeyboard(
-			[]string{"Monitor Season", "Monitor Season & Search Now", "Delete Season & Unmonitor", "\U0001F519"},
-			[]string{LibrarySeasonMonitor, LibrarySeasonMonitorSearchNow, LibrarySeasonDelete, LibrarySeasonGoBack},
+			[]string{"Monitor Season", "Monitor Season & Search Now", "Delete Season & Unmonitor", "📊 Quality", "\U0001F519"},
+			[]string{LibrarySeasonMonitor, LibrarySeasonMonitorSearchNow, LibrarySeasonDelete, LibrarySeasonQuality, LibrarySeasonGoBack},
 		)
 	}
 	// // Send the message containing series details along with the keyboard
@@ -236,12 +249,13 @@ func (b *Bot) handleLibrarySeriesSeasonMonitor(command *userLibrary) bool {
 	input.Seasons[0].Monitored = *starr.True()
 
 	// Update the series on the server
-	_, err := b.SonarrServer.UpdateSeries(input, *starr.False())
+	_, err := b.getSonarrServer().UpdateSeries(input, *starr.False())
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
+	b.audit(command.chatID, "monitored season", seasonTitle(command.series, command.selectedSeason), "series_id", command.series.ID)
 
 	b.setLibraryState(command.chatID, command)
 	return b.showLibrarySeriesSeasonDetail(command)
@@ -255,12 +269,13 @@ func (b *Bot) handleLibrarySeriesSeasonUnmonitor(command *userLibrary) bool {
 	// Convert the updated series to AddSeriesInput
 	input := seriesToAddSeriesInput(command.series)
 	// Update the series on the server
-	_, err := b.SonarrServer.UpdateSeries(input, *starr.False())
+	_, err := b.getSonarrServer().UpdateSeries(input, *starr.False())
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
+	b.audit(command.chatID, "unmonitored season", seasonTitle(command.series, season), "series_id", command.series.ID)
 
 	b.setLibraryState(command.chatID, command)
 	return b.showLibrarySeriesSeasonDetail(command)
@@ -272,7 +287,7 @@ func (b *Bot) handleLibrarySeriesSeasonSearch(command *userLibrary) bool {
 		SeriesID:     command.series.ID,
 		SeasonNumber: command.selectedSeason.SeasonNumber,
 	}
-	_, err := b.SonarrServer.SendCommand(&cmd)
+	_, err := b.getSonarrServer().SendCommand(&cmd)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
@@ -287,19 +302,20 @@ func (b *Bot) handleLibrarySeriesSeasonMonitorSearchNow(command *userLibrary) bo
 	// Update the Monitored field of the season
 	command.selectedSeason.Monitored = *starr.True()
 	input := seriesToAddSeriesInput(command.series)
-	_, err := b.SonarrServer.UpdateSeries(input, *starr.False())
+	_, err := b.getSonarrServer().UpdateSeries(input, *starr.False())
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
+	b.audit(command.chatID, "monitored season", seasonTitle(command.series, command.selectedSeason), "series_id", command.series.ID)
 
 	cmd := sonarr.CommandRequest{
 		Name:         "SeasonSearch",
 		SeriesID:     command.series.ID,
 		SeasonNumber: command.selectedSeason.SeasonNumber,
 	}
-	_, err = b.SonarrServer.SendCommand(&cmd)
+	_, err = b.getSonarrServer().SendCommand(&cmd)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
@@ -310,50 +326,39 @@ func (b *Bot) handleLibrarySeriesSeasonMonitorSearchNow(command *userLibrary) bo
 	return b.showLibrarySeriesSeasonDetail(command)
 }
 
-func (b *Bot) handleLibrarySeasonDeleteSeasonUnmonitor(command *userLibrary) bool {
-	// Access the specific season
-	season := command.selectedSeason
-	episodes, err := b.SonarrServer.GetSeriesEpisodeFiles(command.series.ID)
+func (b *Bot) handleLibrarySeasonDeleteSeasonUnmonitor(update tgbotapi.Update, command *userLibrary) bool {
+	seriesID := command.series.ID
+	seasonNumber := command.selectedSeason.SeasonNumber
+	title := seasonTitle(command.series, command.selectedSeason)
+
+	if b.getConfig().DeleteGracePeriod > 0 {
+		b.clearState(update)
+		b.scheduleDelete(command, fmt.Sprintf("Deleting all files of %v", title), fmt.Sprintf("Files of %v deleted\n", title),
+			func(user *tgbotapi.User) error {
+				return b.deleteSeasonFiles(user, command.chatID, seriesID, seasonNumber, nil)
+			})
+		return true
+	}
+
+	err := b.deleteSeasonFiles(b.updateUser(command.chatID), command.chatID, seriesID, seasonNumber, nil)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
-	for _, episode := range episodes {
-		if episode.SeasonNumber == season.SeasonNumber {
-			err := b.SonarrServer.DeleteEpisodeFile(episode.ID)
-			if err != nil {
-				msg := tgbotapi.NewMessage(command.chatID, err.Error())
-				b.sendMessage(msg)
-				return false
-			}
-		}
-	}
-
-	if season.Monitored {
-		// Update the Monitored field of the season
-		season.Monitored = *starr.False()
-		// Convert the updated series to AddSeriesInput
-		input := seriesToAddSeriesInput(command.series)
-		// Update the series on the server
-		_, err := b.SonarrServer.UpdateSeries(input, *starr.False())
-		if err != nil {
-			msg := tgbotapi.NewMessage(command.chatID, err.Error())
-			b.sendMessage(msg)
-			return false
-		}
-	}
 
-	series, err := b.SonarrServer.GetSeriesByID(command.series.ID)
+	series, err := b.getSonarrServer().GetSeriesByID(command.series.ID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
 	command.series = series
+	command.selectedSeason = getSeasonByNumber(series, seasonNumber)
+	command.seriesSeasons[seasonNumber] = command.selectedSeason
 
 	// get all episodeFiles
-	episodeFiles, err := b.SonarrServer.GetSeriesEpisodeFiles(series.ID)
+	episodeFiles, err := b.getSonarrServer().GetSeriesEpisodeFiles(series.ID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
@@ -370,15 +375,56 @@ func (b *Bot) handleLibrarySeasonEditSubmitChanges(update tgbotapi.Update, comma
 	command.series.QualityProfileID = command.selectedQualityProfile
 	command.series.Tags = command.selectedTags
 	input := seriesToAddSeriesInput(command.series)
-	_, err := b.SonarrServer.UpdateSeries(input, *starr.False())
+	_, err := b.getSonarrServer().UpdateSeries(input, *starr.False())
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
+	b.audit(command.chatID, "edited series", command.series.Title, "series_id", command.series.ID,
+		"monitored", command.series.Monitored, "quality_profile_id", command.series.QualityProfileID, "tags", command.series.Tags)
 
 	text := fmt.Sprintf("Series '%v' updated\n", command.series.Title)
 	b.clearState(update)
 	b.sendMessageWithEdit(command, text)
 	return true
 }
+
+// deleteSeasonFiles deletes the episode files of a season and unmonitors the
+// season, so that Sonarr does not download it again. Only files in fileIDs
+// are deleted unless it is nil.
+func (b *Bot) deleteSeasonFiles(user *tgbotapi.User, chatID int64, seriesID int64, seasonNumber int, fileIDs []int64) error {
+	episodeFiles, err := b.getSonarrServer().GetSeriesEpisodeFiles(seriesID)
+	if err != nil {
+		return err
+	}
+	var deletedFiles int
+	for _, episodeFile := range episodeFiles {
+		if episodeFile.SeasonNumber == seasonNumber && (fileIDs == nil || slices.Contains(fileIDs, episodeFile.ID)) {
+			err := b.getSonarrServer().DeleteEpisodeFile(episodeFile.ID)
+			if err != nil {
+				return err
+			}
+			deletedFiles++
+		}
+	}
+
+	series, err := b.getSonarrServer().GetSeriesByID(seriesID)
+	if err != nil {
+		return err
+	}
+	for _, season := range series.Seasons {
+		if season.SeasonNumber != seasonNumber {
+			continue
+		}
+		b.auditAs(user, chatID, "deleted season files", seasonTitle(series, season), "series_id", seriesID, "episode_files", deletedFiles)
+		if season.Monitored {
+			season.Monitored = *starr.False()
+			_, err := b.getSonarrServer().UpdateSeries(seriesToAddSeriesInput(series), *starr.False())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package bot
 
 import (
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 
@@ -16,12 +17,15 @@ const (
 	LibrarySeriesEditSubmitChanges        = "LIBRARY_SERIES_EDIT_SUBMIT_CHANGES"
 	LibrarySeriesEditGoBack               = "LIBRARY_SERIES_EDIT_GOBACK"
 	LibrarySeriesEditCancel               = "LIBRARY_SERIES_EDIT_CANCEL"
+	LibrarySeriesEditNewTag               = "LIBRARY_SERIES_EDIT_NEW_TAG"
+	LibrarySeriesEditProfileDetails       = "LIBRARY_SERIES_EDIT_PROFILE_DETAILS"
+	LibrarySeriesEditProfileDetailsGoBack = "LIBRARY_SERIES_EDIT_PROFILE_DETAILS_GOBACK"
 )
 
 func (b *Bot) librarySeriesEdit(update tgbotapi.Update) bool {
 	chatID, err := b.getChatID(update)
 	if err != nil {
-		fmt.Printf("Cannot manage library: %v", err)
+		slog.Warn("Cannot manage library", "update_id", update.UpdateID, "error", err)
 		return false
 	}
 
@@ -44,6 +48,16 @@ func (b *Bot) librarySeriesEdit(update tgbotapi.Update) bool {
 		b.clearState(update)
 		b.sendMessageWithEdit(command, CommandsCleared)
 		return false
+	case LibrarySeriesEditNewTag:
+		b.askForText(chatID, TagNamePrompt, TagNamePlaceholder, func(text string) bool {
+			return b.handleLibrarySeriesEditTagName(command, text)
+		})
+		return false
+	case LibrarySeriesEditProfileDetails:
+		qualityProfile := getQualityProfileByID(command.qualityProfiles, command.selectedQualityProfile)
+		return b.showQualityProfileDetails(command, qualityProfile, LibrarySeriesEditProfileDetailsGoBack)
+	case LibrarySeriesEditProfileDetailsGoBack:
+		return b.showLibrarySeriesEdit(command)
 	default:
 		// Check if it starts with "TAG_"
 		if strings.HasPrefix(update.CallbackQuery.Data, "TAG_") {
@@ -71,6 +85,8 @@ func (b *Bot) showLibrarySeriesEdit(command *userLibrary) bool {
 		[]string{"Monitored: " + monitorIcon, qualityProfile},
 		[]string{LibrarySeriesEditToggleMonitor, LibrarySeriesEditToggleQualityProfile},
 	)
+	keyboard.InlineKeyboard[1] = append(keyboard.InlineKeyboard[1],
+		tgbotapi.NewInlineKeyboardButtonData("ℹ️ Details", LibrarySeriesEditProfileDetails))
 
 	var tagsKeyboard [][]tgbotapi.InlineKeyboardButton
 	for _, tag := range command.allTags {
@@ -93,8 +109,8 @@ func (b *Bot) showLibrarySeriesEdit(command *userLibrary) bool {
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tagsKeyboard...)
 
 	keyboardSubmitCancelGoBack := b.createKeyboard(
-		[]string{"Submit - Confirm Changes", "Cancel - clear command", "\U0001F519"},
-		[]string{LibrarySeriesEditSubmitChanges, LibrarySeriesEditCancel, LibrarySeriesEditGoBack},
+		[]string{"➕ New tag", "Submit - Confirm Changes", "Cancel - clear command", "\U0001F519"},
+		[]string{LibrarySeriesEditNewTag, LibrarySeriesEditSubmitChanges, LibrarySeriesEditCancel, LibrarySeriesEditGoBack},
 	)
 
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, keyboardSubmitCancelGoBack.InlineKeyboard...)
@@ -114,6 +130,18 @@ func (b *Bot) showLibrarySeriesEdit(command *userLibrary) bool {
 
 }
 
+// handleLibrarySeriesEditTagName creates and selects the typed tag.
+func (b *Bot) handleLibrarySeriesEditTagName(command *userLibrary, text string) bool {
+	allTags, selectedTags, err := b.addTypedTag(command.chatID, text, command.allTags, command.selectedTags)
+	if err != nil {
+		return b.handleTypedTagError(command.chatID, err)
+	}
+	command.allTags = allTags
+	command.selectedTags = selectedTags
+	b.showLibrarySeriesEdit(command)
+	return true
+}
+
 func (b *Bot) handleLibrarySeriesEditToggleMonitor(command *userLibrary) bool {
 	command.selectedMonitoring = !command.selectedMonitoring
 	b.setLibraryState(command.chatID, command)
@@ -133,7 +161,7 @@ func (b *Bot) handleLibrarySeriesEditSelectTag(update tgbotapi.Update, command *
 	// Parse the tag ID
 	tagID, err := strconv.Atoi(tagIDStr)
 	if err != nil {
-		fmt.Printf("Cannot convert tag string to int: %v", err)
+		b.logger(command.chatID).Error("Cannot convert tag string to int", "error", err)
 		return false
 	}
 
@@ -157,12 +185,14 @@ func (b *Bot) handleLibrarySeriesEditSubmitChanges(update tgbotapi.Update, comma
 	command.series.Tags = command.selectedTags
 
 	input := seriesToAddSeriesInput(command.series)
-	_, err := b.SonarrServer.UpdateSeries(input, *starr.False())
+	_, err := b.getSonarrServer().UpdateSeries(input, *starr.False())
 	if err != nil {
 		msg := tgbotapi.NewMessage(command.chatID, err.Error())
 		b.sendMessage(msg)
 		return false
 	}
+	b.audit(command.chatID, "edited series", command.series.Title, "series_id", command.series.ID,
+		"monitored", command.series.Monitored, "quality_profile_id", command.series.QualityProfileID, "tags", command.series.Tags)
 
 	text := fmt.Sprintf("Series '%v' updated\n", command.series.Title)
 	b.clearState(update)
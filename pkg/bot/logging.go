@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// updateContext describes the update that is currently handled for a chat.
+type updateContext struct {
+	logger *slog.Logger
+	user   *tgbotapi.User
+}
+
+// setUpdateContext remembers the update handled for its chat, so that log
+// lines and audit entries contain chat, user, command and callback data.
+func (b *Bot) setUpdateContext(chatID int64, update tgbotapi.Update) {
+	attrs := []any{"chat_id", chatID}
+	user := update.SentFrom()
+	if user != nil {
+		attrs = append(attrs, "user_id", user.ID, "user_name", user.UserName)
+	}
+	if update.Message != nil && update.Message.IsCommand() {
+		attrs = append(attrs, "command", update.Message.Command())
+	}
+	if update.CallbackQuery != nil {
+		attrs = append(attrs, "callback_data", update.CallbackQuery.Data)
+	}
+
+	b.muUpdateContexts.Lock()
+	defer b.muUpdateContexts.Unlock()
+	b.updateContexts[chatID] = &updateContext{
+		logger: slog.With(attrs...),
+		user:   user,
+	}
+}
+
+func (b *Bot) clearUpdateContext(chatID int64) {
+	b.muUpdateContexts.Lock()
+	defer b.muUpdateContexts.Unlock()
+	delete(b.updateContexts, chatID)
+}
+
+// logger returns a logger with the context of the update currently handled
+// for the chat, or with just the chat ID outside of update handling.
+func (b *Bot) logger(chatID int64) *slog.Logger {
+	b.muUpdateContexts.Lock()
+	defer b.muUpdateContexts.Unlock()
+	if ctx, exists := b.updateContexts[chatID]; exists {
+		return ctx.logger
+	}
+	return slog.With("chat_id", chatID)
+}
+
+// audit records a destructive action, e.g. "deleted series", in the audit log
+// and optionally notifies the admins.
+func (b *Bot) audit(chatID int64, action string, target string, attrs ...any) {
+	b.auditAs(b.updateUser(chatID), chatID, action, target, attrs...)
+}
+
+// auditAs is like audit, for actions running outside of update handling,
+// e.g. deletions after the grace period.
+func (b *Bot) auditAs(user *tgbotapi.User, chatID int64, action string, target string, attrs ...any) {
+	who := fmt.Sprintf("chat %d", chatID)
+	attrs = append(attrs, "chat_id", chatID, "action", action, "target", target)
+	if user != nil {
+		who = user.String()
+		attrs = append(attrs, "user_id", user.ID, "user_name", user.UserName)
+	}
+	b.AuditLog.Info("audit", attrs...)
+
+	if !b.getConfig().AuditToAdmins {
+		return
+	}
+	text := fmt.Sprintf("%v %v: %v", who, action, target)
+	for adminChatID := range b.getConfig().AdminChatIDs {
+		if adminChatID == chatID {
+			continue
+		}
+		b.sendMessage(tgbotapi.NewMessage(adminChatID, text))
+	}
+}
+
+// updateUser returns the sender of the update currently handled for the
+// chat, or nil outside of update handling.
+func (b *Bot) updateUser(chatID int64) *tgbotapi.User {
+	b.muUpdateContexts.Lock()
+	defer b.muUpdateContexts.Unlock()
+	if ctx, exists := b.updateContexts[chatID]; exists {
+		return ctx.user
+	}
+	return nil
+}
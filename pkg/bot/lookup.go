@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// lookupIDPattern matches identifiers Sonarr's lookup understands, e.g.
+	// "tvdb:81189" or "imdb:tt0903747".
+	lookupIDPattern     = regexp.MustCompile(`(?i)\b(tvdb|imdb|tmdb|tvmaze):\s*(tt\d+|\d+)\b`)
+	imdbIDPattern       = regexp.MustCompile(`(?i)\btt\d{5,}\b`)
+	urlPattern          = regexp.MustCompile(`(?i)(https?://)?(www\.)?(imdb\.com|thetvdb\.com|tvmaze\.com|trakt\.tv|themoviedb\.org)/\S*`)
+	leadingIDPattern    = regexp.MustCompile(`^\d+`)
+	digitsPattern       = regexp.MustCompile(`^\d+$`)
+	trailingYearPattern = regexp.MustCompile(`-(19|20)\d{2}$`)
+)
+
+// lookupTerm turns search criteria into a Sonarr lookup term. Identifiers
+// and URLs from IMDb, TheTVDB, TVmaze, TMDB and Trakt anywhere in criteria
+// become "tvdb:81189" style terms and exact is true. Everything else is
+// looked up as a quoted title.
+func lookupTerm(criteria string) (term string, exact bool) {
+	if match := urlPattern.FindString(criteria); match != "" {
+		if term, exact := urlLookupTerm(match); term != "" {
+			return term, exact
+		}
+	}
+	if match := lookupIDPattern.FindStringSubmatch(criteria); match != nil {
+		return strings.ToLower(match[1]) + ":" + strings.ToLower(match[2]), true
+	}
+	if match := imdbIDPattern.FindString(criteria); match != "" && strings.TrimSpace(criteria) == match {
+		return "imdb:" + strings.ToLower(match), true
+	}
+	return "\"" + criteria + "\"", false
+}
+
+// urlLookupTerm resolves a series URL. Trakt and TheTVDB slugs contain no
+// ID, they are looked up as titles.
+func urlLookupTerm(rawURL string) (term string, exact bool) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch host {
+	case "imdb.com", "m.imdb.com":
+		if match := imdbIDPattern.FindString(u.Path); match != "" {
+			return "imdb:" + strings.ToLower(match), true
+		}
+	case "thetvdb.com":
+		if id := u.Query().Get("id"); id != "" {
+			return "tvdb:" + id, true
+		}
+		for i, part := range parts {
+			if part == "series" && i+1 < len(parts) {
+				if digitsPattern.MatchString(parts[i+1]) {
+					return "tvdb:" + parts[i+1], true
+				}
+				return slugLookupTerm(parts[i+1]), false
+			}
+		}
+	case "tvmaze.com":
+		if len(parts) >= 2 && parts[0] == "shows" {
+			if id := leadingIDPattern.FindString(parts[1]); id != "" {
+				return "tvmaze:" + id, true
+			}
+		}
+	case "themoviedb.org":
+		if len(parts) >= 2 && parts[0] == "tv" {
+			if id := leadingIDPattern.FindString(parts[1]); id != "" {
+				return "tmdb:" + id, true
+			}
+		}
+	case "trakt.tv":
+		if len(parts) >= 2 && parts[0] == "shows" {
+			return slugLookupTerm(parts[1]), false
+		}
+	}
+	return "", false
+}
+
+// slugLookupTerm turns "the-office-2005" into the quoted title "the office".
+func slugLookupTerm(slug string) string {
+	slug = trailingYearPattern.ReplaceAllString(slug, "")
+	return "\"" + strings.ReplaceAll(slug, "-", " ") + "\""
+}
@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/config"
+)
+
+const (
+	AddSeriesPreset = "ADDSERIES_PRESET_"
+	PresetIcon      = "⚡"
+	presetFlag      = "--preset"
+)
+
+// parsePresetArgument splits "--preset anime-1080p frieren" into the preset
+// name and the remaining criteria. found is false without --preset.
+func parsePresetArgument(criteria string) (name string, rest string, found bool) {
+	fields := strings.Fields(criteria)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], presetFlag) {
+		return "", criteria, false
+	}
+	if len(fields) == 1 {
+		return "", "", true
+	}
+	return strings.ToLower(fields[1]), strings.Join(fields[2:], " "), true
+}
+
+// findPreset returns the configured preset with the given name or nil.
+func (b *Bot) findPreset(name string) *config.Preset {
+	for _, preset := range b.getConfig().Presets {
+		if preset.Name == name {
+			return &preset
+		}
+	}
+	return nil
+}
+
+func (b *Bot) presetNames() string {
+	var names []string
+	for _, preset := range b.getConfig().Presets {
+		names = append(names, preset.Name)
+	}
+	if len(names) == 0 {
+		return "none configured"
+	}
+	return strings.Join(names, ", ")
+}
+
+// addSeriesPresetButtons returns a button per preset, the preset chosen with
+// /q --preset first.
+func (b *Bot) addSeriesPresetButtons(command *userAddSeries) ([]string, []string) {
+	var labels []string
+	var data []string
+	for _, preset := range b.getConfig().Presets {
+		label := PresetIcon + " Add as " + preset.Name
+		if preset.Name == command.preset {
+			labels = append([]string{label}, labels...)
+			data = append([]string{AddSeriesPreset + preset.Name}, data...)
+			continue
+		}
+		labels = append(labels, label)
+		data = append(data, AddSeriesPreset+preset.Name)
+	}
+	return labels, data
+}
+
+// handleAddSeriesPreset adds the series with the answers of a preset instead
+// of going through the wizard.
+func (b *Bot) handleAddSeriesPreset(update tgbotapi.Update, command *userAddSeries) bool {
+	if command.series.ID != 0 {
+		b.sendMessageWithEdit(command, "Series already in library\nAll commands have been cleared")
+		return false
+	}
+	preset := b.findPreset(strings.TrimPrefix(update.CallbackQuery.Data, AddSeriesPreset))
+	if preset == nil {
+		msg := tgbotapi.NewMessage(command.chatID, "This preset no longer exists")
+		b.sendMessage(msg)
+		return b.showAddSeriesDetails(command)
+	}
+	if !b.loadAddSeriesChoices(update, command) {
+		return false
+	}
+	if err := b.applyPreset(command, preset); err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("Preset %v cannot be used: %v", preset.Name, err))
+		b.logger(command.chatID).Error("Cannot apply preset", "preset", preset.Name, "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	b.setAddSeriesState(command.chatID, command)
+	return b.addSeriesToLibrary(command)
+}
+
+// applyPreset fills in the wizard's answers. Tags that do not exist yet are
+// created.
+func (b *Bot) applyPreset(command *userAddSeries, preset *config.Preset) error {
+	command.profileID = 0
+	for _, profile := range command.allProfiles {
+		if strings.EqualFold(profile.Name, preset.QualityProfile) || strconv.FormatInt(profile.ID, 10) == preset.QualityProfile {
+			command.profileID = profile.ID
+		}
+	}
+	if command.profileID == 0 {
+		return fmt.Errorf("quality profile %q not found", preset.QualityProfile)
+	}
+
+	command.rootFolder = &sonarr.RootFolder{Path: preset.RootFolder}
+	for _, folder := range command.allRootFolders {
+		if strings.TrimRight(folder.Path, `/\`) == strings.TrimRight(preset.RootFolder, `/\`) {
+			command.rootFolder = folder
+		}
+	}
+
+	command.selectedTags = nil
+	for _, label := range preset.Tags {
+		allTags, selectedTags, err := b.addTypedTag(command.chatID, label, command.allTags, command.selectedTags)
+		if errors.Is(err, errInvalidTagLabel) {
+			return fmt.Errorf("invalid tag %q", label)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot create tag %q: %w", label, err)
+		}
+		command.allTags = allTags
+		command.selectedTags = selectedTags
+	}
+
+	command.seriesType = preset.SeriesType
+	if command.seriesType == "" {
+		command.seriesType = "standard"
+	}
+	command.monitor = preset.Monitor
+	command.addSeriesOptions = addSeriesOptions(preset.Search, command.monitor)
+	return nil
+}
@@ -0,0 +1,280 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
+)
+
+const (
+	ProfilesSelect = "PROFILES_SELECT_"
+	ProfilesGoBack = "PROFILES_GOBACK"
+	ProfilesCancel = "PROFILES_CANCEL"
+	// profileMaxSeries limits the series listed in the profile details to
+	// stay below Telegram's message size.
+	profileMaxSeries = 30
+)
+
+type userProfiles struct {
+	profiles  []*sonarr.QualityProfile
+	series    []*sonarr.Series
+	chatID    int64
+	messageID int
+}
+
+func (b *Bot) processProfilesCommand(chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "Handling profiles command... please wait")
+	message, _ := b.sendMessage(msg)
+
+	command := userProfiles{
+		chatID:    message.Chat.ID,
+		messageID: message.MessageID,
+	}
+	s := b.getSonarrServer()
+	profiles, err := s.GetQualityProfiles()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	series, err := s.GetSeries(0)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	command.profiles = profiles
+	command.series = series
+	b.showProfiles(&command)
+}
+
+func (b *Bot) qualityProfiles(update tgbotapi.Update) bool {
+	chatID, err := b.getChatID(update)
+	if err != nil {
+		slog.Warn("Cannot show quality profiles", "update_id", update.UpdateID, "error", err)
+		return false
+	}
+
+	command, exists := b.getProfileState(chatID)
+	if !exists {
+		return false
+	}
+	switch update.CallbackQuery.Data {
+	case ProfilesGoBack:
+		return b.showProfiles(command)
+	case ProfilesCancel:
+		b.clearState(update)
+		b.sendMessageWithEdit(command, CommandsCleared)
+		return false
+	default:
+		if strings.HasPrefix(update.CallbackQuery.Data, ProfilesSelect) {
+			return b.handleProfileSelection(update, command)
+		}
+		return false
+	}
+}
+
+func (b *Bot) showProfiles(command *userProfiles) bool {
+	var text strings.Builder
+	var buttonLabels []string
+	var buttonData []string
+
+	fmt.Fprintf(&text, "*Quality Profiles*\n\n")
+	if len(command.profiles) == 0 {
+		fmt.Fprintf(&text, "No quality profiles defined\n")
+	}
+	for _, profile := range command.profiles {
+		fmt.Fprintf(&text, "*%v*: cutoff %v, %d series\n",
+			utils.Escape(profile.Name),
+			utils.Escape(qualityProfileCutoffName(profile)),
+			len(seriesWithQualityProfile(command.series, profile.ID)),
+		)
+		buttonLabels = append(buttonLabels, profile.Name)
+		buttonData = append(buttonData, ProfilesSelect+strconv.FormatInt(profile.ID, 10))
+	}
+	buttonLabels = append(buttonLabels, "Cancel - clear command")
+	buttonData = append(buttonData, ProfilesCancel)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		text.String(),
+		b.createKeyboard(buttonLabels, buttonData),
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setProfileState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+func (b *Bot) handleProfileSelection(update tgbotapi.Update, command *userProfiles) bool {
+	profileID, err := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, ProfilesSelect), 10, 64)
+	if err != nil {
+		b.logger(command.chatID).Error("Cannot convert profile ID to int", "error", err)
+		return false
+	}
+	profile := getQualityProfileByID(command.profiles, profileID)
+	if profile == nil {
+		return b.showProfiles(command)
+	}
+	b.setProfileState(command.chatID, command)
+	b.sendQualityProfile(command, profile, command.series, ProfilesGoBack)
+	return false
+}
+
+// showQualityProfileDetails loads the library and shows the profile details
+// with a back button sending goBack. Used by the add and edit wizards.
+func (b *Bot) showQualityProfileDetails(command Command, profile *sonarr.QualityProfile, goBack string) bool {
+	if profile == nil {
+		return false
+	}
+	series, err := b.getSonarrServer().GetSeries(0)
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.GetChatID(), err.Error())
+		b.logger(command.GetChatID()).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	b.sendQualityProfile(command, profile, series, goBack)
+	return false
+}
+
+func (b *Bot) sendQualityProfile(command Command, profile *sonarr.QualityProfile, series []*sonarr.Series, goBack string) {
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.GetChatID(),
+		command.GetMessageID(),
+		qualityProfileText(profile, series),
+		b.createKeyboard([]string{"\U0001F519"}, []string{goBack}),
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.sendMessage(editMsg)
+}
+
+// qualityProfileText describes a profile in MarkdownV2: the allowed qualities
+// from most to least preferred, cutoff, upgrades, custom format scores and
+// the series using it.
+func qualityProfileText(profile *sonarr.QualityProfile, series []*sonarr.Series) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "*%v*\n\n", utils.Escape(profile.Name))
+
+	fmt.Fprintf(&text, "*Qualities*\n")
+	allowed := allowedQualityNames(profile)
+	if len(allowed) == 0 {
+		fmt.Fprintf(&text, "None allowed\n")
+	}
+	for i, name := range allowed {
+		fmt.Fprintf(&text, "%d\\. %v\n", i+1, utils.Escape(name))
+	}
+
+	fmt.Fprintf(&text, "\nCutoff: %v\n", utils.Escape(qualityProfileCutoffName(profile)))
+	if profile.UpgradeAllowed {
+		fmt.Fprintf(&text, "Upgrades allowed: %v\n", MonitorIcon)
+	} else {
+		fmt.Fprintf(&text, "Upgrades allowed: %v\n", UnmonitorIcon)
+	}
+	fmt.Fprintf(&text, "Minimum custom format score: %v\n", utils.Escape(strconv.FormatInt(profile.MinFormatScore, 10)))
+	fmt.Fprintf(&text, "Upgrade until custom format score: %v\n", utils.Escape(strconv.FormatInt(profile.CutoffFormatScore, 10)))
+
+	var formats []*starr.FormatItem
+	for _, format := range profile.FormatItems {
+		if format.Score != 0 {
+			formats = append(formats, format)
+		}
+	}
+	sort.SliceStable(formats, func(i, j int) bool {
+		return formats[i].Score > formats[j].Score
+	})
+	if len(formats) > 0 {
+		fmt.Fprintf(&text, "\n*Custom Formats*\n")
+	}
+	for _, format := range formats {
+		fmt.Fprintf(&text, "%v: %v\n", utils.Escape(format.Name), utils.Escape(fmt.Sprintf("%+d", format.Score)))
+	}
+
+	using := seriesWithQualityProfile(series, profile.ID)
+	fmt.Fprintf(&text, "\n*Series* \\(%d\\)\n", len(using))
+	for i, s := range using {
+		if i == profileMaxSeries {
+			fmt.Fprintf(&text, "\\.\\.\\. and %d more\n", len(using)-profileMaxSeries)
+			break
+		}
+		fmt.Fprintf(&text, "%v\n", utils.Escape(s.Title))
+	}
+	return text.String()
+}
+
+// allowedQualityNames lists the allowed qualities and groups, most preferred
+// first. Sonarr orders the profile items from least to most preferred.
+func allowedQualityNames(profile *sonarr.QualityProfile) []string {
+	var names []string
+	for i := len(profile.Qualities) - 1; i >= 0; i-- {
+		item := profile.Qualities[i]
+		if !item.Allowed {
+			continue
+		}
+		if len(item.Items) == 0 {
+			names = append(names, qualityItemName(item))
+			continue
+		}
+		var members []string
+		for j := len(item.Items) - 1; j >= 0; j-- {
+			if item.Items[j].Allowed {
+				members = append(members, qualityItemName(item.Items[j]))
+			}
+		}
+		names = append(names, fmt.Sprintf("%v (%v)", item.Name, strings.Join(members, ", ")))
+	}
+	return names
+}
+
+// qualityProfileCutoffName returns the name of the cutoff quality or group.
+func qualityProfileCutoffName(profile *sonarr.QualityProfile) string {
+	for _, item := range profile.Qualities {
+		if len(item.Items) > 0 && int64(item.ID) == profile.Cutoff {
+			return item.Name
+		}
+		if item.Quality != nil && item.Quality.ID == profile.Cutoff {
+			return item.Quality.Name
+		}
+		for _, member := range item.Items {
+			if member.Quality != nil && member.Quality.ID == profile.Cutoff {
+				return member.Quality.Name
+			}
+		}
+	}
+	return "unknown"
+}
+
+func qualityItemName(item *starr.Quality) string {
+	if item.Quality != nil {
+		return item.Quality.Name
+	}
+	return item.Name
+}
+
+// seriesWithQualityProfile returns the series using the profile sorted by
+// title.
+func seriesWithQualityProfile(series []*sonarr.Series, profileID int64) []*sonarr.Series {
+	var using []*sonarr.Series
+	for _, s := range series {
+		if s.QualityProfileID == profileID {
+			using = append(using, s)
+		}
+	}
+	sort.SliceStable(using, func(i, j int) bool {
+		return utils.IgnoreArticles(strings.ToLower(using[i].Title)) < utils.IgnoreArticles(strings.ToLower(using[j].Title))
+	})
+	return using
+}
@@ -0,0 +1,431 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/sonarrapi"
+	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
+)
+
+const (
+	ProvidersSelect  = "PROVIDERS_SELECT_"
+	ProvidersTest    = "PROVIDERS_TEST"
+	ProvidersTestAll = "PROVIDERS_TEST_ALL"
+	ProvidersToggle  = "PROVIDERS_TOGGLE_"
+	ProvidersGoBack  = "PROVIDERS_GOBACK"
+	ProvidersCancel  = "PROVIDERS_CANCEL"
+	FailingIcon      = "⚠️" // Warning sign
+)
+
+// Indexers and download clients are both providers in Sonarr and are managed
+// the same way.
+const (
+	providerKindIndexer        = "indexer"
+	providerKindDownloadClient = "download client"
+)
+
+// provider is an indexer or a download client.
+type provider struct {
+	id             int64
+	name           string
+	implementation string
+	protocol       string
+	priority       int64
+	flags          string // MarkdownV2, e.g. the indexer's RSS and search flags
+	switches       []providerSwitch
+	status         *sonarrapi.ProviderStatus
+	testResult     *sonarrapi.ProviderTestResult
+}
+
+// providerSwitch is a flag of a provider that can be toggled on its own, e.g.
+// an indexer's RSS, so the other flags are kept as configured.
+type providerSwitch struct {
+	label   string
+	field   string // JSON field of the provider in Sonarr
+	enabled bool
+}
+
+type userProviders struct {
+	kind      string
+	providers []*provider
+	provider  *provider
+	chatID    int64
+	messageID int
+}
+
+// processProvidersCommand shows the indexers or download clients, depending on
+// kind.
+func (b *Bot) processProvidersCommand(chatID int64, kind string) {
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Handling %vs command... please wait", kind))
+	message, _ := b.sendMessage(msg)
+
+	command := userProviders{
+		kind:      kind,
+		chatID:    message.Chat.ID,
+		messageID: message.MessageID,
+	}
+	if !b.loadProviders(&command) {
+		return
+	}
+	b.showProviders(&command)
+}
+
+func (b *Bot) providers(update tgbotapi.Update) bool {
+	chatID, err := b.getChatID(update)
+	if err != nil {
+		slog.Warn("Cannot manage indexers or download clients", "update_id", update.UpdateID, "error", err)
+		return false
+	}
+
+	command, exists := b.getProviderState(chatID)
+	if !exists {
+		return false
+	}
+	switch update.CallbackQuery.Data {
+	case ProvidersTest:
+		return b.handleProviderTest(command)
+	case ProvidersTestAll:
+		return b.handleProvidersTestAll(command)
+	case ProvidersGoBack:
+		command.provider = nil
+		return b.showProviders(command)
+	case ProvidersCancel:
+		b.clearState(update)
+		b.sendMessageWithEdit(command, CommandsCleared)
+		return false
+	default:
+		if strings.HasPrefix(update.CallbackQuery.Data, ProvidersSelect) {
+			return b.handleProviderSelection(update, command)
+		}
+		if strings.HasPrefix(update.CallbackQuery.Data, ProvidersToggle) {
+			return b.handleProviderToggle(update, command)
+		}
+		return false
+	}
+}
+
+// loadProviders fetches the providers of the command's kind with their status.
+// Test results of providers already loaded are kept.
+func (b *Bot) loadProviders(command *userProviders) bool {
+	s := b.getSonarrServer()
+	var providers []*provider
+	var statuses []*sonarrapi.ProviderStatus
+	var err error
+
+	switch command.kind {
+	case providerKindIndexer:
+		indexers, indexersErr := s.GetIndexers()
+		if err = indexersErr; err != nil {
+			break
+		}
+		for _, indexer := range indexers {
+			// Only offer what the indexer supports
+			var switches []providerSwitch
+			if indexer.SupportsRss {
+				switches = append(switches, providerSwitch{label: "RSS", field: "enableRss", enabled: indexer.EnableRss})
+			}
+			if indexer.SupportsSearch {
+				switches = append(switches,
+					providerSwitch{label: "Automatic search", field: "enableAutomaticSearch", enabled: indexer.EnableAutomaticSearch},
+					providerSwitch{label: "Interactive search", field: "enableInteractiveSearch", enabled: indexer.EnableInteractiveSearch})
+			}
+			providers = append(providers, &provider{
+				id:             indexer.ID,
+				name:           indexer.Name,
+				implementation: indexer.ImplementationName,
+				protocol:       indexer.Protocol,
+				priority:       indexer.Priority,
+				flags: fmt.Sprintf("RSS: %v \\| Automatic search: %v \\| Interactive search: %v",
+					enabledIcon(indexer.EnableRss), enabledIcon(indexer.EnableAutomaticSearch), enabledIcon(indexer.EnableInteractiveSearch)),
+				switches: switches,
+			})
+		}
+		statuses, err = s.GetIndexerStatus()
+	case providerKindDownloadClient:
+		clients, clientsErr := s.GetDownloadClients()
+		if err = clientsErr; err != nil {
+			break
+		}
+		for _, client := range clients {
+			providers = append(providers, &provider{
+				id:             client.ID,
+				name:           client.Name,
+				implementation: client.ImplementationName,
+				protocol:       client.Protocol,
+				priority:       int64(client.Priority),
+				flags:          fmt.Sprintf("Enabled: %v", enabledIcon(client.Enable)),
+				switches:       []providerSwitch{{field: "enable", enabled: client.Enable}},
+			})
+		}
+		statuses, err = s.GetDownloadClientStatus()
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+
+	for _, p := range providers {
+		for _, status := range statuses {
+			if status.ProviderID == p.id {
+				p.status = status
+			}
+		}
+		for _, previous := range command.providers {
+			if previous.id == p.id {
+				p.testResult = previous.testResult
+			}
+		}
+		if command.provider != nil && command.provider.id == p.id {
+			command.provider = p
+		}
+	}
+	sort.SliceStable(providers, func(i, j int) bool {
+		if providers[i].priority != providers[j].priority {
+			return providers[i].priority < providers[j].priority
+		}
+		return strings.ToLower(providers[i].name) < strings.ToLower(providers[j].name)
+	})
+	command.providers = providers
+	return true
+}
+
+func (b *Bot) showProviders(command *userProviders) bool {
+	var text strings.Builder
+	var buttonLabels []string
+	var buttonData []string
+
+	title := "Indexers"
+	if command.kind == providerKindDownloadClient {
+		title = "Download Clients"
+	}
+	fmt.Fprintf(&text, "*%v*\n\n", title)
+	if len(command.providers) == 0 {
+		fmt.Fprintf(&text, "No %vs configured\n", command.kind)
+	}
+	for _, p := range command.providers {
+		text.WriteString(providerText(p))
+		text.WriteString("\n")
+		label := p.name
+		if providerFailing(p) {
+			label = FailingIcon + " " + label
+		}
+		buttonLabels = append(buttonLabels, label)
+		buttonData = append(buttonData, ProvidersSelect+strconv.FormatInt(p.id, 10))
+	}
+	if len(command.providers) > 0 {
+		buttonLabels = append(buttonLabels, "\U0001F9EA Test all")
+		buttonData = append(buttonData, ProvidersTestAll)
+	}
+	buttonLabels = append(buttonLabels, "Cancel - clear command")
+	buttonData = append(buttonData, ProvidersCancel)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		text.String(),
+		b.createKeyboard(buttonLabels, buttonData),
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setProviderState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+// providerText describes an indexer or download client in MarkdownV2.
+func providerText(p *provider) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "*%v* \\(%v, %v\\)\n", utils.Escape(p.name), utils.Escape(p.implementation), utils.Escape(p.protocol))
+	fmt.Fprintf(&text, "%v\n", p.flags)
+	fmt.Fprintf(&text, "Priority: %d\n", p.priority)
+	fmt.Fprintf(&text, "Status: %v\n", utils.Escape(providerStatusText(p.status)))
+	if p.testResult != nil {
+		fmt.Fprintf(&text, "Test: %v\n", utils.Escape(providerTestText(p.testResult)))
+	}
+	return text.String()
+}
+
+func providerStatusText(status *sonarrapi.ProviderStatus) string {
+	const layout = "2006-01-02 15:04"
+	switch {
+	case status == nil || status.EscalationLevel == 0 && !status.DisabledTill.After(time.Now()):
+		return "OK"
+	case status.DisabledTill.After(time.Now()):
+		return fmt.Sprintf("%v temporarily disabled until %v after failures since %v",
+			FailingIcon, status.DisabledTill.Local().Format(layout), status.InitialFailure.Local().Format(layout))
+	default:
+		return fmt.Sprintf("%v failing since %v", FailingIcon, status.InitialFailure.Local().Format(layout))
+	}
+}
+
+func providerTestText(result *sonarrapi.ProviderTestResult) string {
+	if result.IsValid {
+		return MonitorIcon + " passed"
+	}
+	var failures []string
+	for _, failure := range result.ValidationFailures {
+		failures = append(failures, failure.ErrorMessage)
+	}
+	if len(failures) == 0 {
+		return UnmonitorIcon + " failed"
+	}
+	return UnmonitorIcon + " " + strings.Join(failures, "; ")
+}
+
+func providerFailing(p *provider) bool {
+	return providerStatusText(p.status) != "OK" || p.testResult != nil && !p.testResult.IsValid
+}
+
+func enabledIcon(enabled bool) string {
+	if enabled {
+		return MonitorIcon
+	}
+	return UnmonitorIcon
+}
+
+func (b *Bot) handleProviderSelection(update tgbotapi.Update, command *userProviders) bool {
+	providerID, err := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, ProvidersSelect), 10, 64)
+	if err != nil {
+		b.logger(command.chatID).Error("Cannot convert provider ID to int", "error", err)
+		return false
+	}
+	command.provider = nil
+	for _, p := range command.providers {
+		if p.id == providerID {
+			command.provider = p
+		}
+	}
+	if command.provider == nil {
+		return b.showProviders(command)
+	}
+	return b.showProvider(command)
+}
+
+func (b *Bot) showProvider(command *userProviders) bool {
+	buttonLabels := []string{"\U0001F9EA Test"}
+	buttonData := []string{ProvidersTest}
+	for i, providerSwitch := range command.provider.switches {
+		label := "Enable"
+		if providerSwitch.enabled {
+			label = "Disable"
+		}
+		if providerSwitch.label != "" {
+			label += " " + providerSwitch.label
+		}
+		buttonLabels = append(buttonLabels, label)
+		buttonData = append(buttonData, ProvidersToggle+strconv.Itoa(i))
+	}
+	buttonLabels = append(buttonLabels, "\U0001F519")
+	buttonData = append(buttonData, ProvidersGoBack)
+	keyboard := b.createKeyboard(buttonLabels, buttonData)
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		providerText(command.provider),
+		keyboard,
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setProviderState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+func (b *Bot) handleProviderTest(command *userProviders) bool {
+	if command.provider == nil {
+		return b.showProviders(command)
+	}
+	s := b.getSonarrServer()
+	var result *sonarrapi.ProviderTestResult
+	var err error
+	if command.kind == providerKindIndexer {
+		result, err = s.TestSavedIndexer(command.provider.id)
+	} else {
+		result, err = s.TestSavedDownloadClient(command.provider.id)
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	command.provider.testResult = result
+	// A test updates the provider's status
+	if !b.loadProviders(command) {
+		return false
+	}
+	return b.showProvider(command)
+}
+
+func (b *Bot) handleProvidersTestAll(command *userProviders) bool {
+	s := b.getSonarrServer()
+	var results []*sonarrapi.ProviderTestResult
+	var err error
+	if command.kind == providerKindIndexer {
+		results, err = s.TestAllIndexers()
+	} else {
+		results, err = s.TestAllDownloadClients()
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	// Disabled providers are not tested
+	for _, p := range command.providers {
+		p.testResult = nil
+		for _, result := range results {
+			if result.ID == p.id {
+				p.testResult = result
+			}
+		}
+	}
+	if !b.loadProviders(command) {
+		return false
+	}
+	return b.showProviders(command)
+}
+
+// handleProviderToggle switches a single flag of the provider.
+func (b *Bot) handleProviderToggle(update tgbotapi.Update, command *userProviders) bool {
+	p := command.provider
+	index, err := strconv.Atoi(strings.TrimPrefix(update.CallbackQuery.Data, ProvidersToggle))
+	if p == nil || err != nil || index < 0 || index >= len(p.switches) {
+		return b.showProviders(command)
+	}
+	providerSwitch := p.switches[index]
+	enable := !providerSwitch.enabled
+	fields := map[string]any{providerSwitch.field: enable}
+	s := b.getSonarrServer()
+	if command.kind == providerKindIndexer {
+		_, err = s.PatchIndexer(p.id, fields)
+	} else {
+		_, err = s.PatchDownloadClient(p.id, fields)
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	action := "disabled " + command.kind
+	if enable {
+		action = "enabled " + command.kind
+	}
+	b.audit(command.chatID, action, p.name, "provider_id", p.id, "field", providerSwitch.field)
+
+	if !b.loadProviders(command) {
+		return false
+	}
+	return b.showProvider(command)
+}
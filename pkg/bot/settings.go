@@ -0,0 +1,483 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
+)
+
+const (
+	SettingsProfile          = "SETTINGS_PROFILE"
+	SettingsProfileSelect    = "SETTINGS_PROFILE_SELECT_"
+	SettingsRootFolder       = "SETTINGS_ROOTFOLDER"
+	SettingsRootFolderSelect = "SETTINGS_ROOTFOLDER_SELECT_"
+	SettingsTags             = "SETTINGS_TAGS"
+	SettingsTag              = "SETTINGS_TAG_"
+	SettingsMonitor          = "SETTINGS_MONITOR"
+	SettingsMonitorSelect    = "SETTINGS_MONITOR_SELECT_"
+	SettingsAddOption        = "SETTINGS_ADDOPTION"
+	SettingsAddOptionSelect  = "SETTINGS_ADDOPTION_SELECT_"
+	SettingsSeasonFolder     = "SETTINGS_SEASONFOLDER"
+	SettingsReset            = "SETTINGS_RESET"
+	SettingsGoBack           = "SETTINGS_GOBACK"
+	SettingsDone             = "SETTINGS_DONE"
+	SelectedIcon             = "✅"
+)
+
+// UserSettings are a user's defaults for the add series wizard, changed with
+// /settings. Steps with a default are skipped, empty values mean "ask every
+// time".
+type UserSettings struct {
+	QualityProfileID int64  `json:"qualityProfileId,omitempty"`
+	RootFolder       string `json:"rootFolder,omitempty"`
+	// Tags are selected in the tags step, which is still shown
+	Tags    []int  `json:"tags,omitempty"`
+	Monitor string `json:"monitor,omitempty"`
+	// AddOption is "none", "missing", "cutoff" or "missing-cutoff"
+	AddOption string `json:"addOption,omitempty"`
+	// SeasonFolder defaults to true like in Sonarr
+	SeasonFolder *bool `json:"seasonFolder,omitempty"`
+}
+
+// addOptions are the choices of the last add series step.
+var addOptions = []struct {
+	Text  string
+	Value string
+}{
+	{Text: "Add", Value: "none"},
+	{Text: "Add + search missing", Value: "missing"},
+	{Text: "Add + search missing & cutoff unmet", Value: "missing-cutoff"},
+	{Text: "Add + search cutoff unmet", Value: "cutoff"},
+}
+
+type userSettingsMenu struct {
+	settings    UserSettings
+	profiles    []*sonarr.QualityProfile
+	rootFolders []*sonarr.RootFolder
+	tags        []*starr.Tag
+	chatID      int64
+	messageID   int
+}
+
+// LoadSettings reads the settings of all users from path. Changes are saved
+// there, a missing file is created on the first change. Without a settings
+// file /settings is disabled.
+func (b *Bot) LoadSettings(path string) error {
+	settings := make(map[int64]UserSettings)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+	}
+	b.muUserSettings.Lock()
+	defer b.muUserSettings.Unlock()
+	b.userSettings = settings
+	b.settingsFile = path
+	return nil
+}
+
+// settingsUserID returns the ID settings are stored under: the user sending
+// the current update or, outside of update handling, the chat.
+func (b *Bot) settingsUserID(chatID int64) int64 {
+	if user := b.updateUser(chatID); user != nil {
+		return user.ID
+	}
+	return chatID
+}
+
+func (b *Bot) getUserSettings(chatID int64) UserSettings {
+	b.muUserSettings.Lock()
+	defer b.muUserSettings.Unlock()
+	return b.userSettings[b.settingsUserID(chatID)]
+}
+
+// settingsPersisted reports whether a settings file is configured.
+func (b *Bot) settingsPersisted() bool {
+	b.muUserSettings.Lock()
+	defer b.muUserSettings.Unlock()
+	return b.settingsFile != ""
+}
+
+// saveUserSettings stores the settings and writes the settings file.
+func (b *Bot) saveUserSettings(chatID int64, settings UserSettings) error {
+	userID := b.settingsUserID(chatID)
+	b.muUserSettings.Lock()
+	defer b.muUserSettings.Unlock()
+	if b.settingsFile == "" {
+		return errors.New("settings are not saved, SBOT_SETTINGS_FILE is not set")
+	}
+	b.userSettings[userID] = settings
+	data, err := json.MarshalIndent(b.userSettings, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a crash cannot leave a
+	// truncated settings file behind
+	tmp := b.settingsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.settingsFile)
+}
+
+// applyUserSettings selects the user's defaults in the add series wizard.
+// Defaults that no longer exist on the Sonarr server are asked for.
+func (b *Bot) applyUserSettings(command *userAddSeries) {
+	settings := b.getUserSettings(command.chatID)
+	command.defaults = UserSettings{SeasonFolder: settings.SeasonFolder}
+	command.seasonFolder = settings.SeasonFolder == nil || *settings.SeasonFolder
+
+	if profile := getQualityProfileByID(command.allProfiles, settings.QualityProfileID); profile != nil {
+		command.defaults.QualityProfileID = profile.ID
+		command.profileID = profile.ID
+	}
+	for _, folder := range command.allRootFolders {
+		if settings.RootFolder != "" && folder.Path == settings.RootFolder {
+			command.defaults.RootFolder = folder.Path
+			command.rootFolder = folder
+		}
+	}
+	command.selectedTags = nil
+	for _, tag := range command.allTags {
+		if isSelectedTag(settings.Tags, tag.ID) {
+			command.defaults.Tags = append(command.defaults.Tags, tag.ID)
+			command.selectedTags = append(command.selectedTags, tag.ID)
+		}
+	}
+	if monitorTypeText(settings.Monitor) != "" {
+		command.defaults.Monitor = settings.Monitor
+	}
+	if addOptionText(settings.AddOption) != "" {
+		command.defaults.AddOption = settings.AddOption
+	}
+}
+
+func (b *Bot) processSettingsCommand(chatID int64) {
+	if !b.settingsPersisted() {
+		msg := tgbotapi.NewMessage(chatID, "Settings cannot be saved, SBOT_SETTINGS_FILE is not set")
+		b.sendMessage(msg)
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, "Handling settings command... please wait")
+	message, _ := b.sendMessage(msg)
+
+	command := userSettingsMenu{
+		settings:  b.getUserSettings(chatID),
+		chatID:    message.Chat.ID,
+		messageID: message.MessageID,
+	}
+	s := b.getSonarrServer()
+	profiles, err := s.GetQualityProfiles()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	rootFolders, err := s.GetRootFolders()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	tags, err := s.GetTags()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, err.Error())
+		b.logger(chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return
+	}
+	command.profiles = profiles
+	command.rootFolders = rootFolders
+	command.tags = tags
+	b.showSettings(&command)
+}
+
+func (b *Bot) settings(update tgbotapi.Update) bool {
+	chatID, err := b.getChatID(update)
+	if err != nil {
+		slog.Warn("Cannot change settings", "update_id", update.UpdateID, "error", err)
+		return false
+	}
+
+	command, exists := b.getSettingsState(chatID)
+	if !exists {
+		return false
+	}
+	data := update.CallbackQuery.Data
+	switch data {
+	case SettingsProfile:
+		return b.showSettingsProfiles(command)
+	case SettingsRootFolder:
+		return b.showSettingsRootFolders(command)
+	case SettingsTags:
+		return b.showSettingsTags(command)
+	case SettingsMonitor:
+		return b.showSettingsMonitor(command)
+	case SettingsAddOption:
+		return b.showSettingsAddOptions(command)
+	case SettingsSeasonFolder:
+		seasonFolder := command.settings.SeasonFolder != nil && !*command.settings.SeasonFolder
+		command.settings.SeasonFolder = &seasonFolder
+		return b.saveSettings(command)
+	case SettingsReset:
+		command.settings = UserSettings{}
+		return b.saveSettings(command)
+	case SettingsGoBack:
+		return b.showSettings(command)
+	case SettingsDone:
+		b.clearState(update)
+		b.sendMessageWithEdit(command, "Settings saved")
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(data, SettingsProfileSelect):
+		profileID, err := strconv.ParseInt(strings.TrimPrefix(data, SettingsProfileSelect), 10, 64)
+		if err != nil {
+			b.logger(chatID).Error("Cannot convert profile ID to int", "error", err)
+			return false
+		}
+		command.settings.QualityProfileID = profileID
+	case strings.HasPrefix(data, SettingsRootFolderSelect):
+		folderID, err := strconv.ParseInt(strings.TrimPrefix(data, SettingsRootFolderSelect), 10, 64)
+		if err != nil {
+			b.logger(chatID).Error("Cannot convert root folder ID to int", "error", err)
+			return false
+		}
+		command.settings.RootFolder = ""
+		for _, folder := range command.rootFolders {
+			if folder.ID == folderID {
+				command.settings.RootFolder = folder.Path
+			}
+		}
+	case strings.HasPrefix(data, SettingsTag):
+		tagID, err := strconv.Atoi(strings.TrimPrefix(data, SettingsTag))
+		if err != nil {
+			b.logger(chatID).Error("Cannot convert tag string to int", "error", err)
+			return false
+		}
+		if isSelectedTag(command.settings.Tags, tagID) {
+			command.settings.Tags = removeTag(command.settings.Tags, tagID)
+		} else {
+			command.settings.Tags = append(command.settings.Tags, tagID)
+		}
+		if !b.saveSettingsOrReport(command) {
+			return false
+		}
+		return b.showSettingsTags(command)
+	case strings.HasPrefix(data, SettingsMonitorSelect):
+		command.settings.Monitor = strings.TrimPrefix(data, SettingsMonitorSelect)
+	case strings.HasPrefix(data, SettingsAddOptionSelect):
+		command.settings.AddOption = strings.TrimPrefix(data, SettingsAddOptionSelect)
+	default:
+		return false
+	}
+	return b.saveSettings(command)
+}
+
+// saveSettings saves the changed settings and shows them.
+func (b *Bot) saveSettings(command *userSettingsMenu) bool {
+	if !b.saveSettingsOrReport(command) {
+		return false
+	}
+	return b.showSettings(command)
+}
+
+func (b *Bot) saveSettingsOrReport(command *userSettingsMenu) bool {
+	if err := b.saveUserSettings(command.chatID, command.settings); err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("Cannot save settings: %v", err))
+		b.logger(command.chatID).Error("Cannot save settings", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	return true
+}
+
+func (b *Bot) showSettings(command *userSettingsMenu) bool {
+	settings := command.settings
+	var text strings.Builder
+	fmt.Fprintf(&text, "*Defaults for adding series*\n\n")
+
+	profile := "ask"
+	if p := getQualityProfileByID(command.profiles, settings.QualityProfileID); p != nil {
+		profile = p.Name
+	}
+	rootFolder := "ask"
+	if settings.RootFolder != "" {
+		rootFolder = settings.RootFolder
+	}
+	var tagLabels []string
+	for _, tag := range command.tags {
+		if isSelectedTag(settings.Tags, tag.ID) {
+			tagLabels = append(tagLabels, tag.Label)
+		}
+	}
+	tags := "none"
+	if len(tagLabels) > 0 {
+		tags = strings.Join(tagLabels, ", ")
+	}
+	monitor := "ask"
+	if text := monitorTypeText(settings.Monitor); text != "" {
+		monitor = text
+	}
+	addOption := "ask"
+	if text := addOptionText(settings.AddOption); text != "" {
+		addOption = text
+	}
+	seasonFolder := settings.SeasonFolder == nil || *settings.SeasonFolder
+	seasonFolderIcon := MonitorIcon
+	if !seasonFolder {
+		seasonFolderIcon = UnmonitorIcon
+	}
+
+	fmt.Fprintf(&text, "Quality profile: %v\n", utils.Escape(profile))
+	fmt.Fprintf(&text, "Root folder: %v\n", utils.Escape(rootFolder))
+	fmt.Fprintf(&text, "Tags: %v\n", utils.Escape(tags))
+	fmt.Fprintf(&text, "Monitor: %v\n", utils.Escape(monitor))
+	fmt.Fprintf(&text, "Add option: %v\n", utils.Escape(addOption))
+	fmt.Fprintf(&text, "Season folders: %v\n", seasonFolderIcon)
+	fmt.Fprintf(&text, "\n_Steps with a default are skipped when adding a series\\._")
+
+	keyboard := b.createKeyboard(
+		[]string{"Quality profile", "Root folder", "Tags", "Monitor", "Add option", "Toggle season folders", "Reset all", "Done"},
+		[]string{SettingsProfile, SettingsRootFolder, SettingsTags, SettingsMonitor, SettingsAddOption, SettingsSeasonFolder, SettingsReset, SettingsDone},
+	)
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		text.String(),
+		keyboard,
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setSettingsState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+// showSettingsChoices shows choices with "Ask every time" first, the current
+// value marked.
+func (b *Bot) showSettingsChoices(command *userSettingsMenu, prompt string, labels []string, data []string, selected int) bool {
+	labels = append([]string{"Ask every time"}, labels...)
+	selected++
+	for i := range labels {
+		if i == selected {
+			labels[i] += " " + SelectedIcon
+		}
+	}
+	keyboard := b.createKeyboard(append(labels, "\U0001F519"), append(data, SettingsGoBack))
+	b.setSettingsState(command.chatID, command)
+	b.sendMessageWithEditAndKeyboard(command, keyboard, prompt)
+	return false
+}
+
+func (b *Bot) showSettingsProfiles(command *userSettingsMenu) bool {
+	labels := []string{}
+	data := []string{SettingsProfileSelect + "0"}
+	selected := -1
+	for i, profile := range command.profiles {
+		labels = append(labels, profile.Name)
+		data = append(data, SettingsProfileSelect+strconv.FormatInt(profile.ID, 10))
+		if profile.ID == command.settings.QualityProfileID {
+			selected = i
+		}
+	}
+	return b.showSettingsChoices(command, "Select the default quality profile:", labels, data, selected)
+}
+
+func (b *Bot) showSettingsRootFolders(command *userSettingsMenu) bool {
+	labels := []string{}
+	data := []string{SettingsRootFolderSelect + "0"}
+	selected := -1
+	for i, folder := range command.rootFolders {
+		labels = append(labels, folder.Path)
+		data = append(data, SettingsRootFolderSelect+strconv.FormatInt(folder.ID, 10))
+		if folder.Path == command.settings.RootFolder {
+			selected = i
+		}
+	}
+	return b.showSettingsChoices(command, "Select the default root folder:", labels, data, selected)
+}
+
+func (b *Bot) showSettingsMonitor(command *userSettingsMenu) bool {
+	labels := []string{}
+	data := []string{SettingsMonitorSelect}
+	selected := -1
+	for i, monitor := range monitorTypes {
+		labels = append(labels, monitor.Text)
+		data = append(data, SettingsMonitorSelect+monitor.Value)
+		if monitor.Value == command.settings.Monitor {
+			selected = i
+		}
+	}
+	return b.showSettingsChoices(command, "Select the default monitoring option:", labels, data, selected)
+}
+
+func (b *Bot) showSettingsAddOptions(command *userSettingsMenu) bool {
+	labels := []string{}
+	data := []string{SettingsAddOptionSelect}
+	selected := -1
+	for i, option := range addOptions {
+		labels = append(labels, option.Text)
+		data = append(data, SettingsAddOptionSelect+option.Value)
+		if option.Value == command.settings.AddOption {
+			selected = i
+		}
+	}
+	return b.showSettingsChoices(command, "Select how series are added by default:", labels, data, selected)
+}
+
+func (b *Bot) showSettingsTags(command *userSettingsMenu) bool {
+	var labels []string
+	var data []string
+	for _, tag := range command.tags {
+		label := tag.Label
+		if isSelectedTag(command.settings.Tags, tag.ID) {
+			label += " " + SelectedIcon
+		}
+		labels = append(labels, label)
+		data = append(data, SettingsTag+strconv.Itoa(tag.ID))
+	}
+	prompt := "Select the tags selected by default:"
+	if len(command.tags) == 0 {
+		prompt = "No tags defined, create them with /tags"
+	}
+	keyboard := b.createKeyboard(append(labels, "\U0001F519"), append(data, SettingsGoBack))
+	b.setSettingsState(command.chatID, command)
+	b.sendMessageWithEditAndKeyboard(command, keyboard, prompt)
+	return false
+}
+
+func monitorTypeText(value string) string {
+	for _, monitor := range monitorTypes {
+		if monitor.Value == value {
+			return monitor.Text
+		}
+	}
+	return ""
+}
+
+func addOptionText(value string) string {
+	for _, option := range addOptions {
+		if option.Value == value {
+			return option.Text
+		}
+	}
+	return ""
+}
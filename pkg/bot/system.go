@@ -0,0 +1,436 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/sonarrapi"
+	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
+)
+
+const (
+	SystemTasks             = "SYSTEM_TASKS"
+	SystemTaskRun           = "SYSTEM_TASK_RUN_"
+	SystemHealth            = "SYSTEM_HEALTH"
+	SystemLogs              = "SYSTEM_LOGS"
+	SystemLogsDownload      = "SYSTEM_LOGS_DOWNLOAD"
+	SystemLogsFirstPage     = "SYSTEM_LOGS_FIRST_PAGE"
+	SystemLogsPreviousPage  = "SYSTEM_LOGS_PREV_PAGE"
+	SystemLogsNextPage      = "SYSTEM_LOGS_NEXT_PAGE"
+	SystemLogsLastPage      = "SYSTEM_LOGS_LAST_PAGE"
+	SystemGoBack            = "SYSTEM_GOBACK"
+	SystemCancel            = "SYSTEM_CANCEL"
+	systemTimeLayout        = "2006-01-02 15:04"
+	systemLogMaxMessage     = 300  // characters of a log message shown in the chat
+	systemLogMaxText        = 3800 // characters of a logs page, below Telegram's limit of 4096
+	systemLogDownloadRecord = 1000 // log records in a downloaded log file
+)
+
+// systemLogLevels are the log levels Sonarr filters by, lowest first.
+var systemLogLevels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+type userSystem struct {
+	tasks     []*sonarrapi.Task
+	logLevel  string
+	logPage   *sonarrapi.LogPage
+	page      int
+	chatID    int64
+	messageID int
+}
+
+// processSystemCommand shows the system overview or, depending on the first
+// argument, the scheduled tasks, the health checks or the logs.
+func (b *Bot) processSystemCommand(update tgbotapi.Update, chatID int64) {
+	args := strings.Fields(strings.ToLower(update.Message.CommandArguments()))
+	view := ""
+	if len(args) > 0 {
+		view = args[0]
+	}
+
+	command := userSystem{chatID: chatID}
+	switch view {
+	case "", "status", "tasks", "health":
+	case "logs", "log":
+		if len(args) > 1 {
+			command.logLevel = strings.TrimSuffix(args[1], "ing") // "warning" is "warn"
+			if !isSystemLogLevel(command.logLevel) {
+				msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Unknown log level %q, use one of: %v", args[1], strings.Join(systemLogLevels, ", ")))
+				b.clearState(update)
+				b.sendMessage(msg)
+				return
+			}
+		}
+	default:
+		msg := tgbotapi.NewMessage(chatID, "Usage: /system [tasks|health|logs [level]]")
+		b.clearState(update)
+		b.sendMessage(msg)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Handling system command... please wait")
+	message, _ := b.sendMessage(msg)
+	command.chatID = message.Chat.ID
+	command.messageID = message.MessageID
+	b.setSystemState(chatID, &command)
+
+	switch view {
+	case "tasks":
+		b.showSystemTasks(&command)
+	case "health":
+		b.showSystemHealth(&command)
+	case "logs", "log":
+		b.showSystemLogs(&command)
+	default:
+		b.showSystemStatus(&command)
+	}
+}
+
+func (b *Bot) system(update tgbotapi.Update) bool {
+	chatID, err := b.getChatID(update)
+	if err != nil {
+		slog.Warn("Cannot show system information", "update_id", update.UpdateID, "error", err)
+		return false
+	}
+
+	command, exists := b.getSystemState(chatID)
+	if !exists {
+		return false
+	}
+	switch update.CallbackQuery.Data {
+	case "current_page":
+		if command.logPage != nil {
+			totalPages := (command.logPage.TotalRecords + b.getConfig().MaxItems - 1) / b.getConfig().MaxItems
+			b.askForPage(chatID, totalPages, func(page int) {
+				command.page = page
+				b.showSystemLogs(command)
+			})
+		}
+		return false
+	case SystemTasks:
+		return b.showSystemTasks(command)
+	case SystemHealth:
+		return b.showSystemHealth(command)
+	case SystemLogs:
+		command.page = 0
+		return b.showSystemLogs(command)
+	case SystemLogsFirstPage:
+		command.page = 0
+		return b.showSystemLogs(command)
+	case SystemLogsPreviousPage:
+		if command.page > 0 {
+			command.page--
+		}
+		return b.showSystemLogs(command)
+	case SystemLogsNextPage:
+		command.page = min(command.page+1, b.systemLogPages(command)-1)
+		return b.showSystemLogs(command)
+	case SystemLogsLastPage:
+		command.page = b.systemLogPages(command) - 1
+		return b.showSystemLogs(command)
+	case SystemLogsDownload:
+		return b.handleSystemLogsDownload(command)
+	case SystemGoBack:
+		return b.showSystemStatus(command)
+	case SystemCancel:
+		b.clearState(update)
+		b.sendMessageWithEdit(command, CommandsCleared)
+		return false
+	default:
+		if strings.HasPrefix(update.CallbackQuery.Data, SystemTaskRun) {
+			return b.handleSystemTaskRun(update, command)
+		}
+		return false
+	}
+}
+
+func (b *Bot) showSystemStatus(command *userSystem) bool {
+	status, err := b.getSonarrServer().GetSystemStatusDetails()
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+
+	database := status.DatabaseType
+	if database == "" {
+		database = "SQLite"
+	}
+	databaseVersion := status.DatabaseVersion
+	if databaseVersion == "" {
+		databaseVersion = status.SqliteVersion
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "*%v %v*\n", utils.Escape(status.AppName), utils.Escape(status.Version))
+	if status.InstanceName != "" && status.InstanceName != status.AppName {
+		fmt.Fprintf(&text, "Instance: %v\n", utils.Escape(status.InstanceName))
+	}
+	fmt.Fprintf(&text, "Branch: %v\n", utils.Escape(status.Branch))
+	fmt.Fprintf(&text, "OS: %v\n", utils.Escape(strings.TrimSpace(status.OsName+" "+status.OsVersion)))
+	fmt.Fprintf(&text, "Runtime: %v\n", utils.Escape(strings.TrimSpace(status.RuntimeName+" "+status.RuntimeVersion)))
+	fmt.Fprintf(&text, "Database: %v\n", utils.Escape(strings.TrimSpace(database+" "+databaseVersion)))
+	fmt.Fprintf(&text, "Authentication: %v\n", utils.Escape(status.Authentication))
+	if !status.StartTime.IsZero() {
+		fmt.Fprintf(&text, "Started: %v \\(up %v\\)\n", utils.Escape(status.StartTime.Local().Format(systemTimeLayout)), utils.Escape(formatUptime(time.Since(status.StartTime))))
+	}
+
+	keyboard := b.createKeyboard(
+		[]string{"\U0001F4CB Tasks", "\U0001FA7A Health", "\U0001F4DC Logs", "Cancel - clear command"},
+		[]string{SystemTasks, SystemHealth, SystemLogs, SystemCancel},
+	)
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(command.chatID, command.messageID, text.String(), keyboard)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setSystemState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+// formatUptime formats a duration as days, hours and minutes, e.g. "3d 4h 12m".
+func formatUptime(uptime time.Duration) string {
+	minutes := int(uptime.Minutes())
+	days, hours := minutes/(24*60), minutes/60%24
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes%60)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes%60)
+}
+
+func (b *Bot) showSystemTasks(command *userSystem) bool {
+	tasks, err := b.getSonarrServer().GetSystemTasks()
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	command.tasks = tasks
+
+	var text strings.Builder
+	var buttonLabels []string
+	var buttonData []string
+	fmt.Fprintf(&text, "*Scheduled Tasks*\n\n")
+	for _, task := range tasks {
+		fmt.Fprintf(&text, "*%v*\n", utils.Escape(task.Name))
+		fmt.Fprintf(&text, "Interval: %v\n", utils.Escape(formatUptime(time.Duration(task.Interval)*time.Minute)))
+		if !task.LastExecution.IsZero() {
+			fmt.Fprintf(&text, "Last run: %v", utils.Escape(task.LastExecution.Local().Format(systemTimeLayout)))
+			if task.LastDuration != "" {
+				fmt.Fprintf(&text, " \\(%v\\)", utils.Escape(task.LastDuration))
+			}
+			text.WriteString("\n")
+		}
+		fmt.Fprintf(&text, "Next run: %v\n\n", utils.Escape(task.NextExecution.Local().Format(systemTimeLayout)))
+		buttonLabels = append(buttonLabels, "▶️ Run "+task.Name)
+		buttonData = append(buttonData, SystemTaskRun+strconv.FormatInt(task.ID, 10))
+	}
+	buttonLabels = append(buttonLabels, "Cancel - clear command", "\U0001F519")
+	buttonData = append(buttonData, SystemCancel, SystemGoBack)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(command.chatID, command.messageID, text.String(), b.createKeyboard(buttonLabels, buttonData))
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setSystemState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+func (b *Bot) handleSystemTaskRun(update tgbotapi.Update, command *userSystem) bool {
+	taskID, err := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, SystemTaskRun), 10, 64)
+	if err != nil {
+		b.logger(command.chatID).Error("Cannot convert task ID to int", "error", err)
+		return false
+	}
+	var task *sonarrapi.Task
+	for _, t := range command.tasks {
+		if t.ID == taskID {
+			task = t
+		}
+	}
+	if task == nil {
+		return b.showSystemTasks(command)
+	}
+	_, err = b.getSonarrServer().SendCommand(&sonarr.CommandRequest{Name: task.TaskName})
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	b.logger(command.chatID).Info("Task started", "task", task.TaskName)
+	msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("Task %v started", task.Name))
+	b.sendMessage(msg)
+	return false
+}
+
+func (b *Bot) showSystemHealth(command *userSystem) bool {
+	checks, err := b.getSonarrServer().GetHealth()
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "*Health*\n\n")
+	if len(checks) == 0 {
+		fmt.Fprintf(&text, "%v No issues found\n", MonitorIcon)
+	}
+	for _, check := range checks {
+		icon := "ℹ️"
+		switch check.Type {
+		case "warning":
+			icon = FailingIcon
+		case "error":
+			icon = UnmonitorIcon
+		}
+		fmt.Fprintf(&text, "%v %v\n", icon, utils.Escape(check.Message))
+		if check.WikiURL != "" {
+			fmt.Fprintf(&text, "[More information](%v)\n", utils.Escape(check.WikiURL))
+		}
+		text.WriteString("\n")
+	}
+
+	keyboard := b.createKeyboard(
+		[]string{"Cancel - clear command", "\U0001F519"},
+		[]string{SystemCancel, SystemGoBack},
+	)
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(command.chatID, command.messageID, text.String(), keyboard)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setSystemState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+func (b *Bot) showSystemLogs(command *userSystem) bool {
+	maxItems := b.getConfig().MaxItems
+	logPage, err := b.getSonarrServer().GetLogs(command.page+1, maxItems, command.logLevel)
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	command.logPage = logPage
+	totalPages := (logPage.TotalRecords + maxItems - 1) / maxItems
+
+	var text strings.Builder
+	title := "Logs"
+	if command.logLevel != "" {
+		title = fmt.Sprintf("Logs \\(%v and above\\)", command.logLevel)
+	}
+	fmt.Fprintf(&text, "*%v*\n\n", title)
+	if len(logPage.Records) == 0 {
+		fmt.Fprintf(&text, "No log entries found\n")
+	}
+	// Records share the space of the message, long messages are shortened
+	// and records that do not fit are left out
+	remaining := systemLogMaxText - text.Len()
+	for i, record := range logPage.Records {
+		entry := systemLogEntry(record, remaining/(len(logPage.Records)-i))
+		if entry == "" {
+			fmt.Fprintf(&text, "_%d more entries not shown, download the log file_\n", len(logPage.Records)-i)
+			break
+		}
+		text.WriteString(entry)
+		remaining -= len(entry)
+	}
+
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	if totalPages > 1 {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, paginationButtons(command.page, totalPages,
+			SystemLogsFirstPage, SystemLogsPreviousPage, SystemLogsNextPage, SystemLogsLastPage))
+	}
+	keyboardGoBack := b.createKeyboard(
+		[]string{"\U0001F4BE Download log file", "Cancel - clear command", "\U0001F519"},
+		[]string{SystemLogsDownload, SystemCancel, SystemGoBack},
+	)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, keyboardGoBack.InlineKeyboard...)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(command.chatID, command.messageID, text.String(), keyboard)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setSystemState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+// systemLogPages returns the number of pages of the logs shown last, at least
+// one.
+func (b *Bot) systemLogPages(command *userSystem) int {
+	if command.logPage == nil {
+		return 1
+	}
+	maxItems := b.getConfig().MaxItems
+	return max(1, (command.logPage.TotalRecords+maxItems-1)/maxItems)
+}
+
+// systemLogEntry formats a log record in MarkdownV2 in at most budget bytes,
+// shortening its message as needed. It returns "" if the record does not fit.
+func systemLogEntry(record *sonarrapi.LogRecord, budget int) string {
+	header := fmt.Sprintf("`%v` *%v* %v\n",
+		record.Time.Local().Format("01-02 15:04:05"),
+		utils.Escape(strings.ToUpper(record.Level)),
+		utils.Escape(record.Logger),
+	)
+	message := []rune(record.Message)
+	length := min(len(message), systemLogMaxMessage)
+	for length >= 0 {
+		shown := string(message[:length])
+		if length < len(message) {
+			shown += "..."
+		}
+		entry := header + utils.Escape(shown) + "\n\n"
+		if len(entry) <= budget {
+			return entry
+		}
+		// Escaping and multi-byte characters make the entry longer than
+		// the message, shorten by at least the excess
+		length -= max(1, len(entry)-budget)
+	}
+	return ""
+}
+
+// handleSystemLogsDownload sends the most recent log records as a file.
+func (b *Bot) handleSystemLogsDownload(command *userSystem) bool {
+	logPage, err := b.getSonarrServer().GetLogs(1, systemLogDownloadRecord, command.logLevel)
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+
+	var log strings.Builder
+	for _, record := range logPage.Records {
+		fmt.Fprintf(&log, "%v|%v|%v|%v\n", record.Time.Local().Format(time.DateTime), strings.ToUpper(record.Level), record.Logger, record.Message)
+		if record.Exception != "" {
+			fmt.Fprintf(&log, "%v\n", record.Exception)
+		}
+	}
+	name := "sonarr.log"
+	if command.logLevel != "" {
+		name = fmt.Sprintf("sonarr.%v.log", command.logLevel)
+	}
+	document := tgbotapi.NewDocument(command.chatID, tgbotapi.FileBytes{Name: name, Bytes: []byte(log.String())})
+	document.Caption = fmt.Sprintf("%d most recent log entries", len(logPage.Records))
+	b.sendMessage(document)
+	return false
+}
+
+func isSystemLogLevel(level string) bool {
+	for _, l := range systemLogLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,350 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golift.io/starr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/sonarrapi"
+	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
+)
+
+const (
+	TagsSelect         = "TAGS_SELECT_"
+	TagsCreate         = "TAGS_CREATE"
+	TagsRename         = "TAGS_RENAME"
+	TagsDelete         = "TAGS_DELETE"
+	TagsDeleteYes      = "TAGS_DELETE_YES"
+	TagsGoBack         = "TAGS_GOBACK"
+	TagsCancel         = "TAGS_CANCEL"
+	TagNamePrompt      = "Send the name of the new tag (letters, digits and hyphens):"
+	TagNamePlaceholder = "e.g. kids-tv"
+)
+
+// Sonarr only accepts lowercase letters, digits and hyphens in tag labels.
+var tagLabelPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+type userTags struct {
+	tags      []*sonarrapi.TagDetails
+	tag       *sonarrapi.TagDetails
+	chatID    int64
+	messageID int
+}
+
+func (b *Bot) processTagsCommand(chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "Handling tags command... please wait")
+	message, _ := b.sendMessage(msg)
+
+	command := userTags{
+		chatID:    message.Chat.ID,
+		messageID: message.MessageID,
+	}
+	if !b.loadTags(&command) {
+		return
+	}
+	b.showTags(&command)
+}
+
+func (b *Bot) tags(update tgbotapi.Update) bool {
+	chatID, err := b.getChatID(update)
+	if err != nil {
+		slog.Warn("Cannot manage tags", "update_id", update.UpdateID, "error", err)
+		return false
+	}
+
+	command, exists := b.getTagState(chatID)
+	if !exists {
+		return false
+	}
+	switch update.CallbackQuery.Data {
+	case TagsCreate:
+		command.tag = nil
+		b.askForText(chatID, TagNamePrompt, TagNamePlaceholder, func(text string) bool {
+			return b.handleTagsTextInput(command, TagsCreate, text)
+		})
+		return false
+	case TagsRename:
+		if command.tag == nil {
+			return b.showTags(command)
+		}
+		b.askForText(chatID, fmt.Sprintf("Send the new name of tag %v (letters, digits and hyphens):", command.tag.Label), TagNamePlaceholder, func(text string) bool {
+			return b.handleTagsTextInput(command, TagsRename, text)
+		})
+		return false
+	case TagsDelete:
+		return b.showTagDelete(command)
+	case TagsDeleteYes:
+		return b.handleTagDeleteYes(command)
+	case TagsGoBack:
+		command.tag = nil
+		return b.showTags(command)
+	case TagsCancel:
+		b.clearState(update)
+		b.sendMessageWithEdit(command, CommandsCleared)
+		return false
+	default:
+		if strings.HasPrefix(update.CallbackQuery.Data, TagsSelect) {
+			return b.handleTagSelection(update, command)
+		}
+		return false
+	}
+}
+
+func (b *Bot) loadTags(command *userTags) bool {
+	tags, err := b.getSonarrServer().GetTagDetails()
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].Label < tags[j].Label
+	})
+	command.tags = tags
+	if command.tag != nil {
+		for _, tag := range tags {
+			if tag.ID == command.tag.ID {
+				command.tag = tag
+			}
+		}
+	}
+	return true
+}
+
+func (b *Bot) showTags(command *userTags) bool {
+	var text strings.Builder
+	var buttonLabels []string
+	var buttonData []string
+
+	fmt.Fprintf(&text, "*Tags*\n\n")
+	if len(command.tags) == 0 {
+		fmt.Fprintf(&text, "No tags defined\n")
+	}
+	for _, tag := range command.tags {
+		fmt.Fprintf(&text, "*%v*: %v\n", utils.Escape(tag.Label), utils.Escape(tagUsageText(tag)))
+		buttonLabels = append(buttonLabels, tag.Label)
+		buttonData = append(buttonData, TagsSelect+strconv.Itoa(tag.ID))
+	}
+	buttonLabels = append(buttonLabels, "➕ New tag", "Cancel - clear command")
+	buttonData = append(buttonData, TagsCreate, TagsCancel)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		command.chatID,
+		command.messageID,
+		text.String(),
+		b.createKeyboard(buttonLabels, buttonData),
+	)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setTagState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+// tagUsageText counts what uses the tag, e.g. "3 series, 1 indexer".
+func tagUsageText(tag *sonarrapi.TagDetails) string {
+	usages := []struct {
+		count    int
+		singular string
+		plural   string
+	}{
+		{len(tag.SeriesIDs), "series", "series"},
+		{len(tag.IndexerIDs), "indexer", "indexers"},
+		{len(tag.DownloadClientIDs), "download client", "download clients"},
+		{len(tag.RestrictionIDs), "release profile", "release profiles"},
+		{len(tag.DelayProfileIDs), "delay profile", "delay profiles"},
+		{len(tag.ImportListIDs), "import list", "import lists"},
+		{len(tag.NotificationIDs), "connection", "connections"},
+		{len(tag.AutoTagIDs), "auto tag", "auto tags"},
+	}
+	var parts []string
+	for _, usage := range usages {
+		switch {
+		case usage.count == 1:
+			parts = append(parts, "1 "+usage.singular)
+		case usage.count > 1:
+			parts = append(parts, fmt.Sprintf("%d %v", usage.count, usage.plural))
+		}
+	}
+	if len(parts) == 0 {
+		return "unused"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (b *Bot) handleTagSelection(update tgbotapi.Update, command *userTags) bool {
+	tagID, err := strconv.Atoi(strings.TrimPrefix(update.CallbackQuery.Data, TagsSelect))
+	if err != nil {
+		b.logger(command.chatID).Error("Cannot convert tag ID to int", "error", err)
+		return false
+	}
+	command.tag = nil
+	for _, tag := range command.tags {
+		if tag.ID == tagID {
+			command.tag = tag
+		}
+	}
+	if command.tag == nil {
+		return b.showTags(command)
+	}
+	return b.showTag(command)
+}
+
+func (b *Bot) showTag(command *userTags) bool {
+	text := fmt.Sprintf("*%v*\n\nUsed by: %v\n", utils.Escape(command.tag.Label), utils.Escape(tagUsageText(command.tag)))
+	keyboard := b.createKeyboard(
+		[]string{"✏️ Rename", "\U0001F5D1 Delete", "\U0001F519"},
+		[]string{TagsRename, TagsDelete, TagsGoBack},
+	)
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(command.chatID, command.messageID, text, keyboard)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setTagState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+// handleTagsTextInput creates or renames a tag with the typed label. It
+// returns false if the label is invalid or taken.
+func (b *Bot) handleTagsTextInput(command *userTags, action string, text string) bool {
+	label, ok := normalizeTagLabel(text)
+	if !ok {
+		return false
+	}
+	for _, tag := range command.tags {
+		if tag.Label == label {
+			msg := tgbotapi.NewMessage(command.chatID, fmt.Sprintf("Tag %v already exists", label))
+			b.sendMessage(msg)
+			return false
+		}
+	}
+
+	var err error
+	switch action {
+	case TagsCreate:
+		_, err = b.createTag(command.chatID, label)
+	case TagsRename:
+		oldLabel := command.tag.Label
+		if _, err = b.getSonarrServer().UpdateTag(&starr.Tag{ID: command.tag.ID, Label: label}); err == nil {
+			b.audit(command.chatID, "renamed tag", label, "tag_id", command.tag.ID, "old_label", oldLabel)
+		}
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return true
+	}
+	if !b.loadTags(command) {
+		return true
+	}
+	if command.tag != nil {
+		b.showTag(command)
+		return true
+	}
+	b.showTags(command)
+	return true
+}
+
+func (b *Bot) showTagDelete(command *userTags) bool {
+	if command.tag == nil {
+		return b.showTags(command)
+	}
+	text := fmt.Sprintf("Delete tag *%v*?\n\nUsed by: %v\n", utils.Escape(command.tag.Label), utils.Escape(tagUsageText(command.tag)))
+	keyboard := b.createKeyboard(
+		[]string{"Yes, delete tag", "\U0001F519"},
+		[]string{TagsDeleteYes, TagsGoBack},
+	)
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(command.chatID, command.messageID, text, keyboard)
+	editMsg.ParseMode = "MarkdownV2"
+	editMsg.DisableWebPagePreview = true
+	b.setTagState(command.chatID, command)
+	b.sendMessage(editMsg)
+	return false
+}
+
+func (b *Bot) handleTagDeleteYes(command *userTags) bool {
+	if command.tag == nil {
+		return b.showTags(command)
+	}
+	if err := b.getSonarrServer().DeleteTag(command.tag.ID); err != nil {
+		msg := tgbotapi.NewMessage(command.chatID, err.Error())
+		b.logger(command.chatID).Error("Sonarr request failed", "error", err)
+		b.sendMessage(msg)
+		return false
+	}
+	b.audit(command.chatID, "deleted tag", command.tag.Label, "tag_id", command.tag.ID)
+	command.tag = nil
+	if !b.loadTags(command) {
+		return false
+	}
+	return b.showTags(command)
+}
+
+// createTag adds a tag to Sonarr. If the label is taken, Sonarr returns the
+// existing tag.
+func (b *Bot) createTag(chatID int64, label string) (*starr.Tag, error) {
+	tag, err := b.getSonarrServer().AddTag(&starr.Tag{Label: label})
+	if err != nil {
+		return nil, err
+	}
+	b.audit(chatID, "created tag", tag.Label, "tag_id", tag.ID)
+	return tag, nil
+}
+
+// normalizeTagLabel turns typed text into a label Sonarr accepts, e.g.
+// "Kids TV" becomes "kids-tv".
+func normalizeTagLabel(text string) (string, bool) {
+	label := strings.Join(strings.Fields(strings.ToLower(text)), "-")
+	return label, tagLabelPattern.MatchString(label)
+}
+
+// errInvalidTagLabel is returned by addTypedTag for labels Sonarr does not
+// accept, as opposed to failed Sonarr requests.
+var errInvalidTagLabel = errors.New("tag names may only contain letters, digits and hyphens")
+
+// addTypedTag creates the typed tag, or finds it in allTags, and selects it.
+// Used by the add and edit wizards and by presets.
+func (b *Bot) addTypedTag(chatID int64, text string, allTags []*starr.Tag, selectedTags []int) ([]*starr.Tag, []int, error) {
+	label, ok := normalizeTagLabel(text)
+	if !ok {
+		return allTags, selectedTags, errInvalidTagLabel
+	}
+	var tag *starr.Tag
+	var err error
+	for _, existing := range allTags {
+		if existing.Label == label {
+			tag = existing
+		}
+	}
+	if tag == nil {
+		if tag, err = b.createTag(chatID, label); err != nil {
+			return allTags, selectedTags, err
+		}
+		allTags = append(allTags, tag)
+	}
+	if !isSelectedTag(selectedTags, tag.ID) {
+		selectedTags = append(selectedTags, tag.ID)
+	}
+	return allTags, selectedTags, nil
+}
+
+// handleTypedTagError reports a failed Sonarr request of addTypedTag. It
+// returns false if the typed label was invalid, so that it is asked again.
+func (b *Bot) handleTypedTagError(chatID int64, err error) bool {
+	if errors.Is(err, errInvalidTagLabel) {
+		return false
+	}
+	msg := tgbotapi.NewMessage(chatID, err.Error())
+	b.logger(chatID).Error("Sonarr request failed", "error", err)
+	b.sendMessage(msg)
+	return true
+}
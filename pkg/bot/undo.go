@@ -0,0 +1,156 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	UndoDelete                 = "UNDO_DELETE_"
+	undoCountdownInterval      = 5 * time.Second
+	pendingDeleteCancelledText = "Deletion cancelled as the bot was shut down, nothing was deleted."
+)
+
+// pendingDelete is a deletion waiting for its grace period to pass.
+type pendingDelete struct {
+	chatID    int64
+	messageID int
+	text      string
+	undo      chan struct{}
+	cancel    chan struct{} // closed on shutdown
+}
+
+func (p *pendingDelete) GetChatID() int64 {
+	return p.chatID
+}
+
+func (p *pendingDelete) GetMessageID() int {
+	return p.messageID
+}
+
+// scheduleDelete replaces the command's message with text, a countdown and an
+// undo button. Unless undone, deleteFunc is called with the user that
+// confirmed the deletion once the grace period has passed, and the message is
+// replaced with doneText.
+func (b *Bot) scheduleDelete(command Command, text string, doneText string, deleteFunc func(user *tgbotapi.User) error) {
+	pending := &pendingDelete{
+		chatID:    command.GetChatID(),
+		messageID: command.GetMessageID(),
+		text:      text,
+		undo:      make(chan struct{}),
+		cancel:    make(chan struct{}),
+	}
+	user := b.updateUser(pending.chatID)
+	gracePeriod := b.getConfig().DeleteGracePeriod
+
+	b.muPendingDeletes.Lock()
+	if b.pendingDeletesCancelled {
+		b.muPendingDeletes.Unlock()
+		b.sendMessageWithEdit(pending, pendingDeleteCancelledText)
+		return
+	}
+	b.lastPendingDeleteID++
+	id := b.lastPendingDeleteID
+	b.pendingDeletes[id] = pending
+	b.runningDeletes.Add(1)
+	b.muPendingDeletes.Unlock()
+
+	b.logger(pending.chatID).Info("Deletion scheduled", "grace_period", gracePeriod)
+	b.showPendingDelete(id, pending, gracePeriod)
+	go func() {
+		defer b.runningDeletes.Done()
+		b.runPendingDelete(id, pending, user, gracePeriod, doneText, deleteFunc)
+	}()
+}
+
+// CancelPendingDeletes cancels the deletions waiting for their grace period on
+// shutdown and waits for running deletions to finish. The messages say that
+// nothing was deleted, so no countdown or undo button is left behind.
+func (b *Bot) CancelPendingDeletes() {
+	b.muPendingDeletes.Lock()
+	b.pendingDeletesCancelled = true
+	for id, pending := range b.pendingDeletes {
+		delete(b.pendingDeletes, id)
+		close(pending.cancel)
+	}
+	b.muPendingDeletes.Unlock()
+	b.runningDeletes.Wait()
+}
+
+func (b *Bot) runPendingDelete(id int, pending *pendingDelete, user *tgbotapi.User, gracePeriod time.Duration, doneText string, deleteFunc func(user *tgbotapi.User) error) {
+	deadline := time.Now().Add(gracePeriod)
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+	ticker := time.NewTicker(undoCountdownInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pending.undo:
+			b.sendMessageWithEdit(pending, "Deletion undone, nothing was deleted.")
+			return
+		case <-pending.cancel:
+			b.logger(pending.chatID).Info("Deletion cancelled by shutdown")
+			b.sendMessageWithEdit(pending, pendingDeleteCancelledText)
+			return
+		case <-ticker.C:
+			b.showPendingDelete(id, pending, time.Until(deadline))
+		case <-timer.C:
+			// An undo may have been pressed just now, it is handled above
+			if b.takePendingDelete(id, pending.chatID) == nil {
+				continue
+			}
+			if err := deleteFunc(user); err != nil {
+				msg := tgbotapi.NewMessage(pending.chatID, err.Error())
+				b.logger(pending.chatID).Error("Sonarr request failed", "error", err)
+				b.sendMessage(msg)
+				return
+			}
+			b.sendMessageWithEdit(pending, doneText)
+			return
+		}
+	}
+}
+
+func (b *Bot) showPendingDelete(id int, pending *pendingDelete, remaining time.Duration) {
+	keyboard := b.createKeyboard(
+		[]string{"↩️ Undo"},
+		[]string{UndoDelete + strconv.Itoa(id)},
+	)
+	text := fmt.Sprintf("%v\n\nDeleting in %v, tap Undo to keep everything.", pending.text, remaining.Round(time.Second))
+	b.sendMessageWithEditAndKeyboard(pending, keyboard, text)
+}
+
+// takePendingDelete removes a pending deletion of the chat, so that it is
+// either executed or undone, but not both.
+func (b *Bot) takePendingDelete(id int, chatID int64) *pendingDelete {
+	b.muPendingDeletes.Lock()
+	defer b.muPendingDeletes.Unlock()
+	pending, exists := b.pendingDeletes[id]
+	if !exists || pending.chatID != chatID {
+		return nil
+	}
+	delete(b.pendingDeletes, id)
+	return pending
+}
+
+func (b *Bot) handleUndoDelete(update tgbotapi.Update) {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	id, err := strconv.Atoi(strings.TrimPrefix(update.CallbackQuery.Data, UndoDelete))
+	if err != nil {
+		b.logger(chatID).Error("Cannot convert pending deletion ID to int", "error", err)
+		return
+	}
+	pending := b.takePendingDelete(id, chatID)
+	if pending == nil {
+		msg := tgbotapi.NewMessage(chatID, "Too late, the deletion has already been executed.")
+		b.sendMessage(msg)
+		return
+	}
+	b.logger(chatID).Info("Deletion undone")
+	close(pending.undo)
+}
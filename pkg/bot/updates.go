@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Seconds, long polling timeout sent to Telegram. On shutdown the pending
+	// request is awaited, so it must stay below the shutdown timeout.
+	pollTimeout      = 20
+	minPollBackoff   = time.Second
+	maxPollBackoff   = 2 * time.Minute
+	maxChatWorkers   = 8  // chats handled concurrently
+	maxQueuedUpdates = 32 // updates waiting per chat before new ones are dropped
+)
+
+// PollUpdates fetches updates via long polling and sends them to the returned
+// channel. Telegram errors are retried with exponential backoff. The channel is
+// closed once ctx is cancelled.
+func (b *Bot) PollUpdates(ctx context.Context) <-chan tgbotapi.Update {
+	updates := make(chan tgbotapi.Update)
+
+	go func() {
+		defer close(updates)
+
+		b.deleteWebhook()
+		offset := 0
+		backoff := minPollBackoff
+		for {
+			batch, err := b.getUpdates(ctx, offset)
+			if ctx.Err() != nil {
+				b.confirmUpdates(offset)
+				return
+			}
+			if err != nil {
+				slog.Warn("Error getting updates", "retry_in", backoff, "error", telegramError(err))
+				select {
+				case <-ctx.Done():
+					b.confirmUpdates(offset)
+					return
+				case <-time.After(backoff):
+				}
+				backoff = min(backoff*2, maxPollBackoff)
+				continue
+			}
+			backoff = minPollBackoff
+			b.lastPoll.Store(time.Now())
+
+			for _, update := range batch {
+				updates <- update
+				offset = update.UpdateID + 1
+			}
+		}
+	}()
+
+	return updates
+}
+
+// getUpdates runs a single long polling request. When ctx is cancelled, it
+// waits for the pending request to return, as confirming the offset while
+// another getUpdates is running fails with a conflict.
+func (b *Bot) getUpdates(ctx context.Context, offset int) ([]tgbotapi.Update, error) {
+	type result struct {
+		updates []tgbotapi.Update
+		err     error
+	}
+	results := make(chan result, 1)
+
+	go func() {
+		updateConfig := tgbotapi.NewUpdate(offset)
+		updateConfig.Timeout = pollTimeout
+		updates, err := b.Bot.GetUpdates(updateConfig)
+		results <- result{updates, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		<-results
+		return nil, ctx.Err()
+	case r := <-results:
+		return r.updates, r.err
+	}
+}
+
+// confirmUpdates tells Telegram that all updates before offset have been
+// handled, so they are not delivered again after a restart.
+func (b *Bot) confirmUpdates(offset int) {
+	if offset == 0 {
+		return
+	}
+	updateConfig := tgbotapi.NewUpdate(offset)
+	updateConfig.Limit = 1
+	if _, err := b.Bot.GetUpdates(updateConfig); err != nil {
+		slog.Error("Error confirming updates", "error", telegramError(err))
+	}
+}
+
+type chatQueue struct {
+	updates chan tgbotapi.Update
+	pending int
+}
+
+// HandleUpdates processes updates until the channel is closed and all queued
+// updates have been handled. Updates of one chat are handled in order, while
+// up to maxChatWorkers chats are handled concurrently.
+func (b *Bot) HandleUpdates(updates <-chan tgbotapi.Update) {
+	queues := make(map[int64]*chatQueue)
+	finished := make(chan int64)
+	workers := make(chan struct{}, maxChatWorkers)
+
+	for updates != nil || len(queues) > 0 {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				updates = nil
+				continue
+			}
+			chatID, err := b.getChatID(update)
+			if err != nil {
+				slog.Warn("Cannot handle update", "update_id", update.UpdateID, "error", err)
+				continue
+			}
+			queue, exists := queues[chatID]
+			if !exists {
+				queue = &chatQueue{updates: make(chan tgbotapi.Update, maxQueuedUpdates)}
+				queues[chatID] = queue
+				go b.chatWorker(chatID, queue.updates, workers, finished)
+			}
+			select {
+			case queue.updates <- update:
+				queue.pending++
+			default:
+				slog.Warn("Too many pending updates, dropping update", "chat_id", chatID, "update_id", update.UpdateID)
+			}
+		case chatID := <-finished:
+			queue := queues[chatID]
+			queue.pending--
+			// Stop idle workers, a new one is started with the next update
+			if queue.pending == 0 {
+				close(queue.updates)
+				delete(queues, chatID)
+			}
+		}
+	}
+}
+
+func (b *Bot) chatWorker(chatID int64, updates <-chan tgbotapi.Update, workers chan struct{}, finished chan<- int64) {
+	for update := range updates {
+		workers <- struct{}{}
+		b.handleUpdateRecovered(chatID, update)
+		<-workers
+		finished <- chatID
+	}
+}
+
+// handleUpdateRecovered handles an update and recovers from panics, so that
+// a bug in one conversation does not stop the bot. The chat's conversation is
+// cleared as its state may be inconsistent.
+func (b *Bot) handleUpdateRecovered(chatID int64, update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.logger(chatID).Error("Panic while handling update", "update_id", update.UpdateID, "panic", r, "stack", string(debug.Stack()))
+			b.clearChatState(chatID)
+		}
+	}()
+	b.HandleUpdate(update)
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/woiza/telegram-bot-sonarr/pkg/mediaserver"
+	"github.com/woiza/telegram-bot-sonarr/pkg/utils"
+	"golift.io/starr/sonarr"
+)
+
+const (
+	LibrarySeriesWatchedSeasons = "LIBRARY_SERIES_WATCHED_SEASONS"
+	CleanupIcon                 = "\U0001F9F9" // Broom
+)
+
+// loadWatched returns the watch history of the media server, or nil if none
+// is configured or it cannot be read. The library works without it.
+func (b *Bot) loadWatched(chatID int64) mediaserver.Watched {
+	if b.MediaServer == nil {
+		return nil
+	}
+	watched, err := b.MediaServer.Watched()
+	if err != nil {
+		b.logger(chatID).Warn("Media server request failed", "media_server", b.MediaServer.Name(), "error", err)
+		return nil
+	}
+	return watched
+}
+
+// watchedCounts returns the number of watched episodes of a series and of
+// the episodes on disk that are not watched yet.
+func watchedCounts(watched mediaserver.Watched, series *sonarr.Series, episodes []*sonarr.Episode) (int, int) {
+	var watchedCount, unwatchedCount int
+	for _, episode := range episodes {
+		switch {
+		case watched[series.TvdbID][mediaserver.Episode{Season: episode.SeasonNumber, Number: episode.EpisodeNumber}]:
+			watchedCount++
+		case episode.HasFile:
+			unwatchedCount++
+		}
+	}
+	return watchedCount, unwatchedCount
+}
+
+// seasonFullyWatched reports whether all episodes of a season are watched.
+func seasonFullyWatched(watched mediaserver.Watched, series *sonarr.Series, season *sonarr.Season) bool {
+	return season.Statistics != nil && season.Statistics.TotalEpisodeCount > 0 &&
+		watched.SeasonCount(series.TvdbID, season.SeasonNumber) >= season.Statistics.TotalEpisodeCount
+}
+
+// seriesFullyWatched reports whether all seasons of a series, not counting
+// specials, are watched.
+func seriesFullyWatched(watched mediaserver.Watched, series *sonarr.Series) bool {
+	var seasons int
+	for _, season := range series.Seasons {
+		if season.SeasonNumber == 0 || season.Statistics == nil || season.Statistics.TotalEpisodeCount == 0 {
+			continue
+		}
+		if !seasonFullyWatched(watched, series, season) {
+			return false
+		}
+		seasons++
+	}
+	return seasons > 0
+}
+
+// watchedSeasonsOnDisk returns the fully watched seasons that still have
+// files, they can be deleted to free space.
+func watchedSeasonsOnDisk(watched mediaserver.Watched, series *sonarr.Series) []*sonarr.Season {
+	var seasons []*sonarr.Season
+	for _, season := range series.Seasons {
+		if season.Statistics != nil && season.Statistics.EpisodeFileCount > 0 && seasonFullyWatched(watched, series, season) {
+			seasons = append(seasons, season)
+		}
+	}
+	return seasons
+}
+
+// writeWatchStatus adds the watch status to the MarkdownV2 series card.
+func writeWatchStatus(message *strings.Builder, command *userLibrary) {
+	watchedCount, unwatchedCount := watchedCounts(command.watched, command.series, command.allEpisodes)
+	fmt.Fprintf(message, "Watched: %d episodes, %d unwatched on disk\n", watchedCount, unwatchedCount)
+	if seasons := watchedSeasonsOnDisk(command.watched, command.series); len(seasons) > 0 {
+		numbers := make([]string, len(seasons))
+		for i, season := range seasons {
+			numbers[i] = strconv.Itoa(season.SeasonNumber)
+		}
+		fmt.Fprintf(message, "Watched seasons on disk: %s\n", utils.Escape(strings.Join(numbers, ", ")))
+	}
+}
+
+// showLibraryWatchedSeasons proposes to delete the files of fully watched
+// seasons. Each season opens its season card with "Delete Season & Unmonitor".
+func (b *Bot) showLibraryWatchedSeasons(command *userLibrary) bool {
+	var buttonLabels []string
+	var buttonData []string
+	var size int64
+	for _, season := range watchedSeasonsOnDisk(command.watched, command.series) {
+		size += season.Statistics.SizeOnDisk
+		buttonLabels = append(buttonLabels, fmt.Sprintf("%v Season %d - %v", CleanupIcon, season.SeasonNumber, utils.ByteCountSI(season.Statistics.SizeOnDisk)))
+		buttonData = append(buttonData, fmt.Sprintf("SEASON_%d", season.SeasonNumber))
+	}
+	buttonLabels = append(buttonLabels, "\U0001F519")
+	buttonData = append(buttonData, LibrarySeasonEditGoBack)
+
+	text := fmt.Sprintf("Fully watched seasons of %v on %v: %d, using %v. Choose a season to delete its files:",
+		command.series.Title, b.MediaServer.Name(), len(buttonData)-1, utils.ByteCountSI(size))
+	b.setLibraryState(command.chatID, command)
+	b.setActiveCommand(command.chatID, LibrarySeasonsEditCommand)
+	b.sendMessageWithEditAndKeyboard(command, b.createKeyboard(buttonLabels, buttonData), text)
+	return false
+}
+
+// watchedSeasonsButton returns the keyboard row proposing the cleanup of
+// watched seasons, or nil if there are none.
+func watchedSeasonsButton(command *userLibrary) []tgbotapi.InlineKeyboardButton {
+	if command.watched == nil {
+		return nil
+	}
+	seasons := watchedSeasonsOnDisk(command.watched, command.series)
+	if len(seasons) == 0 {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+		fmt.Sprintf("%v Clean up watched seasons (%d)", CleanupIcon, len(seasons)), LibrarySeriesWatchedSeasons))
+}
@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	webhookSecretHeader   = "X-Telegram-Bot-Api-Secret-Token"
+	webhookShutdownPeriod = 10 * time.Second
+)
+
+// ListenForWebhook registers the webhook with Telegram and sends incoming
+// updates to the returned channel. Once ctx is cancelled, the HTTP server is
+// stopped, the webhook is deleted and the channel is closed.
+func (b *Bot) ListenForWebhook(ctx context.Context) (<-chan tgbotapi.Update, error) {
+	config := b.getConfig()
+	webhookURL, err := url.Parse(config.WebhookURL)
+	if err != nil {
+		return nil, err
+	}
+	path := webhookURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	updates := newWebhookUpdates()
+	mux := http.NewServeMux()
+	mux.Handle(path, b.webhookHandler(config.WebhookSecret, updates))
+	server := &http.Server{
+		Addr:              config.WebhookListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// The certificate and the address are checked before Telegram is pointed
+	// at the webhook
+	if config.WebhookCert != "" {
+		certificate, err := tls.LoadX509KeyPair(config.WebhookCert, config.WebhookKey)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	}
+	listener, err := net.Listen("tcp", config.WebhookListen)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.setWebhook(config.WebhookURL, config.WebhookSecret, config.WebhookCert); err != nil {
+		listener.Close()
+		return nil, telegramError(err)
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			serverErrors <- server.ServeTLS(listener, "", "")
+		} else {
+			serverErrors <- server.Serve(listener)
+		}
+	}()
+	slog.Info("Listening for webhook updates", "address", config.WebhookListen, "path", path)
+
+	go func() {
+		defer updates.close()
+		select {
+		case <-ctx.Done():
+		case err := <-serverErrors:
+			slog.Error("Webhook server stopped", "error", err)
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownPeriod)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Error stopping webhook server", "error", err)
+		}
+		if _, err := b.Bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			slog.Error("Error deleting webhook", "error", telegramError(err))
+		}
+	}()
+
+	return updates.updates, nil
+}
+
+// webhookUpdates passes updates from the webhook handlers to HandleUpdates.
+// Handlers still running when the server's shutdown times out must not send
+// on the closed channel.
+type webhookUpdates struct {
+	updates chan tgbotapi.Update
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+}
+
+func newWebhookUpdates() *webhookUpdates {
+	return &webhookUpdates{updates: make(chan tgbotapi.Update), done: make(chan struct{})}
+}
+
+// send passes an update on, it returns false once the updates are closed.
+func (w *webhookUpdates) send(update tgbotapi.Update) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+	select {
+	case w.updates <- update:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// close stops pending sends and closes the channel once no handler sends.
+func (w *webhookUpdates) close() {
+	close(w.done)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	close(w.updates)
+}
+
+func (b *Bot) webhookHandler(secret string, updates *webhookUpdates) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(webhookSecretHeader)), []byte(secret)) != 1 {
+			http.Error(w, "invalid secret token", http.StatusUnauthorized)
+			return
+		}
+		update, err := b.Bot.HandleUpdate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Telegram delivers the update again after an error
+		if !updates.send(*update) {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// setWebhook calls setWebhook directly, as tgbotapi.WebhookConfig does not
+// support secret tokens.
+func (b *Bot) setWebhook(webhookURL, secret, certificate string) error {
+	params := tgbotapi.Params{"url": webhookURL}
+	params.AddNonEmpty("secret_token", secret)
+
+	var resp *tgbotapi.APIResponse
+	var err error
+	if certificate != "" {
+		files := []tgbotapi.RequestFile{{Name: "certificate", Data: tgbotapi.FilePath(certificate)}}
+		resp, err = b.Bot.UploadFiles("setWebhook", params, files)
+	} else {
+		resp, err = b.Bot.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return errors.New(resp.Description)
+	}
+	return nil
+}
+
+// deleteWebhook removes a previously set webhook, which would otherwise
+// prevent long polling.
+func (b *Bot) deleteWebhook() {
+	info, err := b.Bot.GetWebhookInfo()
+	if err != nil {
+		slog.Error("Error getting webhook info", "error", telegramError(err))
+		return
+	}
+	if !info.IsSet() {
+		return
+	}
+	if _, err := b.Bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		slog.Error("Error deleting webhook", "error", telegramError(err))
+	}
+}
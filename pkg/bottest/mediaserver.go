@@ -0,0 +1,50 @@
+package bottest
+
+import (
+	"sync"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/mediaserver"
+)
+
+// MediaServer is a fake Plex, Jellyfin or Emby server with a fixed watch
+// history. It implements the bot's MediaServer interface.
+type MediaServer struct {
+	// Err is returned by all calls if set.
+	Err error
+
+	mu      sync.Mutex
+	watched mediaserver.Watched
+}
+
+// NewMediaServer returns a media server without watched episodes.
+func NewMediaServer() *MediaServer {
+	return &MediaServer{watched: make(mediaserver.Watched)}
+}
+
+// MarkWatched marks episodes of a season as watched.
+func (m *MediaServer) MarkWatched(tvdbID int64, season int, episodes ...int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, episode := range episodes {
+		m.watched.Add(tvdbID, season, episode)
+	}
+}
+
+func (m *MediaServer) Name() string {
+	return "Mock"
+}
+
+func (m *MediaServer) Watched() (mediaserver.Watched, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	watched := make(mediaserver.Watched, len(m.watched))
+	for tvdbID, episodes := range m.watched {
+		for episode := range episodes {
+			watched.Add(tvdbID, episode.Season, episode.Number)
+		}
+	}
+	return watched, nil
+}
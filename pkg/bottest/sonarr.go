@@ -0,0 +1,732 @@
+// Package bottest provides an in-memory fake Sonarr server, a recording fake
+// Telegram sender and a fake media server to test the bot's conversations end
+// to end.
+package bottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golift.io/starr"
+	"golift.io/starr/sonarr"
+
+	"github.com/woiza/telegram-bot-sonarr/pkg/sonarrapi"
+)
+
+// DeletedSeries records a call to DeleteSeries.
+type DeletedSeries struct {
+	ID            int64
+	DeleteFiles   bool
+	ImportExclude bool
+}
+
+// Sonarr is an in-memory fake of the Sonarr API. The exported fields may be
+// set up before use. All returned objects are copies, so changes made by the
+// bot only take effect through the API calls, like with a real server.
+type Sonarr struct {
+	// Catalog is searched by Lookup, by case-insensitive title substring or
+	// by "tvdb:", "imdb:" and "tvmaze:" ID.
+	Catalog         []*sonarr.Series
+	Series          []*sonarr.Series
+	Episodes        []*sonarr.Episode
+	EpisodeFiles    []*sonarr.EpisodeFile
+	QualityProfiles []*sonarr.QualityProfile
+	RootFolders     []*sonarr.RootFolder
+	Tags            []*starr.Tag
+	ImportLists     []*sonarr.ImportListOutput
+	Exclusions      []*sonarr.Exclusion
+	Indexers        []*sonarr.IndexerOutput
+	DownloadClients []*sonarr.DownloadClientOutput
+	Status          sonarrapi.SystemStatus
+	Tasks           []*sonarrapi.Task
+	Health          []*sonarrapi.Health
+	// Failing providers, see sonarrapi.ProviderStatus
+	IndexerStatus        []*sonarrapi.ProviderStatus
+	DownloadClientStatus []*sonarrapi.ProviderStatus
+	// Provider tests fail with the error message stored for the provider's ID.
+	IndexerTestFailures        map[int64]string
+	DownloadClientTestFailures map[int64]string
+	// Logs are returned by GetLogs in this order, newest first.
+	Logs []*sonarrapi.LogRecord
+	// Recorded calls
+	Commands []*sonarr.CommandRequest
+	Deleted  []DeletedSeries
+	// Err is returned by all calls if set.
+	Err error
+
+	mu     sync.Mutex
+	nextID int64
+}
+
+// NewSonarr returns a fake with one quality profile and one root folder.
+func NewSonarr() *Sonarr {
+	return &Sonarr{
+		QualityProfiles: []*sonarr.QualityProfile{{ID: 1, Name: "HD-1080p"}},
+		RootFolders:     []*sonarr.RootFolder{{ID: 1, Path: "/tv", FreeSpace: 1 << 40}},
+		Status: sonarrapi.SystemStatus{
+			SystemStatus: sonarr.SystemStatus{AppName: "Sonarr", Version: "4.0.0.0"},
+			DatabaseType: "sqLite",
+		},
+		nextID: 100,
+	}
+}
+
+// FindSeries returns the library series with the given TVDB ID, or nil.
+func (s *Sonarr) FindSeries(tvdbID int64) *sonarr.Series {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, series := range s.Series {
+		if series.TvdbID == tvdbID {
+			return clone(series)
+		}
+	}
+	return nil
+}
+
+func (s *Sonarr) Ping() error {
+	return s.Err
+}
+
+func (s *Sonarr) GetSystemStatusDetails() (*sonarrapi.SystemStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return clone(&s.Status), nil
+}
+
+func (s *Sonarr) GetSystemTasks() ([]*sonarrapi.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.Tasks), nil
+}
+
+func (s *Sonarr) GetHealth() ([]*sonarrapi.Health, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.Health), nil
+}
+
+// logLevels are the log levels from lowest to highest.
+var logLevels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+func (s *Sonarr) GetLogs(page, pageSize int, level string) (*sonarrapi.LogPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var records []*sonarrapi.LogRecord
+	for _, record := range s.Logs {
+		if level == "" || logLevelIndex(record.Level) >= logLevelIndex(level) {
+			records = append(records, record)
+		}
+	}
+	logPage := &sonarrapi.LogPage{Page: page, PageSize: pageSize, TotalRecords: len(records)}
+	start := min((page-1)*pageSize, len(records))
+	end := min(start+pageSize, len(records))
+	logPage.Records = cloneAll(records[start:end])
+	return logPage, nil
+}
+
+func logLevelIndex(level string) int {
+	for i, l := range logLevels {
+		if strings.EqualFold(l, level) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Sonarr) Lookup(term string) ([]*sonarr.Series, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	term = strings.ToLower(strings.Trim(term, `"`))
+	var results []*sonarr.Series
+	for _, series := range s.Catalog {
+		if !lookupMatches(series, term) {
+			continue
+		}
+		result := clone(series)
+		// Series in the library are returned with their library ID
+		for _, inLibrary := range s.Series {
+			if inLibrary.TvdbID == series.TvdbID {
+				result = clone(inLibrary)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// lookupMatches matches "tvdb:", "imdb:" and "tvmaze:" terms by ID, other
+// terms by title.
+func lookupMatches(series *sonarr.Series, term string) bool {
+	prefix, id, found := strings.Cut(term, ":")
+	if !found {
+		return strings.Contains(strings.ToLower(series.Title), term)
+	}
+	switch prefix {
+	case "tvdb":
+		return strconv.FormatInt(series.TvdbID, 10) == id
+	case "imdb":
+		return series.ImdbID == id
+	case "tvmaze":
+		return strconv.FormatInt(series.TvMazeID, 10) == id
+	default:
+		return false
+	}
+}
+
+func (s *Sonarr) GetSeries(tvdbID int64) ([]*sonarr.Series, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var results []*sonarr.Series
+	for _, series := range s.Series {
+		if tvdbID == 0 || series.TvdbID == tvdbID {
+			results = append(results, clone(series))
+		}
+	}
+	return results, nil
+}
+
+func (s *Sonarr) GetSeriesByID(seriesID int64) (*sonarr.Series, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	series := s.seriesByID(seriesID)
+	if series == nil {
+		return nil, fmt.Errorf("series %d not found", seriesID)
+	}
+	return clone(series), nil
+}
+
+func (s *Sonarr) AddSeries(input *sonarr.AddSeriesInput) (*sonarr.Series, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for _, series := range s.Series {
+		if series.TvdbID == input.TvdbID {
+			return nil, fmt.Errorf("series %d already exists", input.TvdbID)
+		}
+	}
+	series := &sonarr.Series{}
+	for _, candidate := range s.Catalog {
+		if candidate.TvdbID == input.TvdbID {
+			series = clone(candidate)
+		}
+	}
+	s.nextID++
+	series.ID = s.nextID
+	applyInput(series, input)
+	s.Series = append(s.Series, series)
+	return clone(series), nil
+}
+
+func (s *Sonarr) UpdateSeries(input *sonarr.AddSeriesInput, moveFiles bool) (*sonarr.Series, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	series := s.seriesByID(input.ID)
+	if series == nil {
+		return nil, fmt.Errorf("series %d not found", input.ID)
+	}
+	applyInput(series, input)
+	return clone(series), nil
+}
+
+func (s *Sonarr) DeleteSeries(seriesID int, deleteFiles bool, importExclude bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	if s.seriesByID(int64(seriesID)) == nil {
+		return fmt.Errorf("series %d not found", seriesID)
+	}
+	var remaining []*sonarr.Series
+	for _, series := range s.Series {
+		if series.ID != int64(seriesID) {
+			remaining = append(remaining, series)
+		}
+	}
+	s.Series = remaining
+	s.Deleted = append(s.Deleted, DeletedSeries{ID: int64(seriesID), DeleteFiles: deleteFiles, ImportExclude: importExclude})
+	return nil
+}
+
+func (s *Sonarr) GetSeriesEpisodes(getEpisode *sonarr.GetEpisode) ([]*sonarr.Episode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var results []*sonarr.Episode
+	for _, episode := range s.Episodes {
+		if getEpisode.SeriesID != 0 && episode.SeriesID != getEpisode.SeriesID {
+			continue
+		}
+		if getEpisode.SeasonNumber != 0 && episode.SeasonNumber != getEpisode.SeasonNumber {
+			continue
+		}
+		results = append(results, clone(episode))
+	}
+	return results, nil
+}
+
+func (s *Sonarr) GetSeriesEpisodeFiles(seriesID int64) ([]*sonarr.EpisodeFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var results []*sonarr.EpisodeFile
+	for _, file := range s.EpisodeFiles {
+		if file.SeriesID == seriesID {
+			results = append(results, clone(file))
+		}
+	}
+	return results, nil
+}
+
+func (s *Sonarr) DeleteEpisodeFile(episodeFileID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	var remaining []*sonarr.EpisodeFile
+	for _, file := range s.EpisodeFiles {
+		if file.ID != episodeFileID {
+			remaining = append(remaining, file)
+		}
+	}
+	if len(remaining) == len(s.EpisodeFiles) {
+		return fmt.Errorf("episode file %d not found", episodeFileID)
+	}
+	s.EpisodeFiles = remaining
+	return nil
+}
+
+func (s *Sonarr) GetCalendar(filter sonarr.Calendar) ([]*sonarr.Episode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var results []*sonarr.Episode
+	for _, episode := range s.Episodes {
+		if episode.AirDateUtc.Before(filter.Start) || episode.AirDateUtc.After(filter.End) {
+			continue
+		}
+		if !filter.Unmonitored && !episode.Monitored {
+			continue
+		}
+		results = append(results, clone(episode))
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].AirDateUtc.Before(results[j].AirDateUtc)
+	})
+	return results, nil
+}
+
+func (s *Sonarr) GetQualityProfiles() ([]*sonarr.QualityProfile, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.QualityProfiles), nil
+}
+
+func (s *Sonarr) GetRootFolders() ([]*sonarr.RootFolder, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.RootFolders), nil
+}
+
+func (s *Sonarr) GetTags() ([]*starr.Tag, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.Tags), nil
+}
+
+func (s *Sonarr) GetTagDetails() ([]*sonarrapi.TagDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var details []*sonarrapi.TagDetails
+	for _, tag := range s.Tags {
+		detail := &sonarrapi.TagDetails{ID: tag.ID, Label: tag.Label}
+		for _, series := range s.Series {
+			if slices.Contains(series.Tags, tag.ID) {
+				detail.SeriesIDs = append(detail.SeriesIDs, series.ID)
+			}
+		}
+		for _, indexer := range s.Indexers {
+			if slices.Contains(indexer.Tags, tag.ID) {
+				detail.IndexerIDs = append(detail.IndexerIDs, indexer.ID)
+			}
+		}
+		for _, client := range s.DownloadClients {
+			if slices.Contains(client.Tags, tag.ID) {
+				detail.DownloadClientIDs = append(detail.DownloadClientIDs, client.ID)
+			}
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
+
+// AddTag returns the existing tag if the label is taken, like Sonarr.
+func (s *Sonarr) AddTag(tag *starr.Tag) (*starr.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for _, existing := range s.Tags {
+		if existing.Label == tag.Label {
+			return clone(existing), nil
+		}
+	}
+	s.nextID++
+	added := &starr.Tag{ID: int(s.nextID), Label: tag.Label}
+	s.Tags = append(s.Tags, added)
+	return clone(added), nil
+}
+
+func (s *Sonarr) UpdateTag(tag *starr.Tag) (*starr.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for _, existing := range s.Tags {
+		if existing.ID == tag.ID {
+			existing.Label = tag.Label
+			return clone(existing), nil
+		}
+	}
+	return nil, fmt.Errorf("tag %d not found", tag.ID)
+}
+
+func (s *Sonarr) DeleteTag(tagID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	for i, tag := range s.Tags {
+		if tag.ID == tagID {
+			s.Tags = append(s.Tags[:i], s.Tags[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("tag %d not found", tagID)
+}
+
+func (s *Sonarr) SendCommand(cmd *sonarr.CommandRequest) (*sonarr.CommandResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	s.Commands = append(s.Commands, clone(cmd))
+	return &sonarr.CommandResponse{ID: int64(len(s.Commands)), Name: cmd.Name, Status: "queued"}, nil
+}
+
+func (s *Sonarr) GetImportLists() ([]*sonarr.ImportListOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.ImportLists), nil
+}
+
+func (s *Sonarr) PatchImportList(importListID int64, fields map[string]any) (*sonarr.ImportListOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for i, list := range s.ImportLists {
+		if list.ID != importListID {
+			continue
+		}
+		patched, err := patch(list, fields)
+		if err != nil {
+			return nil, err
+		}
+		s.ImportLists[i] = patched
+		return clone(patched), nil
+	}
+	return nil, fmt.Errorf("import list %d not found", importListID)
+}
+
+func (s *Sonarr) GetExclusions() ([]*sonarr.Exclusion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.Exclusions), nil
+}
+
+func (s *Sonarr) DeleteExclusions(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	for _, id := range ids {
+		var remaining []*sonarr.Exclusion
+		for _, exclusion := range s.Exclusions {
+			if exclusion.ID != id {
+				remaining = append(remaining, exclusion)
+			}
+		}
+		if len(remaining) == len(s.Exclusions) {
+			return fmt.Errorf("exclusion %d not found", id)
+		}
+		s.Exclusions = remaining
+	}
+	return nil
+}
+
+func (s *Sonarr) GetIndexers() ([]*sonarr.IndexerOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.Indexers), nil
+}
+
+func (s *Sonarr) GetDownloadClients() ([]*sonarr.DownloadClientOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.DownloadClients), nil
+}
+
+func (s *Sonarr) GetIndexerStatus() ([]*sonarrapi.ProviderStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.IndexerStatus), nil
+}
+
+func (s *Sonarr) GetDownloadClientStatus() ([]*sonarrapi.ProviderStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return cloneAll(s.DownloadClientStatus), nil
+}
+
+func (s *Sonarr) TestSavedIndexer(indexerID int64) (*sonarrapi.ProviderTestResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for _, indexer := range s.Indexers {
+		if indexer.ID == indexerID {
+			return testResult(indexerID, s.IndexerTestFailures), nil
+		}
+	}
+	return nil, fmt.Errorf("indexer %d not found", indexerID)
+}
+
+func (s *Sonarr) TestSavedDownloadClient(downloadClientID int64) (*sonarrapi.ProviderTestResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for _, client := range s.DownloadClients {
+		if client.ID == downloadClientID {
+			return testResult(downloadClientID, s.DownloadClientTestFailures), nil
+		}
+	}
+	return nil, fmt.Errorf("download client %d not found", downloadClientID)
+}
+
+func (s *Sonarr) TestAllIndexers() ([]*sonarrapi.ProviderTestResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var results []*sonarrapi.ProviderTestResult
+	for _, indexer := range s.Indexers {
+		if indexer.EnableRss || indexer.EnableAutomaticSearch || indexer.EnableInteractiveSearch {
+			results = append(results, testResult(indexer.ID, s.IndexerTestFailures))
+		}
+	}
+	return results, nil
+}
+
+func (s *Sonarr) TestAllDownloadClients() ([]*sonarrapi.ProviderTestResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var results []*sonarrapi.ProviderTestResult
+	for _, client := range s.DownloadClients {
+		if client.Enable {
+			results = append(results, testResult(client.ID, s.DownloadClientTestFailures))
+		}
+	}
+	return results, nil
+}
+
+func (s *Sonarr) PatchIndexer(indexerID int64, fields map[string]any) (*sonarr.IndexerOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for i, indexer := range s.Indexers {
+		if indexer.ID != indexerID {
+			continue
+		}
+		patched, err := patch(indexer, fields)
+		if err != nil {
+			return nil, err
+		}
+		s.Indexers[i] = patched
+		return clone(patched), nil
+	}
+	return nil, fmt.Errorf("indexer %d not found", indexerID)
+}
+
+func (s *Sonarr) PatchDownloadClient(downloadClientID int64, fields map[string]any) (*sonarr.DownloadClientOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for i, client := range s.DownloadClients {
+		if client.ID != downloadClientID {
+			continue
+		}
+		patched, err := patch(client, fields)
+		if err != nil {
+			return nil, err
+		}
+		s.DownloadClients[i] = patched
+		return clone(patched), nil
+	}
+	return nil, fmt.Errorf("download client %d not found", downloadClientID)
+}
+
+func (s *Sonarr) seriesByID(seriesID int64) *sonarr.Series {
+	for _, series := range s.Series {
+		if series.ID == seriesID {
+			return series
+		}
+	}
+	return nil
+}
+
+func applyInput(series *sonarr.Series, input *sonarr.AddSeriesInput) {
+	series.Monitored = input.Monitored
+	series.SeasonFolder = input.SeasonFolder
+	series.QualityProfileID = input.QualityProfileID
+	series.TvdbID = input.TvdbID
+	series.Title = input.Title
+	series.SeriesType = input.SeriesType
+	series.RootFolderPath = input.RootFolderPath
+	series.Tags = append([]int(nil), input.Tags...)
+	if input.Seasons != nil {
+		series.Seasons = cloneAll(input.Seasons)
+	}
+}
+
+func testResult(providerID int64, failures map[int64]string) *sonarrapi.ProviderTestResult {
+	result := &sonarrapi.ProviderTestResult{ID: providerID, IsValid: true}
+	if failure, failed := failures[providerID]; failed {
+		result.IsValid = false
+		result.ValidationFailures = []*sonarrapi.ValidationFailure{{ErrorMessage: failure}}
+	}
+	return result
+}
+
+// clone returns a deep copy of v.
+func clone[T any](v *T) *T {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	var copied T
+	if err := json.Unmarshal(data, &copied); err != nil {
+		panic(err)
+	}
+	return &copied
+}
+
+// patch returns a copy of v with the JSON fields changed.
+func patch[T any](v *T, fields map[string]any) (*T, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var resource map[string]any
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, err
+	}
+	for name, value := range fields {
+		resource[name] = value
+	}
+	if data, err = json.Marshal(resource); err != nil {
+		return nil, err
+	}
+	var patched T
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return nil, err
+	}
+	return &patched, nil
+}
+
+func cloneAll[T any](values []*T) []*T {
+	copies := make([]*T, len(values))
+	for i, v := range values {
+		copies[i] = clone(v)
+	}
+	return copies
+}
@@ -0,0 +1,171 @@
+package bottest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Message is the current state of a message sent by the bot.
+type Message struct {
+	ChatID    int64
+	MessageID int
+	Text      string
+	Keyboard  *tgbotapi.InlineKeyboardMarkup
+	Document  *tgbotapi.DocumentConfig
+}
+
+// Button returns the callback data of the first button whose label contains
+// label, or "" if there is no such button.
+func (m *Message) Button(label string) string {
+	if m == nil || m.Keyboard == nil {
+		return ""
+	}
+	for _, row := range m.Keyboard.InlineKeyboard {
+		for _, button := range row {
+			if strings.Contains(button.Text, label) && button.CallbackData != nil {
+				return *button.CallbackData
+			}
+		}
+	}
+	return ""
+}
+
+// Telegram records the messages sent and edited by the bot. It implements the
+// bot's TelegramSender and TelegramFiles interfaces.
+type Telegram struct {
+	// Sent contains every chattable in the order it was sent or requested
+	Sent []tgbotapi.Chattable
+	// Err is returned by all calls if set.
+	Err error
+	// Files are the documents sent to the bot by file ID
+	Files map[string][]byte
+
+	mu            sync.Mutex
+	messages      []*Message
+	lastMessageID int
+}
+
+// NewTelegram returns an empty recorder.
+func NewTelegram() *Telegram {
+	return &Telegram{Files: make(map[string][]byte)}
+}
+
+func (t *Telegram) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Err != nil {
+		return tgbotapi.Message{}, t.Err
+	}
+	t.Sent = append(t.Sent, c)
+
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		msg := t.newMessage(m.ChatID)
+		msg.Text = m.Text
+		if keyboard, ok := m.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup); ok {
+			msg.Keyboard = &keyboard
+		}
+		return t.apiMessage(msg), nil
+	case tgbotapi.DocumentConfig:
+		msg := t.newMessage(m.ChatID)
+		msg.Text = m.Caption
+		msg.Document = &m
+		return t.apiMessage(msg), nil
+	case tgbotapi.EditMessageTextConfig:
+		msg := t.find(m.ChatID, m.MessageID)
+		if msg == nil {
+			return tgbotapi.Message{}, fmt.Errorf("Bad Request: message to edit not found")
+		}
+		msg.Text = m.Text
+		msg.Keyboard = m.ReplyMarkup
+		return t.apiMessage(msg), nil
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		msg := t.find(m.ChatID, m.MessageID)
+		if msg == nil {
+			return tgbotapi.Message{}, fmt.Errorf("Bad Request: message to edit not found")
+		}
+		msg.Keyboard = m.ReplyMarkup
+		return t.apiMessage(msg), nil
+	case tgbotapi.DeleteMessageConfig:
+		t.remove(m.ChatID, m.MessageID)
+		return tgbotapi.Message{}, nil
+	}
+	return tgbotapi.Message{}, nil
+}
+
+func (t *Telegram) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	if _, err := t.Send(c); err != nil {
+		return nil, err
+	}
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// DownloadFile returns a file from Files.
+func (t *Telegram) DownloadFile(fileID string) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data, ok := t.Files[fileID]
+	if !ok {
+		return nil, fmt.Errorf("Bad Request: invalid file_id")
+	}
+	return data, nil
+}
+
+// Messages returns the current state of all messages in a chat, oldest first.
+func (t *Telegram) Messages(chatID int64) []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var messages []Message
+	for _, msg := range t.messages {
+		if msg.ChatID == chatID {
+			messages = append(messages, *msg)
+		}
+	}
+	return messages
+}
+
+// Last returns the current state of the latest message in a chat, or nil.
+func (t *Telegram) Last(chatID int64) *Message {
+	messages := t.Messages(chatID)
+	if len(messages) == 0 {
+		return nil
+	}
+	return &messages[len(messages)-1]
+}
+
+func (t *Telegram) newMessage(chatID int64) *Message {
+	t.lastMessageID++
+	msg := &Message{ChatID: chatID, MessageID: t.lastMessageID}
+	t.messages = append(t.messages, msg)
+	return msg
+}
+
+func (t *Telegram) find(chatID int64, messageID int) *Message {
+	for _, msg := range t.messages {
+		if msg.ChatID == chatID && msg.MessageID == messageID {
+			return msg
+		}
+	}
+	return nil
+}
+
+func (t *Telegram) remove(chatID int64, messageID int) {
+	for i, msg := range t.messages {
+		if msg.ChatID == chatID && msg.MessageID == messageID {
+			t.messages = append(t.messages[:i], t.messages[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *Telegram) apiMessage(msg *Message) tgbotapi.Message {
+	return tgbotapi.Message{
+		MessageID:   msg.MessageID,
+		Chat:        &tgbotapi.Chat{ID: msg.ChatID},
+		Text:        msg.Text,
+		ReplyMarkup: msg.Keyboard,
+	}
+}
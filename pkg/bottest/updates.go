@@ -0,0 +1,64 @@
+package bottest
+
+import (
+	"strings"
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var lastUpdateID atomic.Int64
+
+// User returns the user sending updates in a private chat.
+func User(chatID int64) *tgbotapi.User {
+	return &tgbotapi.User{ID: chatID, UserName: "user"}
+}
+
+// Command returns an update with a command message, e.g. "/q Title".
+func Command(chatID int64, text string) tgbotapi.Update {
+	update := Text(chatID, text)
+	command, _, _ := strings.Cut(text, " ")
+	update.Message.Entities = []tgbotapi.MessageEntity{{
+		Type:   "bot_command",
+		Length: len(command),
+	}}
+	return update
+}
+
+// Text returns an update with a plain text message.
+func Text(chatID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: int(lastUpdateID.Add(1)),
+		Message: &tgbotapi.Message{
+			From: User(chatID),
+			Chat: &tgbotapi.Chat{ID: chatID, Type: "private"},
+			Text: text,
+		},
+	}
+}
+
+// Callback returns an update for a button with the given callback data
+// pressed on a message.
+func Callback(chatID int64, messageID int, data string) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: int(lastUpdateID.Add(1)),
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   "callback",
+			From: User(chatID),
+			Message: &tgbotapi.Message{
+				MessageID: messageID,
+				Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+			},
+			Data: data,
+		},
+	}
+}
+
+// Document returns an update with a document sent to the bot. The file must
+// be in Telegram.Files under fileID.
+func Document(chatID int64, fileID string, fileName string, caption string) tgbotapi.Update {
+	update := Text(chatID, "")
+	update.Message.Caption = caption
+	update.Message.Document = &tgbotapi.Document{FileID: fileID, FileName: fileName}
+	return update
+}
@@ -1,45 +1,160 @@
 package config
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
+const (
+	webhookSecretChars       = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-"
+	defaultDeleteGracePeriod = 30 * time.Second
+	presetPrefix             = "SBOT_PRESET_"
+	cleanupRulePrefix        = "SBOT_CLEANUP_RULE_"
+	defaultCleanupInterval   = 24 * time.Hour
+	// Preset names end up in callback data, which is limited to 64 bytes
+	presetMaxNameLength = 32
+)
+
+// Monitor options of the add series wizard, see sonarr.AddSeriesOptions.
+var monitorOptions = []string{"all", "future", "missing", "existing", "recent", "pilot",
+	"firstSeason", "lastSeason", "monitorSpecials", "unmonitorSpecials", "none"}
+
+// Preset is a named set of answers for the add series wizard, defined by
+// SBOT_PRESET_<NAME>, e.g. SBOT_PRESET_ANIME_1080P is "anime-1080p".
+type Preset struct {
+	Name           string
+	QualityProfile string // name or ID
+	RootFolder     string
+	Tags           []string // labels, missing tags are created
+	SeriesType     string
+	Monitor        string
+	// Search is "", "missing", "cutoff" or "missing-cutoff"
+	Search string
+}
+
+// Actions of cleanup rules.
+const (
+	CleanupDeleteFiles = "delete-files"
+	CleanupUnmonitor   = "unmonitor"
+)
+
+// CleanupRule selects seasons to clean up, defined by SBOT_CLEANUP_RULE_<NAME>,
+// e.g. SBOT_CLEANUP_RULE_OLD_ENDED is "old-ended". All conditions that are
+// set must match.
+type CleanupRule struct {
+	Name string
+	// Action is CleanupDeleteFiles or CleanupUnmonitor
+	Action     string
+	Status     string   // "", "ended" or "continuing"
+	SeriesType string   // "", "standard", "daily" or "anime"
+	Tags       []string // labels, the series needs one of them
+	// UntouchedDays matches series without a new episode file for this many days
+	UntouchedDays int
+	// KeepSeasons keeps the last seasons of a series
+	KeepSeasons int
+	// Complete matches seasons with all episodes on disk
+	Complete bool
+}
+
 // BotConfig ...
 type Config struct {
+	ConfigFile       string
 	TelegramBotToken string
+	WebhookURL       string
+	WebhookListen    string
+	WebhookSecret    string
+	WebhookCert      string
+	WebhookKey       string
+	MetricsListen    string
+	LogLevel         slog.Level
+	LogFormat        string
+	AuditLogFile     string
+	AuditToAdmins    bool
+	SettingsFile     string
 	AllowedChatIDs   map[int64]bool
+	AdminChatIDs     map[int64]bool
 	MaxItems         int
-	IgnoreTags       bool
-	SeriesType       string
-	SonarrProtocol   string
-	SonarrHostname   string
-	SonarrPort       int
-	SonarrAPIKey     string
-	SonarrBaseUrl    string
+	// DeleteGracePeriod delays deletions so that they can be undone
+	DeleteGracePeriod time.Duration
+	IgnoreTags        bool
+	SeriesType        string
+	Presets           []Preset
+	CleanupRules      []CleanupRule
+	// CleanupInterval is the time between evaluations of the cleanup rules
+	CleanupInterval time.Duration
+	// DiscoverProvider is "", "trakt", "tmdb" or "fixture", see /discover
+	DiscoverProvider string
+	DiscoverAPIKey   string
+	DiscoverFixture  string
+	// MediaServerType is "", "plex", "jellyfin" or "emby", see watch status
+	MediaServerType   string
+	MediaServerURL    string
+	MediaServerToken  string
+	MediaServerUserID string
+	SonarrProtocol    string
+	SonarrHostname    string
+	SonarrPort        int
+	SonarrAPIKey      string
+	SonarrBaseUrl     string
 }
 
 func LoadConfig() (Config, error) {
 	var config Config
 
-	config.TelegramBotToken = os.Getenv("SBOT_TELEGRAM_BOT_TOKEN")
-	allowedUserIDs := os.Getenv("SBOT_BOT_ALLOWED_USERIDS")
-	botMaxItems := os.Getenv("SBOT_BOT_MAX_ITEMS")
-	botIgnoreTags := os.Getenv("SBOT_BOT_IGNORE_TAGS")
-	botSeriesType := os.Getenv("SBOT_BOT_SERIES_TYPE")
-	config.SonarrProtocol = os.Getenv("SBOT_SONARR_PROTOCOL")
-	config.SonarrHostname = os.Getenv("SBOT_SONARR_HOSTNAME")
-	sonarrPort := os.Getenv("SBOT_SONARR_PORT")
-	config.SonarrAPIKey = os.Getenv("SBOT_SONARR_API_KEY")
-	config.SonarrBaseUrl = os.Getenv("SBOT_SONARR_BASE_URL")
+	// Values from SBOT_CONFIG_FILE take precedence over environment variables
+	config.ConfigFile = os.Getenv("SBOT_CONFIG_FILE")
+	getenv, keys, err := newGetenv(config.ConfigFile)
+	if err != nil {
+		return config, err
+	}
+
+	config.TelegramBotToken = getenv("SBOT_TELEGRAM_BOT_TOKEN")
+	config.WebhookURL = getenv("SBOT_TELEGRAM_WEBHOOK_URL")
+	config.WebhookListen = getenv("SBOT_TELEGRAM_WEBHOOK_LISTEN")
+	config.WebhookSecret = getenv("SBOT_TELEGRAM_WEBHOOK_SECRET")
+	config.WebhookCert = getenv("SBOT_TELEGRAM_WEBHOOK_CERT")
+	config.WebhookKey = getenv("SBOT_TELEGRAM_WEBHOOK_KEY")
+	config.MetricsListen = getenv("SBOT_METRICS_LISTEN")
+	logLevel := getenv("SBOT_LOG_LEVEL")
+	config.LogFormat = strings.ToLower(getenv("SBOT_LOG_FORMAT"))
+	config.AuditLogFile = getenv("SBOT_AUDIT_LOG_FILE")
+	auditToAdmins := getenv("SBOT_AUDIT_NOTIFY_ADMINS")
+	config.SettingsFile = getenv("SBOT_SETTINGS_FILE")
+	allowedUserIDs := getenv("SBOT_BOT_ALLOWED_USERIDS")
+	adminUserIDs := getenv("SBOT_BOT_ADMIN_USERIDS")
+	botMaxItems := getenv("SBOT_BOT_MAX_ITEMS")
+	botIgnoreTags := getenv("SBOT_BOT_IGNORE_TAGS")
+	botDeleteGracePeriod := getenv("SBOT_BOT_DELETE_GRACE_PERIOD")
+	botSeriesType := getenv("SBOT_BOT_SERIES_TYPE")
+	config.DiscoverProvider = strings.ToLower(getenv("SBOT_DISCOVER_PROVIDER"))
+	config.DiscoverAPIKey = getenv("SBOT_DISCOVER_API_KEY")
+	config.DiscoverFixture = getenv("SBOT_DISCOVER_FIXTURE_FILE")
+	config.MediaServerType = strings.ToLower(getenv("SBOT_MEDIASERVER_TYPE"))
+	config.MediaServerURL = getenv("SBOT_MEDIASERVER_URL")
+	config.MediaServerToken = getenv("SBOT_MEDIASERVER_TOKEN")
+	config.MediaServerUserID = getenv("SBOT_MEDIASERVER_USER_ID")
+	cleanupInterval := getenv("SBOT_CLEANUP_INTERVAL")
+	config.SonarrProtocol = getenv("SBOT_SONARR_PROTOCOL")
+	config.SonarrHostname = getenv("SBOT_SONARR_HOSTNAME")
+	sonarrPort := getenv("SBOT_SONARR_PORT")
+	config.SonarrAPIKey = getenv("SBOT_SONARR_API_KEY")
+	config.SonarrBaseUrl = getenv("SBOT_SONARR_BASE_URL")
 
 	// Validate required fields
 	if config.TelegramBotToken == "" {
 		return config, errors.New("SBOT_TELEGRAM_BOT_TOKEN is empty or not set")
 	}
+	if err := validateWebhook(&config); err != nil {
+		return config, err
+	}
 	if allowedUserIDs == "" {
 		return config, errors.New("SBOT_BOT_ALLOWED_USERIDS is empty or not set")
 	}
@@ -66,7 +181,7 @@ func LoadConfig() (Config, error) {
 
 	// Parsing SBOT_BOT_MAX_ITEMS as a number
 	maxItems, err := strconv.Atoi(botMaxItems)
-	if err != nil {
+	if err != nil || maxItems < 1 {
 		return config, errors.New("SBOT_BOT_MAX_ITEMS is not a valid number")
 	}
 	config.MaxItems = maxItems
@@ -78,6 +193,37 @@ func LoadConfig() (Config, error) {
 	}
 	config.IgnoreTags = ignoreTags
 
+	// Parsing optional SBOT_BOT_DELETE_GRACE_PERIOD as seconds, 0 deletes immediately
+	config.DeleteGracePeriod = defaultDeleteGracePeriod
+	if botDeleteGracePeriod != "" {
+		seconds, err := strconv.Atoi(botDeleteGracePeriod)
+		if err != nil || seconds < 0 {
+			return config, errors.New("SBOT_BOT_DELETE_GRACE_PERIOD is not a valid number of seconds")
+		}
+		config.DeleteGracePeriod = time.Duration(seconds) * time.Second
+	}
+
+	// Parsing optional SBOT_LOG_LEVEL and SBOT_LOG_FORMAT
+	if logLevel != "" {
+		if err := config.LogLevel.UnmarshalText([]byte(logLevel)); err != nil {
+			return config, errors.New("SBOT_LOG_LEVEL must be debug, info, warn or error")
+		}
+	}
+	if config.LogFormat == "" {
+		config.LogFormat = "text"
+	}
+	if config.LogFormat != "text" && config.LogFormat != "json" {
+		return config, errors.New("SBOT_LOG_FORMAT must be text or json")
+	}
+
+	// Parsing optional SBOT_AUDIT_NOTIFY_ADMINS as a boolean
+	if auditToAdmins != "" {
+		config.AuditToAdmins, err = strconv.ParseBool(auditToAdmins)
+		if err != nil {
+			return config, errors.New("SBOT_AUDIT_NOTIFY_ADMINS is not a valid boolean")
+		}
+	}
+
 	// Normalize and validate SBOT_BOT_SERIES_TYPE
 	if strings.EqualFold(botSeriesType, "standard") {
 		config.SeriesType = "standard"
@@ -89,17 +235,82 @@ func LoadConfig() (Config, error) {
 		config.SeriesType = ""
 	}
 
+	// Validate optional SBOT_DISCOVER_PROVIDER and its settings
+	switch config.DiscoverProvider {
+	case "":
+	case "trakt", "tmdb":
+		if config.DiscoverAPIKey == "" {
+			return config, fmt.Errorf("SBOT_DISCOVER_API_KEY is required for SBOT_DISCOVER_PROVIDER=%s", config.DiscoverProvider)
+		}
+	case "fixture":
+		if config.DiscoverFixture == "" {
+			return config, errors.New("SBOT_DISCOVER_FIXTURE_FILE is required for SBOT_DISCOVER_PROVIDER=fixture")
+		}
+	default:
+		return config, errors.New("SBOT_DISCOVER_PROVIDER must be trakt, tmdb or fixture")
+	}
+
+	if err := validateMediaServer(&config); err != nil {
+		return config, err
+	}
+
+	// Parsing optional SBOT_PRESET_<NAME> add series presets
+	for _, key := range keys {
+		if !strings.HasPrefix(key, presetPrefix) || len(key) == len(presetPrefix) {
+			continue
+		}
+		preset, err := parsePreset(key, getenv(key))
+		if err != nil {
+			return config, err
+		}
+		if preset.SeriesType == "" {
+			preset.SeriesType = config.SeriesType
+		}
+		config.Presets = append(config.Presets, preset)
+	}
+	sort.Slice(config.Presets, func(i, j int) bool {
+		return config.Presets[i].Name < config.Presets[j].Name
+	})
+
+	// Parsing optional SBOT_CLEANUP_RULE_<NAME> cleanup rules
+	for _, key := range keys {
+		if !strings.HasPrefix(key, cleanupRulePrefix) || len(key) == len(cleanupRulePrefix) {
+			continue
+		}
+		rule, err := parseCleanupRule(key, getenv(key))
+		if err != nil {
+			return config, err
+		}
+		config.CleanupRules = append(config.CleanupRules, rule)
+	}
+	sort.Slice(config.CleanupRules, func(i, j int) bool {
+		return config.CleanupRules[i].Name < config.CleanupRules[j].Name
+	})
+
+	// Parsing optional SBOT_CLEANUP_INTERVAL as hours
+	config.CleanupInterval = defaultCleanupInterval
+	if cleanupInterval != "" {
+		hours, err := strconv.Atoi(cleanupInterval)
+		if err != nil || hours < 1 {
+			return config, errors.New("SBOT_CLEANUP_INTERVAL is not a valid number of hours")
+		}
+		config.CleanupInterval = time.Duration(hours) * time.Hour
+	}
+
 	// Parsing SBOT_BOT_ALLOWED_USERIDS as a list of integers
-	userIDs := strings.Split(allowedUserIDs, ",")
-	parsedUserIDs := make(map[int64]bool)
-	for _, id := range userIDs {
-		parsedID, err := strconv.ParseInt(id, 10, 64)
+	config.AllowedChatIDs, err = parseIDs(allowedUserIDs)
+	if err != nil {
+		return config, fmt.Errorf("SBOT_BOT_ALLOWED_USERIDS contains non-integer value: %s", err)
+	}
+
+	// Parsing optional SBOT_BOT_ADMIN_USERIDS as a list of integers
+	config.AdminChatIDs = make(map[int64]bool)
+	if adminUserIDs != "" {
+		config.AdminChatIDs, err = parseIDs(adminUserIDs)
 		if err != nil {
-			return config, fmt.Errorf("SBOT_BOT_ALLOWED_USERIDS contains non-integer value: %s", err)
+			return config, fmt.Errorf("SBOT_BOT_ADMIN_USERIDS contains non-integer value: %s", err)
 		}
-		parsedUserIDs[parsedID] = true
 	}
-	config.AllowedChatIDs = parsedUserIDs
 
 	// Parsing SBOT_SONARR_PORT as a number
 	port, err := strconv.Atoi(sonarrPort)
@@ -110,3 +321,231 @@ func LoadConfig() (Config, error) {
 
 	return config, nil
 }
+
+// validateWebhook checks the optional webhook settings. Without
+// SBOT_TELEGRAM_WEBHOOK_URL the bot uses long polling.
+func validateWebhook(config *Config) error {
+	if config.WebhookURL == "" {
+		return nil
+	}
+	webhookURL, err := url.Parse(config.WebhookURL)
+	if err != nil || webhookURL.Scheme != "https" || webhookURL.Host == "" {
+		return errors.New("SBOT_TELEGRAM_WEBHOOK_URL must be a https URL")
+	}
+	if config.WebhookListen == "" {
+		config.WebhookListen = ":8443"
+	}
+	if len(config.WebhookSecret) > 256 || strings.Trim(config.WebhookSecret, webhookSecretChars) != "" {
+		return errors.New("SBOT_TELEGRAM_WEBHOOK_SECRET must be up to 256 characters A-Z, a-z, 0-9, _ and -")
+	}
+	if (config.WebhookCert == "") != (config.WebhookKey == "") {
+		return errors.New("SBOT_TELEGRAM_WEBHOOK_CERT and SBOT_TELEGRAM_WEBHOOK_KEY must be set together")
+	}
+	return nil
+}
+
+// validateMediaServer checks the optional media server settings used for
+// watch status.
+func validateMediaServer(config *Config) error {
+	switch config.MediaServerType {
+	case "":
+		return nil
+	case "plex", "jellyfin", "emby":
+	default:
+		return errors.New("SBOT_MEDIASERVER_TYPE must be plex, jellyfin or emby")
+	}
+	mediaServerURL, err := url.Parse(config.MediaServerURL)
+	if err != nil || (mediaServerURL.Scheme != "http" && mediaServerURL.Scheme != "https") || mediaServerURL.Host == "" {
+		return errors.New("SBOT_MEDIASERVER_URL must be a http or https URL")
+	}
+	if config.MediaServerToken == "" {
+		return errors.New("SBOT_MEDIASERVER_TOKEN is empty or not set")
+	}
+	if config.MediaServerType != "plex" && config.MediaServerUserID == "" {
+		return fmt.Errorf("SBOT_MEDIASERVER_USER_ID is required for SBOT_MEDIASERVER_TYPE=%s", config.MediaServerType)
+	}
+	return nil
+}
+
+// parsePreset parses a preset like
+// "profile=HD-1080p;rootfolder=/anime;tags=anime,subs;type=anime;monitor=future;search=missing".
+// Profile, root folder and monitor are required.
+func parsePreset(key, value string) (Preset, error) {
+	preset := Preset{Name: strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, presetPrefix), "_", "-"))}
+	if len(preset.Name) > presetMaxNameLength {
+		return preset, fmt.Errorf("%s: the name must be up to %d characters", key, presetMaxNameLength)
+	}
+	for _, field := range strings.Split(value, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, fieldValue, found := strings.Cut(field, "=")
+		if !found {
+			return preset, fmt.Errorf("%s: %q is not a key=value pair", key, field)
+		}
+		fieldValue = strings.TrimSpace(fieldValue)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "profile":
+			preset.QualityProfile = fieldValue
+		case "rootfolder":
+			preset.RootFolder = fieldValue
+		case "tags":
+			for _, tag := range strings.Split(fieldValue, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					preset.Tags = append(preset.Tags, tag)
+				}
+			}
+		case "type":
+			preset.SeriesType = strings.ToLower(fieldValue)
+			if preset.SeriesType != "standard" && preset.SeriesType != "daily" && preset.SeriesType != "anime" {
+				return preset, fmt.Errorf("%s: type must be standard, daily or anime", key)
+			}
+		case "monitor":
+			for _, option := range monitorOptions {
+				if strings.EqualFold(option, fieldValue) {
+					preset.Monitor = option
+				}
+			}
+			if preset.Monitor == "" {
+				return preset, fmt.Errorf("%s: monitor must be one of %s", key, strings.Join(monitorOptions, ", "))
+			}
+		case "search":
+			preset.Search = strings.ToLower(fieldValue)
+			if preset.Search == "none" {
+				preset.Search = ""
+			}
+			if preset.Search != "" && preset.Search != "missing" && preset.Search != "cutoff" && preset.Search != "missing-cutoff" {
+				return preset, fmt.Errorf("%s: search must be none, missing, cutoff or missing-cutoff", key)
+			}
+		default:
+			return preset, fmt.Errorf("%s: unknown setting %q", key, name)
+		}
+	}
+	if preset.QualityProfile == "" || preset.RootFolder == "" || preset.Monitor == "" {
+		return preset, fmt.Errorf("%s: profile, rootfolder and monitor are required", key)
+	}
+	return preset, nil
+}
+
+// parseCleanupRule parses a rule like
+// "action=delete-files;status=ended;untouched=180". The action is required.
+func parseCleanupRule(key, value string) (CleanupRule, error) {
+	rule := CleanupRule{Name: strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, cleanupRulePrefix), "_", "-"))}
+	for _, field := range strings.Split(value, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, fieldValue, found := strings.Cut(field, "=")
+		if !found {
+			return rule, fmt.Errorf("%s: %q is not a key=value pair", key, field)
+		}
+		fieldValue = strings.ToLower(strings.TrimSpace(fieldValue))
+		var err error
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "action":
+			rule.Action = fieldValue
+			if rule.Action != CleanupDeleteFiles && rule.Action != CleanupUnmonitor {
+				return rule, fmt.Errorf("%s: action must be %s or %s", key, CleanupDeleteFiles, CleanupUnmonitor)
+			}
+		case "status":
+			rule.Status = fieldValue
+			if rule.Status != "ended" && rule.Status != "continuing" {
+				return rule, fmt.Errorf("%s: status must be ended or continuing", key)
+			}
+		case "type":
+			rule.SeriesType = fieldValue
+			if rule.SeriesType != "standard" && rule.SeriesType != "daily" && rule.SeriesType != "anime" {
+				return rule, fmt.Errorf("%s: type must be standard, daily or anime", key)
+			}
+		case "tags":
+			for _, tag := range strings.Split(fieldValue, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					rule.Tags = append(rule.Tags, tag)
+				}
+			}
+		case "untouched":
+			rule.UntouchedDays, err = strconv.Atoi(fieldValue)
+			if err != nil || rule.UntouchedDays < 1 {
+				return rule, fmt.Errorf("%s: untouched must be a number of days", key)
+			}
+		case "keep-seasons":
+			rule.KeepSeasons, err = strconv.Atoi(fieldValue)
+			if err != nil || rule.KeepSeasons < 1 {
+				return rule, fmt.Errorf("%s: keep-seasons must be a number of seasons", key)
+			}
+		case "complete":
+			rule.Complete, err = strconv.ParseBool(fieldValue)
+			if err != nil {
+				return rule, fmt.Errorf("%s: complete must be true or false", key)
+			}
+		default:
+			return rule, fmt.Errorf("%s: unknown setting %q", key, name)
+		}
+	}
+	if rule.Action == "" {
+		return rule, fmt.Errorf("%s: action is required", key)
+	}
+	return rule, nil
+}
+
+func parseIDs(list string) (map[int64]bool, error) {
+	parsedIDs := make(map[int64]bool)
+	for _, id := range strings.Split(list, ",") {
+		parsedID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		parsedIDs[parsedID] = true
+	}
+	return parsedIDs, nil
+}
+
+// newGetenv returns a lookup function that prefers KEY=VALUE pairs from the
+// given file and falls back to the environment, and all keys of both. An
+// empty path only uses the environment.
+func newGetenv(path string) (func(string) string, []string, error) {
+	values := make(map[string]string)
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("SBOT_CONFIG_FILE cannot be read: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for lineNumber := 1; scanner.Scan(); lineNumber++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, found := strings.Cut(line, "=")
+			if !found {
+				return nil, nil, fmt.Errorf("SBOT_CONFIG_FILE line %d is not a KEY=VALUE pair", lineNumber)
+			}
+			values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("SBOT_CONFIG_FILE cannot be read: %w", err)
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	for _, variable := range os.Environ() {
+		key, _, _ := strings.Cut(variable, "=")
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return func(key string) string {
+		if value, ok := values[key]; ok {
+			return value
+		}
+		return os.Getenv(key)
+	}, keys, nil
+}
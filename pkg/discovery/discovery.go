@@ -0,0 +1,70 @@
+// Package discovery finds series that are not in the library yet: trending,
+// popular and anticipated series and series similar to another one. The
+// metadata comes from Trakt, TMDB or a local fixture file.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// List is a list of series a provider can show.
+type List string
+
+const (
+	Trending    List = "trending"
+	Popular     List = "popular"
+	Anticipated List = "anticipated"
+)
+
+// Lists are all lists in the order they are offered.
+var Lists = []List{Trending, Popular, Anticipated}
+
+// Show is a series found by a provider. Shows without a TVDB ID cannot be
+// added to Sonarr and are not returned.
+type Show struct {
+	Title  string `json:"title"`
+	Year   int    `json:"year"`
+	TvdbID int64  `json:"tvdbId"`
+	ImdbID string `json:"imdbId,omitempty"`
+}
+
+const requestTimeout = 30 * time.Second
+
+// getJSON decodes the JSON response of a GET request into target.
+func getJSON(client *http.Client, req *http.Request, target any) error {
+	ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+	defer cancel()
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		// The error contains the URL and with it the TMDB API key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%v %v: %w", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%v %v: %v", req.Method, req.URL.Path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("%v %v: %w", req.Method, req.URL.Path, err)
+	}
+	return nil
+}
+
+// truncate returns at most limit shows, limit 0 returns all.
+func truncate(shows []*Show, limit int) []*Show {
+	if limit > 0 && len(shows) > limit {
+		return shows[:limit]
+	}
+	return shows
+}
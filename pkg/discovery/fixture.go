@@ -0,0 +1,42 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Fixture serves shows from memory, e.g. for tests or to try /discover
+// without an API key. A fixture file looks like
+//
+//	{"lists": {"trending": [{"title": "Severance", "year": 2022, "tvdbId": 371980}]},
+//	 "related": {"81189": [{"title": "Better Call Saul", "year": 2015, "tvdbId": 273181}]}}
+type Fixture struct {
+	Lists   map[List][]*Show  `json:"lists"`
+	Related map[int64][]*Show `json:"related"`
+}
+
+// LoadFixture reads a fixture file.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+func (f *Fixture) Name() string {
+	return "fixture"
+}
+
+func (f *Fixture) List(list List, limit int) ([]*Show, error) {
+	return truncate(f.Lists[list], limit), nil
+}
+
+func (f *Fixture) Similar(tvdbID int64, limit int) ([]*Show, error) {
+	return truncate(f.Related[tvdbID], limit), nil
+}
@@ -0,0 +1,130 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const tmdbURL = "https://api.themoviedb.org/3"
+
+// TMDB finds series with the TMDB API, see https://developer.themoviedb.org.
+// Results only contain TMDB IDs, so the TVDB ID of every show is requested
+// separately.
+type TMDB struct {
+	APIKey string
+	// BaseURL defaults to https://api.themoviedb.org/3
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewTMDB returns a provider for a TMDB API key (v3 auth).
+func NewTMDB(apiKey string) *TMDB {
+	return &TMDB{APIKey: apiKey, BaseURL: tmdbURL, Client: http.DefaultClient}
+}
+
+type tmdbShow struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	FirstAirDate string `json:"first_air_date"`
+}
+
+type tmdbPage struct {
+	Results []tmdbShow `json:"results"`
+}
+
+type tmdbExternalIDs struct {
+	TvdbID int64  `json:"tvdb_id"`
+	ImdbID string `json:"imdb_id"`
+}
+
+func (t *TMDB) Name() string {
+	return "TMDB"
+}
+
+func (t *TMDB) List(list List, limit int) ([]*Show, error) {
+	var page tmdbPage
+	var err error
+	switch list {
+	case Trending:
+		err = t.get("/trending/tv/week", nil, &page)
+	case Popular:
+		err = t.get("/tv/popular", nil, &page)
+	case Anticipated:
+		// TMDB has no anticipated list, popular series that did not air yet come closest
+		query := url.Values{
+			"first_air_date.gte": {time.Now().Format(time.DateOnly)},
+			"sort_by":            {"popularity.desc"},
+		}
+		err = t.get("/discover/tv", query, &page)
+	default:
+		return nil, fmt.Errorf("unknown list %q", list)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t.shows(page.Results, limit), nil
+}
+
+func (t *TMDB) Similar(tvdbID int64, limit int) ([]*Show, error) {
+	var found struct {
+		TvResults []tmdbShow `json:"tv_results"`
+	}
+	if err := t.get(fmt.Sprintf("/find/%d", tvdbID), url.Values{"external_source": {"tvdb_id"}}, &found); err != nil {
+		return nil, err
+	}
+	if len(found.TvResults) == 0 {
+		return nil, fmt.Errorf("TVDB ID %d not found on TMDB", tvdbID)
+	}
+	var page tmdbPage
+	if err := t.get(fmt.Sprintf("/tv/%d/recommendations", found.TvResults[0].ID), nil, &page); err != nil {
+		return nil, err
+	}
+	return t.shows(page.Results, limit), nil
+}
+
+func (t *TMDB) get(path string, query url.Values, target any) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	// v3 keys are only accepted as query parameter, getJSON keeps the URL
+	// out of its errors
+	query.Set("api_key", t.APIKey)
+	req, err := http.NewRequest(http.MethodGet, t.BaseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return getJSON(t.Client, req, target)
+}
+
+// shows looks up the external IDs of the results in parallel and returns up
+// to limit shows that have a TVDB ID.
+func (t *TMDB) shows(results []tmdbShow, limit int) []*Show {
+	shows := make([]*Show, len(results))
+	var wg sync.WaitGroup
+	for i, result := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var ids tmdbExternalIDs
+			if err := t.get(fmt.Sprintf("/tv/%d/external_ids", result.ID), nil, &ids); err != nil || ids.TvdbID == 0 {
+				return
+			}
+			year, _ := strconv.Atoi(result.FirstAirDate[:min(4, len(result.FirstAirDate))])
+			shows[i] = &Show{Title: result.Name, Year: year, TvdbID: ids.TvdbID, ImdbID: ids.ImdbID}
+		}()
+	}
+	wg.Wait()
+
+	var found []*Show
+	for _, show := range shows {
+		if show != nil {
+			found = append(found, show)
+		}
+	}
+	return truncate(found, limit)
+}
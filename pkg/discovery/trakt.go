@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const traktURL = "https://api.trakt.tv"
+
+// Trakt finds series with the Trakt API, see https://trakt.docs.apiary.io.
+type Trakt struct {
+	ClientID string
+	// BaseURL defaults to https://api.trakt.tv
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewTrakt returns a provider for the client ID of a Trakt API app.
+func NewTrakt(clientID string) *Trakt {
+	return &Trakt{ClientID: clientID, BaseURL: traktURL, Client: http.DefaultClient}
+}
+
+type traktShow struct {
+	Title string `json:"title"`
+	Year  int    `json:"year"`
+	IDs   struct {
+		Trakt int64  `json:"trakt"`
+		Tvdb  int64  `json:"tvdb"`
+		Imdb  string `json:"imdb"`
+	} `json:"ids"`
+}
+
+// traktItem wraps the show in trending, anticipated and search results.
+type traktItem struct {
+	Show traktShow `json:"show"`
+}
+
+func (t *Trakt) Name() string {
+	return "Trakt"
+}
+
+func (t *Trakt) List(list List, limit int) ([]*Show, error) {
+	// Some shows lack a TVDB ID, ask for more to fill the list
+	query := url.Values{"limit": {strconv.Itoa(limit * 2)}}
+	if list == Popular {
+		var shows []traktShow
+		if err := t.get("/shows/popular", query, &shows); err != nil {
+			return nil, err
+		}
+		return truncate(traktShows(shows), limit), nil
+	}
+	if list != Trending && list != Anticipated {
+		return nil, fmt.Errorf("unknown list %q", list)
+	}
+	var items []traktItem
+	if err := t.get("/shows/"+string(list), query, &items); err != nil {
+		return nil, err
+	}
+	shows := make([]traktShow, len(items))
+	for i, item := range items {
+		shows[i] = item.Show
+	}
+	return truncate(traktShows(shows), limit), nil
+}
+
+func (t *Trakt) Similar(tvdbID int64, limit int) ([]*Show, error) {
+	var found []traktItem
+	if err := t.get(fmt.Sprintf("/search/tvdb/%d", tvdbID), url.Values{"type": {"show"}}, &found); err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("TVDB ID %d not found on Trakt", tvdbID)
+	}
+	var shows []traktShow
+	query := url.Values{"limit": {strconv.Itoa(limit * 2)}}
+	if err := t.get(fmt.Sprintf("/shows/%d/related", found[0].Show.IDs.Trakt), query, &shows); err != nil {
+		return nil, err
+	}
+	return truncate(traktShows(shows), limit), nil
+}
+
+func (t *Trakt) get(path string, query url.Values, target any) error {
+	req, err := http.NewRequest(http.MethodGet, t.BaseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("trakt-api-version", "2")
+	req.Header.Set("trakt-api-key", t.ClientID)
+	return getJSON(t.Client, req, target)
+}
+
+// traktShows converts the shows that have a TVDB ID.
+func traktShows(shows []traktShow) []*Show {
+	var result []*Show
+	for _, show := range shows {
+		if show.IDs.Tvdb == 0 {
+			continue
+		}
+		result = append(result, &Show{Title: show.Title, Year: show.Year, TvdbID: show.IDs.Tvdb, ImdbID: show.IDs.Imdb})
+	}
+	return result
+}
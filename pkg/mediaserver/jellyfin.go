@@ -0,0 +1,78 @@
+package mediaserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Jellyfin reads the watch history of a Jellyfin or Emby user. Both share
+// the same API.
+type Jellyfin struct {
+	URL    string // e.g. http://jellyfin:8096
+	APIKey string
+	UserID string
+	Client *http.Client
+	name   string
+}
+
+// NewJellyfin returns a client for a Jellyfin server.
+func NewJellyfin(url, apiKey, userID string) *Jellyfin {
+	return &Jellyfin{URL: strings.TrimRight(url, "/"), APIKey: apiKey, UserID: userID, Client: http.DefaultClient, name: "Jellyfin"}
+}
+
+// NewEmby returns a client for an Emby server.
+func NewEmby(url, apiKey, userID string) *Jellyfin {
+	client := NewJellyfin(url, apiKey, userID)
+	client.name = "Emby"
+	return client
+}
+
+type jellyfinItems struct {
+	Items []struct {
+		ID                string `json:"Id"`
+		SeriesID          string `json:"SeriesId"`
+		ParentIndexNumber int    `json:"ParentIndexNumber"`
+		IndexNumber       int    `json:"IndexNumber"`
+		ProviderIDs       struct {
+			Tvdb string `json:"Tvdb"`
+		} `json:"ProviderIds"`
+	} `json:"Items"`
+}
+
+func (j *Jellyfin) Name() string {
+	return j.name
+}
+
+// Watched reads the series and the played episodes of the user.
+func (j *Jellyfin) Watched() (Watched, error) {
+	var series jellyfinItems
+	if err := j.get("?IncludeItemTypes=Series&Recursive=true&Fields=ProviderIds", &series); err != nil {
+		return nil, err
+	}
+	tvdbIDs := make(map[string]int64)
+	for _, item := range series.Items {
+		tvdbIDs[item.ID], _ = strconv.ParseInt(item.ProviderIDs.Tvdb, 10, 64)
+	}
+
+	var episodes jellyfinItems
+	if err := j.get("?IncludeItemTypes=Episode&Recursive=true&IsPlayed=true", &episodes); err != nil {
+		return nil, err
+	}
+	watched := make(Watched)
+	for _, episode := range episodes.Items {
+		if tvdbID := tvdbIDs[episode.SeriesID]; tvdbID != 0 {
+			watched.Add(tvdbID, episode.ParentIndexNumber, episode.IndexNumber)
+		}
+	}
+	return watched, nil
+}
+
+func (j *Jellyfin) get(query string, target any) error {
+	req, err := http.NewRequest(http.MethodGet, j.URL+"/Users/"+j.UserID+"/Items"+query, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Emby-Token", j.APIKey)
+	return getJSON(j.Client, req, target)
+}
@@ -0,0 +1,70 @@
+// Package mediaserver reads the watch history of Plex, Jellyfin and Emby.
+// Series are matched with Sonarr by their TVDB ID, so the media server's
+// metadata agent must provide TVDB IDs.
+package mediaserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Episode is an episode of a series by season and episode number.
+type Episode struct {
+	Season int
+	Number int
+}
+
+// Watched are the watched episodes of each series by TVDB ID.
+type Watched map[int64]map[Episode]bool
+
+// Add marks an episode as watched.
+func (w Watched) Add(tvdbID int64, season, number int) {
+	if w[tvdbID] == nil {
+		w[tvdbID] = make(map[Episode]bool)
+	}
+	w[tvdbID][Episode{Season: season, Number: number}] = true
+}
+
+// SeasonCount returns the number of watched episodes of a season.
+func (w Watched) SeasonCount(tvdbID int64, season int) int {
+	var count int
+	for episode := range w[tvdbID] {
+		if episode.Season == season {
+			count++
+		}
+	}
+	return count
+}
+
+const requestTimeout = time.Minute
+
+// getJSON decodes the JSON response of a GET request into target.
+func getJSON(client *http.Client, req *http.Request, target any) error {
+	ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+	defer cancel()
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		// The error contains the URL, which may include credentials
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%v %v: %w", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%v %v: %v", req.Method, req.URL.Path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("%v %v: %w", req.Method, req.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package mediaserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Plex reads the watch history of the owner of a Plex token.
+type Plex struct {
+	URL    string // e.g. http://plex:32400
+	Token  string
+	Client *http.Client
+}
+
+// NewPlex returns a client for a Plex server.
+func NewPlex(url, token string) *Plex {
+	return &Plex{URL: strings.TrimRight(url, "/"), Token: token, Client: http.DefaultClient}
+}
+
+type plexContainer struct {
+	MediaContainer struct {
+		Directory []struct {
+			Key  string `json:"key"`
+			Type string `json:"type"`
+		} `json:"Directory"`
+		Metadata []plexMetadata `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+type plexMetadata struct {
+	RatingKey            string `json:"ratingKey"`
+	GrandparentRatingKey string `json:"grandparentRatingKey"`
+	ParentIndex          int    `json:"parentIndex"`
+	Index                int    `json:"index"`
+	ViewCount            int    `json:"viewCount"`
+	Guid                 []struct {
+		ID string `json:"id"` // e.g. tvdb://81189
+	} `json:"Guid"`
+}
+
+func (p *Plex) Name() string {
+	return "Plex"
+}
+
+// Watched reads the shows and episodes of all TV libraries.
+func (p *Plex) Watched() (Watched, error) {
+	var sections plexContainer
+	if err := p.get("/library/sections", &sections); err != nil {
+		return nil, err
+	}
+	watched := make(Watched)
+	for _, section := range sections.MediaContainer.Directory {
+		if section.Type != "show" {
+			continue
+		}
+		var shows plexContainer
+		if err := p.get("/library/sections/"+section.Key+"/all?type=2&includeGuids=1", &shows); err != nil {
+			return nil, err
+		}
+		tvdbIDs := make(map[string]int64)
+		for _, show := range shows.MediaContainer.Metadata {
+			for _, guid := range show.Guid {
+				if id, found := strings.CutPrefix(guid.ID, "tvdb://"); found {
+					tvdbIDs[show.RatingKey], _ = strconv.ParseInt(id, 10, 64)
+				}
+			}
+		}
+		var episodes plexContainer
+		if err := p.get("/library/sections/"+section.Key+"/all?type=4", &episodes); err != nil {
+			return nil, err
+		}
+		for _, episode := range episodes.MediaContainer.Metadata {
+			if tvdbID := tvdbIDs[episode.GrandparentRatingKey]; tvdbID != 0 && episode.ViewCount > 0 {
+				watched.Add(tvdbID, episode.ParentIndex, episode.Index)
+			}
+		}
+	}
+	return watched, nil
+}
+
+func (p *Plex) get(path string, target any) error {
+	req, err := http.NewRequest(http.MethodGet, p.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Plex-Token", p.Token)
+	return getJSON(p.Client, req, target)
+}
@@ -0,0 +1,209 @@
+// Package metrics implements a small set of Prometheus compatible metrics
+// without additional dependencies.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metrics exposed by the bot.
+var (
+	Commands = NewCounterVec("sbot_commands_total",
+		"Commands received, by command name.", "command")
+	Callbacks = NewCounterVec("sbot_callbacks_total",
+		"Inline keyboard callbacks received, by callback data prefix.", "prefix")
+	SonarrRequests = NewCounterVec("sbot_sonarr_requests_total",
+		"Requests sent to Sonarr, by endpoint and HTTP status code or \"error\".", "endpoint", "status")
+	SonarrRequestDuration = NewHistogramVec("sbot_sonarr_request_duration_seconds",
+		"Latency of requests sent to Sonarr, by endpoint.", []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}, "endpoint")
+	MessagesSent = NewCounterVec("sbot_messages_sent_total",
+		"Messages sent or edited successfully.")
+	MessagesFailed = NewCounterVec("sbot_messages_failed_total",
+		"Messages that could not be sent or edited.")
+)
+
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	muRegistry sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	muRegistry.Lock()
+	defer muRegistry.Unlock()
+	registry = append(registry, c)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func WriteTo(w io.Writer) {
+	muRegistry.Lock()
+	defer muRegistry.Unlock()
+	for _, c := range registry {
+		c.write(w)
+	}
+}
+
+// Handler serves all metrics in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteTo(w)
+	})
+}
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+}
+
+// NewCounterVec creates and registers a counter with the given label names.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[formatLabels(c.labels, labelValues)]++
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, labels := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %v\n", c.name, labels, c.values[labels])
+	}
+}
+
+// HistogramVec is a histogram partitioned by label values.
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	values     map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates and registers a histogram with the given upper
+// bucket bounds and label names.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogram)}
+	register(h)
+	return h
+}
+
+// Observe adds a single observation for the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	hist, exists := h.values[key]
+	if !exists {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hist
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.values) {
+		hist := h.values[key]
+		var labelValues []string
+		if len(h.labels) > 0 {
+			labelValues = strings.Split(key, "\xff")
+		}
+		bucketLabels := append(append([]string{}, h.labels...), "le")
+		bucketValues := append(append([]string{}, labelValues...), "")
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hist.counts[i]
+			bucketValues[len(bucketValues)-1] = fmt.Sprint(bound)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, bucketValues), cumulative)
+		}
+		bucketValues[len(bucketValues)-1] = "+Inf"
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, bucketValues), hist.count)
+		labels := formatLabels(h.labels, labelValues)
+		fmt.Fprintf(w, "%s_sum%s %v\n%s_count%s %d\n", h.name, labels, hist.sum, h.name, labels, hist.count)
+	}
+}
+
+// GaugeFunc is a gauge whose values are read from a function on every scrape.
+type GaugeFunc struct {
+	name, help string
+	label      string
+	fn         func() map[string]float64
+}
+
+// NewGaugeFunc creates and registers a gauge partitioned by a single label.
+// fn returns the current value per label value.
+func NewGaugeFunc(name, help, label string, fn func() map[string]float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, label: label, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	values := g.fn()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+	for _, labelValue := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s%s %v\n", g.name, formatLabels([]string{g.label}, []string{labelValue}), values[labelValue])
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = fmt.Sprintf("%s=%q", name, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
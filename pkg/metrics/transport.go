@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+// InstrumentTransport wraps next to record the count, status and latency of
+// Sonarr API requests per endpoint.
+func InstrumentTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &instrumentedTransport{next: next}
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointName(req)
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	SonarrRequestDuration.Observe(time.Since(start).Seconds(), endpoint)
+	if err != nil {
+		SonarrRequests.Inc(endpoint, "error")
+		return resp, err
+	}
+	SonarrRequests.Inc(endpoint, strconv.Itoa(resp.StatusCode))
+	return resp, err
+}
+
+// endpointName returns method and path of a request relative to the API root,
+// with numeric IDs replaced, e.g. "GET series/:id".
+func endpointName(req *http.Request) string {
+	path := req.URL.Path
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		path = path[i+len("/api/"):]
+		// strip the API version, e.g. v3/
+		if _, rest, found := strings.Cut(path, "/"); found {
+			path = rest
+		}
+	} else {
+		path = path[strings.LastIndex(path, "/")+1:]
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			segments[i] = ":id"
+		}
+	}
+	return req.Method + " " + strings.Join(segments, "/")
+}
@@ -0,0 +1,210 @@
+// Package sonarrapi extends the starr Sonarr client with the API endpoints the
+// bot needs that starr does not cover.
+package sonarrapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"golift.io/starr"
+	"golift.io/starr/sonarr"
+)
+
+// Client is a starr Sonarr client with additional endpoints.
+type Client struct {
+	*sonarr.Sonarr
+}
+
+// New returns a client for the Sonarr server configured in config.
+func New(config *starr.Config) *Client {
+	return &Client{Sonarr: sonarr.New(config)}
+}
+
+// PatchImportList changes single fields of an import list, e.g.
+// "enableAutomaticAdd". Unlike UpdateImportList, fields that starr does not
+// know about are kept.
+func (c *Client) PatchImportList(importListID int64, fields map[string]any) (*sonarr.ImportListOutput, error) {
+	uri := path.Join(sonarr.APIver, "importlist", fmt.Sprint(importListID))
+	return patch[sonarr.ImportListOutput](c, uri, fields)
+}
+
+// patch reads the resource at uri, changes the given fields and writes it back.
+func patch[T any](c *Client, uri string, fields map[string]any) (*T, error) {
+	ctx := context.Background()
+	var resource map[string]any
+	req := starr.Request{URI: uri}
+	if err := c.GetInto(ctx, req, &resource); err != nil {
+		return nil, fmt.Errorf("api.Get(%s): %w", &req, err)
+	}
+	for name, value := range fields {
+		resource[name] = value
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(resource); err != nil {
+		return nil, fmt.Errorf("json.Marshal(%s): %w", uri, err)
+	}
+	var output T
+	req = starr.Request{URI: uri, Body: &body}
+	if err := c.PutInto(ctx, req, &output); err != nil {
+		return nil, fmt.Errorf("api.Put(%s): %w", &req, err)
+	}
+	return &output, nil
+}
+
+// ProviderStatus is the failure state of an indexer or download client.
+// Sonarr disables a provider temporarily after repeated failures.
+type ProviderStatus struct {
+	ID                int64     `json:"id"`
+	ProviderID        int64     `json:"providerId"`
+	InitialFailure    time.Time `json:"initialFailure,omitempty"`
+	MostRecentFailure time.Time `json:"mostRecentFailure,omitempty"`
+	EscalationLevel   int       `json:"escalationLevel"`
+	DisabledTill      time.Time `json:"disabledTill,omitempty"`
+}
+
+// ProviderTestResult is the result of testing an indexer or download client.
+type ProviderTestResult struct {
+	ID                 int64                `json:"id"`
+	IsValid            bool                 `json:"isValid"`
+	ValidationFailures []*ValidationFailure `json:"validationFailures"`
+}
+
+// ValidationFailure is a problem found by a provider test.
+type ValidationFailure struct {
+	PropertyName string `json:"propertyName"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+// GetIndexerStatus returns the status of all failing indexers.
+func (c *Client) GetIndexerStatus() ([]*ProviderStatus, error) {
+	return c.getProviderStatus("indexerstatus")
+}
+
+// GetDownloadClientStatus returns the status of all failing download clients.
+func (c *Client) GetDownloadClientStatus() ([]*ProviderStatus, error) {
+	return c.getProviderStatus("downloadclientstatus")
+}
+
+// TestSavedIndexer tests an indexer with its saved settings.
+func (c *Client) TestSavedIndexer(indexerID int64) (*ProviderTestResult, error) {
+	return c.testProvider("indexer", indexerID)
+}
+
+// TestSavedDownloadClient tests a download client with its saved settings.
+func (c *Client) TestSavedDownloadClient(downloadClientID int64) (*ProviderTestResult, error) {
+	return c.testProvider("downloadclient", downloadClientID)
+}
+
+// TestAllIndexers tests all enabled indexers.
+func (c *Client) TestAllIndexers() ([]*ProviderTestResult, error) {
+	return c.testAllProviders("indexer")
+}
+
+// TestAllDownloadClients tests all enabled download clients.
+func (c *Client) TestAllDownloadClients() ([]*ProviderTestResult, error) {
+	return c.testAllProviders("downloadclient")
+}
+
+// PatchIndexer changes single fields of an indexer, e.g. "enableRss".
+func (c *Client) PatchIndexer(indexerID int64, fields map[string]any) (*sonarr.IndexerOutput, error) {
+	uri := path.Join(sonarr.APIver, "indexer", fmt.Sprint(indexerID))
+	return patch[sonarr.IndexerOutput](c, uri, fields)
+}
+
+// PatchDownloadClient changes single fields of a download client, e.g. "enable".
+func (c *Client) PatchDownloadClient(downloadClientID int64, fields map[string]any) (*sonarr.DownloadClientOutput, error) {
+	uri := path.Join(sonarr.APIver, "downloadclient", fmt.Sprint(downloadClientID))
+	return patch[sonarr.DownloadClientOutput](c, uri, fields)
+}
+
+func (c *Client) getProviderStatus(resource string) ([]*ProviderStatus, error) {
+	var output []*ProviderStatus
+	req := starr.Request{URI: path.Join(sonarr.APIver, resource)}
+	if err := c.GetInto(context.Background(), req, &output); err != nil {
+		return nil, fmt.Errorf("api.Get(%s): %w", &req, err)
+	}
+	return output, nil
+}
+
+// testProvider posts the saved provider to the test endpoint. Sonarr answers
+// a failed test with 400 and the validation failures as body.
+func (c *Client) testProvider(resource string, providerID int64) (*ProviderTestResult, error) {
+	ctx := context.Background()
+	var provider json.RawMessage
+	req := starr.Request{URI: path.Join(sonarr.APIver, resource, fmt.Sprint(providerID))}
+	if err := c.GetInto(ctx, req, &provider); err != nil {
+		return nil, fmt.Errorf("api.Get(%s): %w", &req, err)
+	}
+
+	result := &ProviderTestResult{ID: providerID, IsValid: true}
+	req = starr.Request{URI: path.Join(sonarr.APIver, resource, "test"), Body: bytes.NewReader(provider)}
+	err := c.PostInto(ctx, req, nil)
+	if body, ok := badRequestBody(err); ok {
+		result.IsValid = false
+		if err := json.Unmarshal(body, &result.ValidationFailures); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal(%s): %w", &req, err)
+		}
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("api.Post(%s): %w", &req, err)
+	}
+	return result, nil
+}
+
+// testAllProviders tests all enabled providers. Sonarr answers with 400 if
+// any of them fails, the body contains the results either way.
+func (c *Client) testAllProviders(resource string) ([]*ProviderTestResult, error) {
+	var output []*ProviderTestResult
+	req := starr.Request{URI: path.Join(sonarr.APIver, resource, "testall")}
+	err := c.PostInto(context.Background(), req, &output)
+	if body, ok := badRequestBody(err); ok {
+		if err := json.Unmarshal(body, &output); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal(%s): %w", &req, err)
+		}
+		return output, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("api.Post(%s): %w", &req, err)
+	}
+	return output, nil
+}
+
+func badRequestBody(err error) ([]byte, bool) {
+	var reqErr *starr.ReqError
+	if errors.As(err, &reqErr) && reqErr.Code == http.StatusBadRequest {
+		return reqErr.Body, true
+	}
+	return nil, false
+}
+
+// TagDetails is a tag with the IDs of everything using it.
+type TagDetails struct {
+	ID                int     `json:"id"`
+	Label             string  `json:"label"`
+	DelayProfileIDs   []int64 `json:"delayProfileIds"`
+	ImportListIDs     []int64 `json:"importListIds"`
+	NotificationIDs   []int64 `json:"notificationIds"`
+	RestrictionIDs    []int64 `json:"restrictionIds"` // release profiles
+	IndexerIDs        []int64 `json:"indexerIds"`
+	DownloadClientIDs []int64 `json:"downloadClientIds"`
+	AutoTagIDs        []int64 `json:"autoTagIds"`
+	SeriesIDs         []int64 `json:"seriesIds"`
+}
+
+// GetTagDetails returns all tags with their usage.
+func (c *Client) GetTagDetails() ([]*TagDetails, error) {
+	var output []*TagDetails
+	req := starr.Request{URI: path.Join(sonarr.APIver, "tag", "detail")}
+	if err := c.GetInto(context.Background(), req, &output); err != nil {
+		return nil, fmt.Errorf("api.Get(%s): %w", &req, err)
+	}
+	return output, nil
+}
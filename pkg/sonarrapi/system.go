@@ -0,0 +1,111 @@
+package sonarrapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"golift.io/starr"
+	"golift.io/starr/sonarr"
+)
+
+// SystemStatus is the system status including the database fields starr
+// does not decode.
+type SystemStatus struct {
+	sonarr.SystemStatus
+	DatabaseType    string `json:"databaseType"`
+	DatabaseVersion string `json:"databaseVersion"`
+}
+
+// Task is a scheduled task. TaskName is the command that runs the task.
+type Task struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	TaskName      string    `json:"taskName"`
+	Interval      int       `json:"interval"` // minutes
+	LastExecution time.Time `json:"lastExecution"`
+	LastStartTime time.Time `json:"lastStartTime"`
+	NextExecution time.Time `json:"nextExecution"`
+	LastDuration  string    `json:"lastDuration"`
+}
+
+// Health is a health check problem, Type is "ok", "notice", "warning" or
+// "error".
+type Health struct {
+	Source  string `json:"source"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	WikiURL string `json:"wikiUrl"`
+}
+
+// LogPage is a page of log records, newest first.
+type LogPage struct {
+	Page         int          `json:"page"`
+	PageSize     int          `json:"pageSize"`
+	TotalRecords int          `json:"totalRecords"`
+	Records      []*LogRecord `json:"records"`
+}
+
+// LogRecord is a log entry.
+type LogRecord struct {
+	ID            int64     `json:"id"`
+	Time          time.Time `json:"time"`
+	Level         string    `json:"level"`
+	Logger        string    `json:"logger"`
+	Message       string    `json:"message"`
+	Exception     string    `json:"exception"`
+	ExceptionType string    `json:"exceptionType"`
+}
+
+// GetSystemStatusDetails returns the system status with the database type.
+func (c *Client) GetSystemStatusDetails() (*SystemStatus, error) {
+	var output SystemStatus
+	req := starr.Request{URI: path.Join(sonarr.APIver, "system", "status")}
+	if err := c.GetInto(context.Background(), req, &output); err != nil {
+		return nil, fmt.Errorf("api.Get(%s): %w", &req, err)
+	}
+	return &output, nil
+}
+
+// GetSystemTasks returns the scheduled tasks.
+func (c *Client) GetSystemTasks() ([]*Task, error) {
+	var output []*Task
+	req := starr.Request{URI: path.Join(sonarr.APIver, "system", "task")}
+	if err := c.GetInto(context.Background(), req, &output); err != nil {
+		return nil, fmt.Errorf("api.Get(%s): %w", &req, err)
+	}
+	return output, nil
+}
+
+// GetHealth returns the current health check problems.
+func (c *Client) GetHealth() ([]*Health, error) {
+	var output []*Health
+	req := starr.Request{URI: path.Join(sonarr.APIver, "health")}
+	if err := c.GetInto(context.Background(), req, &output); err != nil {
+		return nil, fmt.Errorf("api.Get(%s): %w", &req, err)
+	}
+	return output, nil
+}
+
+// GetLogs returns a page of log records, starting with page 1. With level,
+// e.g. "warn", only records of that level or above are returned.
+func (c *Client) GetLogs(page, pageSize int, level string) (*LogPage, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("pageSize", strconv.Itoa(pageSize))
+	query.Set("sortKey", "time")
+	query.Set("sortDirection", "descending")
+	if level != "" {
+		query.Set("level", level)
+	}
+
+	var output LogPage
+	req := starr.Request{URI: path.Join(sonarr.APIver, "log"), Query: query}
+	if err := c.GetInto(context.Background(), req, &output); err != nil {
+		return nil, fmt.Errorf("api.Get(%s): %w", &req, err)
+	}
+	return &output, nil
+}